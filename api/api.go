@@ -5,6 +5,8 @@ import (
 	"go-app/server/auth"
 	"go-app/server/config"
 	"go-app/server/handler"
+	"go-app/server/health"
+	"go-app/server/tlsconfig"
 	"go-app/server/validator"
 	"net/http"
 
@@ -21,6 +23,15 @@ type API struct {
 	TokenAuth  auth.TokenAuth
 	Validator  *validator.Validator
 
+	RefreshTokens *auth.RefreshTokenStore
+	Revocations   *auth.RevocationStore
+	Sessions      auth.Session
+	Policy        *auth.Policy
+	OIDC          *auth.OIDCClient
+	ClientCerts   *auth.ClientCertificateAuth
+	Certs         *tlsconfig.CertReloader
+	Health        *health.Registry
+
 	App *app.App
 }
 
@@ -32,6 +43,15 @@ type Options struct {
 	Config     *config.APIConfig
 	TokenAuth  auth.TokenAuth
 	Validator  *validator.Validator
+
+	RefreshTokens *auth.RefreshTokenStore
+	Revocations   *auth.RevocationStore
+	Sessions      auth.Session
+	Policy        *auth.Policy
+	OIDC          *auth.OIDCClient
+	ClientCerts   *auth.ClientCertificateAuth
+	Certs         *tlsconfig.CertReloader
+	Health        *health.Registry
 }
 
 // Router stores all the endpoints available for the server to respond.
@@ -50,6 +70,15 @@ func NewAPI(opts *Options) *API {
 		TokenAuth:  opts.TokenAuth,
 		Logger:     opts.Logger,
 		Validator:  opts.Validator,
+
+		RefreshTokens: opts.RefreshTokens,
+		Revocations:   opts.Revocations,
+		Sessions:      opts.Sessions,
+		Policy:        opts.Policy,
+		OIDC:          opts.OIDC,
+		ClientCerts:   opts.ClientCerts,
+		Certs:         opts.Certs,
+		Health:        opts.Health,
 	}
 	api.setupRoutes()
 	return &api
@@ -58,6 +87,8 @@ func NewAPI(opts *Options) *API {
 func (a *API) setupRoutes() {
 	a.Router.Root = a.MainRouter
 	a.Router.APIRoot = a.MainRouter.PathPrefix("/api").Subrouter()
+	a.Router.Root.Handle("/healthz", a.requestHandler(a.healthz)).Methods("GET")
+	a.Router.Root.Handle("/readyz", a.requestHandler(a.readyz)).Methods("GET")
 	a.InitRoutes()
 	if a.Config.EnableTestRoute {
 		a.InitTestRoutes()
@@ -74,8 +105,12 @@ func (a *API) requestHandler(h func(c *handler.RequestContext, w http.ResponseWr
 	return &handler.Request{
 		HandlerFunc: h,
 		AuthFunc:    a.TokenAuth,
+		APIKeys:     &apiKeyAuth{api: a},
+		ClientCerts: a.clientCertAuth(),
+		Revocations: a.revocationList(),
+		Session:     a.Sessions,
+		Policy:      a.Policy,
 		IsLoggedIn:  false,
-		IsSudoUser:  false,
 	}
 }
 
@@ -83,8 +118,12 @@ func (a *API) requestWithAuthHandler(h func(c *handler.RequestContext, w http.Re
 	return &handler.Request{
 		HandlerFunc: h,
 		AuthFunc:    a.TokenAuth,
+		APIKeys:     &apiKeyAuth{api: a},
+		ClientCerts: a.clientCertAuth(),
+		Revocations: a.revocationList(),
+		Session:     a.Sessions,
+		Policy:      a.Policy,
 		IsLoggedIn:  true,
-		IsSudoUser:  false,
 	}
 }
 
@@ -92,7 +131,58 @@ func (a *API) requestWithSudoHandler(h func(c *handler.RequestContext, w http.Re
 	return &handler.Request{
 		HandlerFunc: h,
 		AuthFunc:    a.TokenAuth,
+		APIKeys:     &apiKeyAuth{api: a},
+		ClientCerts: a.clientCertAuth(),
+		Revocations: a.revocationList(),
+		Session:     a.Sessions,
+		Policy:      a.Policy,
+		IsLoggedIn:  true,
+		Roles:       []string{auth.RoleAdmin},
+	}
+}
+
+// requestWithMFAPendingHandler accepts only logged in callers, including the ones waiting for mfa verification
+func (a *API) requestWithMFAPendingHandler(h func(c *handler.RequestContext, w http.ResponseWriter, r *http.Request)) http.Handler {
+	return &handler.Request{
+		HandlerFunc:     h,
+		AuthFunc:        a.TokenAuth,
+		APIKeys:         &apiKeyAuth{api: a},
+		ClientCerts:     a.clientCertAuth(),
+		Revocations:     a.revocationList(),
+		Session:         a.Sessions,
+		Policy:          a.Policy,
+		IsLoggedIn:      true,
+		AllowMFAPending: true,
+	}
+}
+
+// requestWithPermission requires the caller to be granted the permission, e.g. "orders:write"
+func (a *API) requestWithPermission(permission string, h func(c *handler.RequestContext, w http.ResponseWriter, r *http.Request)) http.Handler {
+	return &handler.Request{
+		HandlerFunc: h,
+		AuthFunc:    a.TokenAuth,
+		APIKeys:     &apiKeyAuth{api: a},
+		ClientCerts: a.clientCertAuth(),
+		Revocations: a.revocationList(),
+		Session:     a.Sessions,
+		Policy:      a.Policy,
 		IsLoggedIn:  true,
-		IsSudoUser:  true,
+		Permissions: []string{permission},
+	}
+}
+
+// revocationList returns nil interface when revocation store is not configured
+func (a *API) revocationList() auth.RevocationList {
+	if a.Revocations == nil {
+		return nil
+	}
+	return a.Revocations
+}
+
+// clientCertAuth returns nil interface when client certificates are not verified
+func (a *API) clientCertAuth() auth.ClientCertAuth {
+	if a.ClientCerts == nil {
+		return nil
 	}
+	return a.ClientCerts
 }
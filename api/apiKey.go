@@ -0,0 +1,66 @@
+package api
+
+import (
+	"go-app/app"
+	"go-app/server/auth"
+	"go-app/server/handler"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	errors "github.com/vasupal1996/goerror"
+)
+
+// apiKeyAuth resolves api keys using the app api key service.
+// App is wired after routes are set up so the service is looked up on every request.
+type apiKeyAuth struct {
+	api *API
+}
+
+// VerifyAPIKey returns claim of the api key
+func (k *apiKeyAuth) VerifyAPIKey(key string) (auth.Claim, error) {
+	if k.api.App == nil || k.api.App.APIKey == nil {
+		return nil, errors.New("api key authentication is not configured", &errors.PermissionDenied)
+	}
+	return k.api.App.APIKey.VerifyAPIKey(key)
+}
+
+// createAPIKey creates a new api key, the key is only returned in this response
+func (a *API) createAPIKey(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	opts := app.CreateAPIKeyOpts{}
+	if err := a.DecodeJSONBody(r, &opts); err != nil {
+		requestCTX.SetErr(err, http.StatusBadRequest)
+		return
+	}
+	if errs := a.Validator.Validate(&opts); errs != nil {
+		requestCTX.SetErrs(errs, http.StatusBadRequest)
+		return
+	}
+	if uc, ok := requestCTX.UserClaim.(*auth.UserClaim); ok {
+		opts.CreatedBy = uc.ID
+	}
+	resp, err := a.App.APIKey.CreateAPIKey(&opts)
+	if err != nil {
+		requestCTX.SetErr(err, statusCodeFromErr(err))
+		return
+	}
+	requestCTX.SetAppResponse(resp, http.StatusCreated)
+}
+
+// listAPIKeys lists all the api keys without the keys themselves
+func (a *API) listAPIKeys(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	resp, err := a.App.APIKey.ListAPIKeys()
+	if err != nil {
+		requestCTX.SetErr(err, statusCodeFromErr(err))
+		return
+	}
+	requestCTX.SetAppResponse(resp, http.StatusOK)
+}
+
+// revokeAPIKey revokes the api key with id passed in url
+func (a *API) revokeAPIKey(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	if err := a.App.APIKey.RevokeAPIKey(mux.Vars(r)["id"]); err != nil {
+		requestCTX.SetErr(err, statusCodeFromErr(err))
+		return
+	}
+	requestCTX.SetAppResponse("api key revoked", http.StatusOK)
+}
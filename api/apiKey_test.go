@@ -0,0 +1,201 @@
+package api
+
+import (
+	"go-app/app"
+	"go-app/mock"
+	"go-app/server/auth"
+	"go-app/server/handler"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	errors "github.com/vasupal1996/goerror"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestAPI_apiKeyAdmin(t *testing.T) {
+	api := NewTestAPI(getTestConfig())
+	keyID, _ := primitive.ObjectIDFromHex("60096eb2f03a83b5ae315c78")
+	adminToken, _ := api.TokenAuth.SignToken(&auth.UserClaim{ID: "admin-1", Type: "admin"})
+	userToken, _ := api.TokenAuth.SignToken(&auth.UserClaim{ID: "user-1", Type: "user"})
+
+	tests := []struct {
+		name          string
+		method        string
+		url           string
+		token         string
+		body          io.Reader
+		buildStubs    func(k *mock.MockAPIKey)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "Create As User",
+			method: http.MethodPost,
+			url:    "/api/admin/api-keys",
+			token:  userToken,
+			body:   strings.NewReader(`{"name":"partner","scopes":["orders:read"]}`),
+			buildStubs: func(k *mock.MockAPIKey) {
+				k.EXPECT().CreateAPIKey(gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusForbidden, r.Code)
+			},
+		},
+		{
+			name:   "Create Without Scopes",
+			method: http.MethodPost,
+			url:    "/api/admin/api-keys",
+			token:  adminToken,
+			body:   strings.NewReader(`{"name":"partner","scopes":[]}`),
+			buildStubs: func(k *mock.MockAPIKey) {
+				k.EXPECT().CreateAPIKey(gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, r.Code)
+			},
+		},
+		{
+			name:   "Create",
+			method: http.MethodPost,
+			url:    "/api/admin/api-keys",
+			token:  adminToken,
+			body:   strings.NewReader(`{"name":"partner","scopes":["orders:read"]}`),
+			buildStubs: func(k *mock.MockAPIKey) {
+				opts := &app.CreateAPIKeyOpts{Name: "partner", Scopes: []string{"orders:read"}, CreatedBy: "admin-1"}
+				k.EXPECT().CreateAPIKey(gomock.Eq(opts)).Times(1).Return(&app.CreateAPIKeyResp{
+					APIKeyResp: app.APIKeyResp{ID: keyID, Name: "partner", Scopes: []string{"orders:read"}},
+					Key:        "gak_secret",
+				}, nil)
+			},
+			checkResponse: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusCreated, r.Code)
+				assert.Contains(t, r.Body.String(), `"key":"gak_secret"`)
+			},
+		},
+		{
+			name:   "List",
+			method: http.MethodGet,
+			url:    "/api/admin/api-keys",
+			token:  adminToken,
+			buildStubs: func(k *mock.MockAPIKey) {
+				k.EXPECT().ListAPIKeys().Times(1).Return([]app.APIKeyResp{{ID: keyID, Name: "partner"}}, nil)
+			},
+			checkResponse: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, r.Code)
+				assert.Contains(t, r.Body.String(), `"name":"partner"`)
+				assert.NotContains(t, r.Body.String(), `"key"`)
+			},
+		},
+		{
+			name:   "Revoke",
+			method: http.MethodDelete,
+			url:    "/api/admin/api-keys/" + keyID.Hex(),
+			token:  adminToken,
+			buildStubs: func(k *mock.MockAPIKey) {
+				k.EXPECT().RevokeAPIKey(gomock.Eq(keyID.Hex())).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, r.Code)
+			},
+		},
+		{
+			name:   "Revoke Not Found",
+			method: http.MethodDelete,
+			url:    "/api/admin/api-keys/" + keyID.Hex(),
+			token:  adminToken,
+			buildStubs: func(k *mock.MockAPIKey) {
+				k.EXPECT().RevokeAPIKey(gomock.Eq(keyID.Hex())).Times(1).Return(errors.New("api key not found", &errors.NotFound))
+			},
+			checkResponse: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, r.Code)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			k := mock.NewMockAPIKey(ctrl)
+			tt.buildStubs(k)
+			api.App.APIKey = k
+
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequest(tt.method, tt.url, tt.body)
+			assert.Nil(t, err)
+			req.Header.Set("Authorization", tt.token)
+			api.Router.Root.ServeHTTP(recorder, req)
+			tt.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestAPI_apiKeyAuthentication(t *testing.T) {
+	api := NewTestAPI(getTestConfig())
+	h := func(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+		requestCTX.SetAppResponse(requestCTX.UserClaim.(*auth.APIKeyClaim).Name, http.StatusOK)
+	}
+	api.Router.Root.Handle("/orders", api.requestWithPermission("orders:read", h)).Methods("GET")
+
+	tests := []struct {
+		name       string
+		key        string
+		buildStubs func(k *mock.MockAPIKey)
+		wantCode   int
+	}{
+		{
+			name: "Valid Key With Scope",
+			key:  "gak_valid",
+			buildStubs: func(k *mock.MockAPIKey) {
+				k.EXPECT().VerifyAPIKey("gak_valid").Times(1).Return(&auth.APIKeyClaim{ID: "1", Name: "partner", Scopes: []string{"orders:*"}}, nil)
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "Valid Key Without Scope",
+			key:  "gak_valid",
+			buildStubs: func(k *mock.MockAPIKey) {
+				k.EXPECT().VerifyAPIKey("gak_valid").Times(1).Return(&auth.APIKeyClaim{ID: "1", Name: "partner", Scopes: []string{"users:read"}}, nil)
+			},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name: "Invalid Key",
+			key:  "gak_revoked",
+			buildStubs: func(k *mock.MockAPIKey) {
+				k.EXPECT().VerifyAPIKey("gak_revoked").Times(1).Return(nil, auth.ErrInvalidAPIKey)
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "Without Key",
+			buildStubs: func(k *mock.MockAPIKey) {
+				k.EXPECT().VerifyAPIKey(gomock.Any()).Times(0)
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			k := mock.NewMockAPIKey(ctrl)
+			tt.buildStubs(k)
+			api.App.APIKey = k
+
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, "/orders", nil)
+			assert.Nil(t, err)
+			if tt.key != "" {
+				req.Header.Set(auth.HeaderAPIKey, tt.key)
+			}
+			api.Router.Root.ServeHTTP(recorder, req)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+		})
+	}
+}
@@ -2,20 +2,35 @@ package api
 
 import (
 	"go-app/app"
+	"go-app/server/auth"
 	"go-app/server/config"
+	"go-app/server/health"
 	"go-app/server/logger"
+	memorystorage "go-app/server/storage/memory"
+	"go-app/server/validator"
 
 	"github.com/gorilla/mux"
 )
 
-// NewTestAPI returns api struct for unit testing
+// NewTestAPI returns api struct for unit testing.
+// Token authentication is configured from test config and backed by memory storage.
 func NewTestAPI(c *config.APIConfig) *API {
 	l := logger.NewLogger(nil, logger.NewZeroLogConsoleWriter(logger.NewStandardConsoleWriter()), nil)
+	tc := config.GetConfigFromFile("test")
+	ms := memorystorage.NewMemoryStorageWithCleanupInterval(0)
+	ta, _ := auth.NewTokenAuthentication(&tc.TokenAuthConfig)
 	api := &API{
 		MainRouter: &mux.Router{},
 		Router:     &Router{},
 		Config:     c,
 		Logger:     l,
+		Validator:  validator.NewValidation(),
+		TokenAuth:  ta,
+
+		RefreshTokens: auth.NewRefreshTokenStore(ms, &tc.TokenAuthConfig),
+		Revocations:   auth.NewRevocationStore(ms),
+		Policy:        auth.NewPolicyFromConfig(&tc.RBACConfig),
+		Health:        health.NewRegistry(),
 	}
 	api.setupRoutes()
 	api.App = &app.App{}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"go-app/server/auth"
+	"go-app/server/handler"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPI_requestWithPermission(t *testing.T) {
+	api := NewTestAPI(getTestConfig())
+	api.Policy.Grant("user", "orders:read")
+	api.Policy.Grant("editor", "orders:*")
+	h := func(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+		requestCTX.SetAppResponse("ok", http.StatusOK)
+	}
+	api.Router.Root.Handle("/orders", api.requestWithPermission("orders:write", h)).Methods("POST")
+	api.Router.Root.Handle("/sudo", api.requestWithSudoHandler(h)).Methods("POST")
+
+	tests := []struct {
+		name     string
+		url      string
+		roles    []string
+		wantCode int
+	}{
+		{name: "Orders User", url: "/orders", roles: []string{"user"}, wantCode: http.StatusForbidden},
+		{name: "Orders Editor", url: "/orders", roles: []string{"editor"}, wantCode: http.StatusOK},
+		{name: "Orders Admin", url: "/orders", roles: []string{"admin"}, wantCode: http.StatusOK},
+		{name: "Orders User And Editor", url: "/orders", roles: []string{"user", "editor"}, wantCode: http.StatusOK},
+		{name: "Sudo User", url: "/sudo", roles: []string{"user"}, wantCode: http.StatusForbidden},
+		{name: "Sudo Editor", url: "/sudo", roles: []string{"editor"}, wantCode: http.StatusForbidden},
+		{name: "Sudo Admin", url: "/sudo", roles: []string{"admin"}, wantCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := api.TokenAuth.SignToken(&auth.UserClaim{ID: "1", Roles: tt.roles})
+			assert.Nil(t, err)
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodPost, tt.url, nil)
+			assert.Nil(t, err)
+			req.Header.Set("Authorization", token)
+			api.Router.Root.ServeHTTP(recorder, req)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"go-app/server/auth"
+	"go-app/server/handler"
+	"net/http"
+
+	errors "github.com/vasupal1996/goerror"
+)
+
+// jwks publishes public keys of the token auth key set so that other services can verify tokens issued by this server
+func (a *API) jwks(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	kp, ok := a.TokenAuth.(auth.JWKSProvider)
+	if !ok {
+		requestCTX.SetErr(errors.New("jwks is not supported by token auth", &errors.NotFound), http.StatusNotFound)
+		return
+	}
+	requestCTX.SetRawJSONResponse(kp.JWKS(), http.StatusOK)
+}
+
+// RefreshTokenOpts contains refresh token to be exchanged for a new access token
+type RefreshTokenOpts struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// TokenResp contains signed access token and the refresh token which can be used to get a new access token
+// When MFARequired is set the token is a partial token only accepted by the mfa verification endpoint.
+type TokenResp struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+}
+
+// refreshToken rotates refresh token and signs a new access token for the claim it was issued for
+func (a *API) refreshToken(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	opts := RefreshTokenOpts{}
+	if err := a.DecodeJSONBody(r, &opts); err != nil {
+		requestCTX.SetErr(err, http.StatusBadRequest)
+		return
+	}
+	if errs := a.Validator.Validate(&opts); errs != nil {
+		requestCTX.SetErrs(errs, http.StatusBadRequest)
+		return
+	}
+	refreshToken, claim, err := a.RefreshTokens.Rotate(opts.RefreshToken)
+	if err != nil {
+		requestCTX.SetErr(errors.Wrap(err, "failed to refresh token", &errors.PermissionDenied), http.StatusUnauthorized)
+		return
+	}
+	token, err := a.TokenAuth.SignToken(claim)
+	if err != nil {
+		requestCTX.SetErr(errors.Wrap(err, "failed to sign token", &errors.SomethingWentWrong), http.StatusInternalServerError)
+		return
+	}
+	requestCTX.SetAppResponse(&TokenResp{Token: token, RefreshToken: refreshToken}, http.StatusOK)
+}
+
+// LogoutOpts contains refresh token to be revoked together with the access token
+type LogoutOpts struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// logout revokes the access token of the request, deletes the cookie session and, if passed, the refresh token family
+func (a *API) logout(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	opts := LogoutOpts{}
+	if r.ContentLength != 0 {
+		if err := a.DecodeJSONBody(r, &opts); err != nil {
+			requestCTX.SetErr(err, http.StatusBadRequest)
+			return
+		}
+	}
+	if uc, ok := requestCTX.UserClaim.(*auth.UserClaim); ok {
+		if err := a.Revocations.Revoke(uc); err != nil {
+			requestCTX.SetErr(errors.Wrap(err, "failed to revoke token", &errors.SomethingWentWrong), http.StatusInternalServerError)
+			return
+		}
+	}
+	if a.Sessions != nil {
+		if err := a.Sessions.Delete(w, r); err != nil && err != auth.ErrSessionNotFound {
+			requestCTX.SetErr(errors.Wrap(err, "failed to delete session", &errors.SomethingWentWrong), http.StatusInternalServerError)
+			return
+		}
+	}
+	if opts.RefreshToken != "" {
+		if err := a.RefreshTokens.Revoke(opts.RefreshToken); err != nil {
+			requestCTX.SetErr(errors.Wrap(err, "failed to revoke refresh token", &errors.BadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+	requestCTX.SetAppResponse("logged out", http.StatusOK)
+}
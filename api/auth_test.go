@@ -0,0 +1,172 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"go-app/server/auth"
+	"go-app/server/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPI_jwks(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	rsaKeys, _ := auth.NewKeySet(0, &auth.SigningKey{ID: "k1", Method: jwt.SigningMethodRS256, PrivateKey: priv, PublicKey: &priv.PublicKey})
+	hmacKeys, _ := auth.NewKeySet(0, &auth.SigningKey{ID: "k1", Method: jwt.SigningMethodHS256, PrivateKey: []byte("a"), PublicKey: []byte("a")})
+
+	api := NewTestAPI(getTestConfig())
+	tests := []struct {
+		name          string
+		keys          auth.KeyStore
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "RSA Key Published",
+			keys: rsaKeys,
+			checkResponse: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, r.Code)
+				assert.Equal(t, "application/json", r.Header().Get("Content-Type"))
+				jwks := auth.JWKS{}
+				assert.Nil(t, json.NewDecoder(r.Body).Decode(&jwks))
+				assert.Len(t, jwks.Keys, 1)
+				assert.Equal(t, "k1", jwks.Keys[0].Kid)
+				assert.Equal(t, "RSA", jwks.Keys[0].Kty)
+			},
+		},
+		{
+			name: "HMAC Key Not Published",
+			keys: hmacKeys,
+			checkResponse: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, r.Code)
+				assert.Equal(t, "{\"keys\":[]}\n", r.Body.String())
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api.TokenAuth = &auth.TokenAuthentication{Config: &config.TokenAuthConfig{}, Keys: tt.keys}
+
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+			assert.Nil(t, err)
+			api.Router.Root.ServeHTTP(recorder, req)
+			tt.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestAPI_refreshToken(t *testing.T) {
+	api := NewTestAPI(getTestConfig())
+	tokenAuth := api.TokenAuth
+
+	uc := &auth.UserClaim{ID: "1", Type: "user"}
+	issued, err := api.RefreshTokens.Issue(uc)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name          string
+		body          string
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Missing Refresh Token",
+			body: `{}`,
+			checkResponse: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, r.Code)
+			},
+		},
+		{
+			name: "Valid Refresh Token",
+			body: fmt.Sprintf(`{"refresh_token":%q}`, issued),
+			checkResponse: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, r.Code)
+				resp := struct {
+					Payload TokenResp `json:"payload"`
+				}{}
+				assert.Nil(t, json.NewDecoder(r.Body).Decode(&resp))
+				assert.NotEqual(t, issued, resp.Payload.RefreshToken)
+				claim, err := tokenAuth.VerifyToken(resp.Payload.Token)
+				assert.Nil(t, err)
+				assert.Equal(t, "1", claim.(*auth.UserClaim).ID)
+			},
+		},
+		{
+			name: "Reused Refresh Token",
+			body: fmt.Sprintf(`{"refresh_token":%q}`, issued),
+			checkResponse: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, r.Code)
+				assert.Contains(t, r.Body.String(), "refresh token reuse detected")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodPost, "/api/auth/refresh", strings.NewReader(tt.body))
+			assert.Nil(t, err)
+			api.Router.Root.ServeHTTP(recorder, req)
+			tt.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestAPI_logout(t *testing.T) {
+	api := NewTestAPI(getTestConfig())
+	uc := &auth.UserClaim{ID: "1", Type: "user"}
+	token, err := api.TokenAuth.SignToken(uc)
+	assert.Nil(t, err)
+	refreshToken, err := api.RefreshTokens.Issue(uc)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name          string
+		token         string
+		body          string
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Without Token",
+			checkResponse: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, r.Code)
+			},
+		},
+		{
+			name:  "Logout",
+			token: token,
+			body:  fmt.Sprintf(`{"refresh_token":%q}`, refreshToken),
+			checkResponse: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, r.Code)
+				_, _, err := api.RefreshTokens.Rotate(refreshToken)
+				assert.Equal(t, auth.ErrInvalidRefreshToken, err)
+			},
+		},
+		{
+			name:  "Revoked Token",
+			token: token,
+			checkResponse: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, r.Code)
+				assert.Contains(t, r.Body.String(), "token is revoked")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodPost, "/api/auth/logout", strings.NewReader(tt.body))
+			assert.Nil(t, err)
+			if tt.token != "" {
+				req.Header.Set("Authorization", tt.token)
+			}
+			api.Router.Root.ServeHTTP(recorder, req)
+			tt.checkResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"go-app/app"
+	"go-app/server/auth"
+	"go-app/server/handler"
+	"net/http"
+
+	"github.com/dgrijalva/jwt-go"
+	errors "github.com/vasupal1996/goerror"
+)
+
+// enrollMFA generates a totp secret for the logged in admin
+func (a *API) enrollMFA(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	uc, ok := requestCTX.UserClaim.(*auth.UserClaim)
+	if !ok {
+		requestCTX.SetErr(errors.New("mfa can only be enrolled by users", &errors.PermissionDenied), http.StatusForbidden)
+		return
+	}
+	user, err := a.App.User.GetUser(uc.ID)
+	if err != nil {
+		requestCTX.SetErr(err, statusCodeFromErr(err))
+		return
+	}
+	resp, err := a.App.MFA.Enroll(&app.EnrollMFAOpts{UserID: uc.ID, Account: user.Email})
+	if err != nil {
+		requestCTX.SetErr(err, statusCodeFromErr(err))
+		return
+	}
+	requestCTX.SetAppResponse(resp, http.StatusOK)
+}
+
+// confirmMFA enables mfa for the logged in admin and returns recovery codes
+func (a *API) confirmMFA(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	uc, ok := requestCTX.UserClaim.(*auth.UserClaim)
+	if !ok {
+		requestCTX.SetErr(errors.New("mfa can only be enrolled by users", &errors.PermissionDenied), http.StatusForbidden)
+		return
+	}
+	opts := app.ConfirmMFAOpts{}
+	if err := a.DecodeJSONBody(r, &opts); err != nil {
+		requestCTX.SetErr(err, http.StatusBadRequest)
+		return
+	}
+	if errs := a.Validator.Validate(&opts); errs != nil {
+		requestCTX.SetErrs(errs, http.StatusBadRequest)
+		return
+	}
+	opts.UserID = uc.ID
+	resp, err := a.App.MFA.ConfirmEnrollment(&opts)
+	if err != nil {
+		requestCTX.SetErr(err, statusCodeFromErr(err))
+		return
+	}
+	requestCTX.SetAppResponse(resp, http.StatusOK)
+}
+
+// verifyMFA exchanges a partial token and a totp or recovery code for a full token
+func (a *API) verifyMFA(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	uc, ok := requestCTX.UserClaim.(*auth.UserClaim)
+	if !ok || !uc.MFAPending {
+		requestCTX.SetErr(errors.New("mfa verification is not pending", &errors.BadRequest), http.StatusBadRequest)
+		return
+	}
+	opts := app.VerifyMFAOpts{}
+	if err := a.DecodeJSONBody(r, &opts); err != nil {
+		requestCTX.SetErr(err, http.StatusBadRequest)
+		return
+	}
+	if errs := a.Validator.Validate(&opts); errs != nil {
+		requestCTX.SetErrs(errs, http.StatusBadRequest)
+		return
+	}
+	opts.UserID = uc.ID
+	if err := a.App.MFA.Verify(&opts); err != nil {
+		requestCTX.SetErr(err, statusCodeFromErr(err))
+		return
+	}
+	// partial token is single use
+	if a.Revocations != nil {
+		if err := a.Revocations.Revoke(uc); err != nil {
+			requestCTX.SetErr(errors.Wrap(err, "failed to revoke token", &errors.SomethingWentWrong), http.StatusInternalServerError)
+			return
+		}
+	}
+	full := *uc
+	full.MFAPending = false
+	full.StandardClaims = jwt.StandardClaims{}
+	a.signIn(requestCTX, w, r, &full)
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"encoding/json"
+	"go-app/app"
+	"go-app/mock"
+	"go-app/server/auth"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	errors "github.com/vasupal1996/goerror"
+)
+
+func decodeTokenResp(t *testing.T, r *httptest.ResponseRecorder) TokenResp {
+	resp := struct {
+		Payload TokenResp `json:"payload"`
+	}{}
+	assert.Nil(t, json.NewDecoder(r.Body).Decode(&resp))
+	return resp.Payload
+}
+
+func TestAPI_mfaLogin(t *testing.T) {
+	api := NewTestAPI(getTestConfig())
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	user := mock.NewMockUser(ctrl)
+	mfa := mock.NewMockMFA(ctrl)
+	api.App.User = user
+	api.App.MFA = mfa
+
+	serve := func(method, url, token, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		api.Router.Root.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// first step returns a partial token
+	user.EXPECT().Login(gomock.Any()).Times(1).Return(&auth.UserClaim{ID: "1", Type: "admin"}, nil)
+	mfa.EXPECT().IsEnabled("1").Times(1).Return(true, nil)
+	r := serve(http.MethodPost, "/api/auth/login", "", `{"email":"a@b.com","password":"password1"}`)
+	assert.Equal(t, http.StatusOK, r.Code)
+	partial := decodeTokenResp(t, r)
+	assert.True(t, partial.MFARequired)
+	assert.Empty(t, partial.RefreshToken)
+	claim, err := api.TokenAuth.VerifyToken(partial.Token)
+	assert.Nil(t, err)
+	assert.True(t, claim.(*auth.UserClaim).MFAPending)
+	assert.False(t, claim.IsAdmin())
+
+	// partial token is rejected everywhere except the verification endpoint
+	r = serve(http.MethodPost, "/api/auth/logout", partial.Token, "")
+	assert.Equal(t, http.StatusUnauthorized, r.Code)
+	assert.Contains(t, r.Body.String(), "mfa verification required")
+	r = serve(http.MethodGet, "/api/admin/api-keys", partial.Token, "")
+	assert.Equal(t, http.StatusUnauthorized, r.Code)
+	r = serve(http.MethodPost, "/api/auth/mfa/enroll", partial.Token, "")
+	assert.Equal(t, http.StatusUnauthorized, r.Code)
+
+	// wrong code
+	mfa.EXPECT().Verify(gomock.Eq(&app.VerifyMFAOpts{UserID: "1", Code: "000000"})).Times(1).Return(errors.New("invalid mfa code", &errors.Unauthorized))
+	r = serve(http.MethodPost, "/api/auth/mfa/verify", partial.Token, `{"code":"000000"}`)
+	assert.Equal(t, http.StatusUnauthorized, r.Code)
+
+	// valid code returns a full token and revokes the partial one
+	mfa.EXPECT().Verify(gomock.Eq(&app.VerifyMFAOpts{UserID: "1", Code: "123456"})).Times(1).Return(nil)
+	r = serve(http.MethodPost, "/api/auth/mfa/verify", partial.Token, `{"code":"123456"}`)
+	assert.Equal(t, http.StatusOK, r.Code)
+	full := decodeTokenResp(t, r)
+	assert.False(t, full.MFARequired)
+	assert.NotEmpty(t, full.RefreshToken)
+	claim, err = api.TokenAuth.VerifyToken(full.Token)
+	assert.Nil(t, err)
+	assert.False(t, claim.(*auth.UserClaim).MFAPending)
+	assert.True(t, claim.IsAdmin())
+
+	r = serve(http.MethodPost, "/api/auth/mfa/verify", partial.Token, `{"code":"123456"}`)
+	assert.Equal(t, http.StatusUnauthorized, r.Code)
+	assert.Contains(t, r.Body.String(), "token is revoked")
+
+	// full token is not accepted by the verification endpoint
+	r = serve(http.MethodPost, "/api/auth/mfa/verify", full.Token, `{"code":"123456"}`)
+	assert.Equal(t, http.StatusBadRequest, r.Code)
+}
+
+func TestAPI_mfaEnroll(t *testing.T) {
+	api := NewTestAPI(getTestConfig())
+	adminToken, _ := api.TokenAuth.SignToken(&auth.UserClaim{ID: "1", Type: "admin"})
+	userToken, _ := api.TokenAuth.SignToken(&auth.UserClaim{ID: "2", Type: "user"})
+
+	tests := []struct {
+		name       string
+		url        string
+		token      string
+		body       string
+		buildStubs func(u *mock.MockUser, m *mock.MockMFA)
+		wantCode   int
+		wantBody   string
+	}{
+		{
+			name:  "Enroll As User",
+			url:   "/api/auth/mfa/enroll",
+			token: userToken,
+			buildStubs: func(u *mock.MockUser, m *mock.MockMFA) {
+				m.EXPECT().Enroll(gomock.Any()).Times(0)
+			},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:  "Enroll As Admin",
+			url:   "/api/auth/mfa/enroll",
+			token: adminToken,
+			buildStubs: func(u *mock.MockUser, m *mock.MockMFA) {
+				u.EXPECT().GetUser("1").Times(1).Return(&app.UserResp{Email: "admin@example.com"}, nil)
+				m.EXPECT().Enroll(gomock.Eq(&app.EnrollMFAOpts{UserID: "1", Account: "admin@example.com"})).Times(1).
+					Return(&app.EnrollMFAResp{Secret: "ABC", URI: "otpauth://totp/go-app:admin@example.com?secret=ABC"}, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `"secret":"ABC"`,
+		},
+		{
+			name:  "Confirm Missing Code",
+			url:   "/api/auth/mfa/confirm",
+			token: adminToken,
+			body:  `{}`,
+			buildStubs: func(u *mock.MockUser, m *mock.MockMFA) {
+				m.EXPECT().ConfirmEnrollment(gomock.Any()).Times(0)
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:  "Confirm",
+			url:   "/api/auth/mfa/confirm",
+			token: adminToken,
+			body:  `{"code":"123456"}`,
+			buildStubs: func(u *mock.MockUser, m *mock.MockMFA) {
+				m.EXPECT().ConfirmEnrollment(gomock.Eq(&app.ConfirmMFAOpts{UserID: "1", Code: "123456"})).Times(1).
+					Return(&app.ConfirmMFAResp{RecoveryCodes: []string{"abcde-fghij"}}, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `"recovery_codes":["abcde-fghij"]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			u := mock.NewMockUser(ctrl)
+			m := mock.NewMockMFA(ctrl)
+			tt.buildStubs(u, m)
+			api.App.User = u
+			api.App.MFA = m
+
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodPost, tt.url, strings.NewReader(tt.body))
+			assert.Nil(t, err)
+			req.Header.Set("Authorization", tt.token)
+			api.Router.Root.ServeHTTP(recorder, req)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+			assert.Contains(t, recorder.Body.String(), tt.wantBody)
+		})
+	}
+}
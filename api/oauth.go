@@ -0,0 +1,388 @@
+package api
+
+import (
+	"bytes"
+	"go-app/app"
+	"go-app/server/auth"
+	"go-app/server/handler"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	errors "github.com/vasupal1996/goerror"
+)
+
+// error codes of oauth2 token endpoint, see RFC 6749 section 5.2
+const (
+	oauthErrInvalidRequest       = "invalid_request"
+	oauthErrInvalidClient        = "invalid_client"
+	oauthErrInvalidGrant         = "invalid_grant"
+	oauthErrUnauthorizedClient   = "unauthorized_client"
+	oauthErrUnsupportedGrantType = "unsupported_grant_type"
+	oauthErrInvalidScope         = "invalid_scope"
+	oauthErrUnsupportedTokenType = "unsupported_token_type"
+	oauthErrServerError          = "server_error"
+)
+
+// OAuthErrorResp is the error response of oauth2 endpoints
+type OAuthErrorResp struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// OAuthTokenResp is the successful response of the token endpoint
+type OAuthTokenResp struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in,omitempty"`
+	Scope       string `json:"scope,omitempty"`
+}
+
+// IntrospectionResp is the token introspection response, see RFC 7662 section 2.2
+type IntrospectionResp struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	TokenID   string `json:"jti,omitempty"`
+}
+
+var consentTemplate = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Authorize {{.Client}}</title></head>
+<body>
+<h1>{{.Client}} wants to access your account</h1>
+{{if .Scopes}}<p>It will be allowed to:</p>
+<ul>{{range .Scopes}}<li>{{.}}</li>{{end}}</ul>{{else}}<p>It will not be granted any permission.</p>{{end}}
+{{if .Denied}}<p>You do not have the following requested permissions, they will not be granted:</p>
+<ul>{{range .Denied}}<li>{{.}}</li>{{end}}</ul>{{end}}
+<form method="POST" action="{{.Action}}">
+<input type="hidden" name="request_id" value="{{.RequestID}}">
+{{range .Scopes}}<input type="hidden" name="scope" value="{{.}}">
+{{end}}<button type="submit" name="approve" value="true">Allow</button>
+<button type="submit" name="approve" value="false">Deny</button>
+</form>
+</body>
+</html>
+`))
+
+type consentPage struct {
+	Client    string
+	Scopes    []string
+	Denied    []string
+	RequestID string
+	Action    string
+}
+
+// registerOAuthClient registers a new oauth client, the client secret is only returned in this response
+func (a *API) registerOAuthClient(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	opts := app.RegisterClientOpts{}
+	if err := a.DecodeJSONBody(r, &opts); err != nil {
+		requestCTX.SetErr(err, http.StatusBadRequest)
+		return
+	}
+	if errs := a.Validator.Validate(&opts); errs != nil {
+		requestCTX.SetErrs(errs, http.StatusBadRequest)
+		return
+	}
+	resp, err := a.App.OAuth.RegisterClient(&opts)
+	if err != nil {
+		requestCTX.SetErr(err, statusCodeFromErr(err))
+		return
+	}
+	requestCTX.SetAppResponse(resp, http.StatusCreated)
+}
+
+// getOAuthClient returns the oauth client with id passed in url
+func (a *API) getOAuthClient(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	resp, err := a.App.OAuth.GetClient(mux.Vars(r)["id"])
+	if err != nil {
+		requestCTX.SetErr(err, statusCodeFromErr(err))
+		return
+	}
+	requestCTX.SetAppResponse(resp, http.StatusOK)
+}
+
+// authorize validates the authorization request of the logged in user and renders the consent page.
+// Errors are not redirected to the client since the redirect uri may not be trusted.
+func (a *API) authorize(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	uc, ok := requestCTX.UserClaim.(*auth.UserClaim)
+	if !ok || uc.ClientID != "" {
+		requestCTX.SetErr(errors.New("clients can only be authorized by users", &errors.PermissionDenied), http.StatusForbidden)
+		return
+	}
+	q := r.URL.Query()
+	req, err := a.App.OAuth.CreateAuthorizationRequest(&app.AuthorizationRequestOpts{
+		UserID:              uc.ID,
+		ClientID:            q.Get("client_id"),
+		RedirectURI:         q.Get("redirect_uri"),
+		ResponseType:        q.Get("response_type"),
+		Scopes:              strings.Fields(q.Get("scope")),
+		State:               q.Get("state"),
+		CodeChallenge:       q.Get("code_challenge"),
+		CodeChallengeMethod: q.Get("code_challenge_method"),
+	})
+	if err != nil {
+		requestCTX.SetErr(err, statusCodeFromErr(err))
+		return
+	}
+	page := consentPage{Client: req.Client.Name, RequestID: req.ID, Action: r.URL.Path}
+	for _, s := range req.Scopes {
+		if a.userHasScope(uc, s) {
+			page.Scopes = append(page.Scopes, s)
+		} else {
+			page.Denied = append(page.Denied, s)
+		}
+	}
+	var buf bytes.Buffer
+	if err := consentTemplate.Execute(&buf, &page); err != nil {
+		requestCTX.SetErr(errors.Wrap(err, "failed to render consent page", &errors.SomethingWentWrong), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Cache-Control", "no-store")
+	requestCTX.SetHTMLResponse(buf.Bytes(), http.StatusOK)
+}
+
+// approveAuthorization handles the consent form and redirects the user back to the client
+func (a *API) approveAuthorization(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	uc, ok := requestCTX.UserClaim.(*auth.UserClaim)
+	if !ok || uc.ClientID != "" {
+		requestCTX.SetErr(errors.New("clients can only be authorized by users", &errors.PermissionDenied), http.StatusForbidden)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		requestCTX.SetErr(errors.Wrap(err, "invalid form", &errors.BadRequest), http.StatusBadRequest)
+		return
+	}
+	// scopes are checked again since the form is submitted by the browser
+	scopes := []string{}
+	for _, s := range r.PostForm["scope"] {
+		if a.userHasScope(uc, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	redirect, err := a.App.OAuth.ApproveAuthorizationRequest(&app.ApproveAuthorizationOpts{
+		RequestID: r.PostForm.Get("request_id"),
+		UserID:    uc.ID,
+		Approved:  r.PostForm.Get("approve") == "true",
+		Scopes:    scopes,
+	})
+	if err != nil {
+		requestCTX.SetErr(err, statusCodeFromErr(err))
+		return
+	}
+	requestCTX.SetRedirectResponse(redirect, http.StatusSeeOther)
+}
+
+// oauthToken issues access tokens for client_credentials and authorization_code grants
+func (a *API) oauthToken(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	if err := r.ParseForm(); err != nil {
+		setOAuthErr(requestCTX, oauthErrInvalidRequest, "invalid form", http.StatusBadRequest)
+		return
+	}
+	client, ok := a.authenticateOAuthClient(requestCTX, w, r)
+	if !ok {
+		return
+	}
+	grantType := r.PostForm.Get("grant_type")
+	if grantType != app.GrantTypeClientCredentials && grantType != app.GrantTypeAuthorizationCode {
+		setOAuthErr(requestCTX, oauthErrUnsupportedGrantType, "", http.StatusBadRequest)
+		return
+	}
+	if !client.HasGrantType(grantType) {
+		setOAuthErr(requestCTX, oauthErrUnauthorizedClient, "client is not allowed to use "+grantType+" grant", http.StatusBadRequest)
+		return
+	}
+
+	claim := auth.UserClaim{ClientID: client.ID}
+	switch grantType {
+	case app.GrantTypeClientCredentials:
+		if !client.Confidential {
+			setOAuthErr(requestCTX, oauthErrUnauthorizedClient, "public clients can not use client_credentials grant", http.StatusBadRequest)
+			return
+		}
+		scopes := strings.Fields(r.PostForm.Get("scope"))
+		if len(scopes) == 0 {
+			scopes = client.Scopes
+		}
+		for _, s := range scopes {
+			if !containsScope(client.Scopes, s) {
+				setOAuthErr(requestCTX, oauthErrInvalidScope, "scope "+s+" is not allowed for the client", http.StatusBadRequest)
+				return
+			}
+		}
+		claim.ID = client.ID
+		claim.Type = auth.RoleService
+		claim.Scope = strings.Join(scopes, " ")
+	case app.GrantTypeAuthorizationCode:
+		grant, err := a.App.OAuth.ExchangeAuthorizationCode(&app.ExchangeAuthorizationCodeOpts{
+			ClientID:     client.ID,
+			Code:         r.PostForm.Get("code"),
+			RedirectURI:  r.PostForm.Get("redirect_uri"),
+			CodeVerifier: r.PostForm.Get("code_verifier"),
+		})
+		if err != nil {
+			if errors.GetType(err) == errors.BadRequest {
+				setOAuthErr(requestCTX, oauthErrInvalidGrant, err.Error(), http.StatusBadRequest)
+				return
+			}
+			a.Logger.Error().Err(err).Msg("failed to exchange authorization code")
+			setOAuthErr(requestCTX, oauthErrServerError, "", http.StatusInternalServerError)
+			return
+		}
+		claim.ID = grant.UserID
+		claim.Scope = strings.Join(grant.Scopes, " ")
+	}
+
+	token, err := a.TokenAuth.SignToken(&claim)
+	if err != nil {
+		a.Logger.Error().Err(err).Msg("failed to sign oauth access token")
+		setOAuthErr(requestCTX, oauthErrServerError, "", http.StatusInternalServerError)
+		return
+	}
+	resp := OAuthTokenResp{AccessToken: token, TokenType: "Bearer", Scope: claim.Scope}
+	if claim.ExpiresAt != 0 {
+		resp.ExpiresIn = claim.ExpiresAt - time.Now().Unix()
+	}
+	requestCTX.SetRawJSONResponse(&resp, http.StatusOK)
+}
+
+// introspectToken returns whether a token is active and its details to an authenticated confidential client
+func (a *API) introspectToken(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	if err := r.ParseForm(); err != nil {
+		setOAuthErr(requestCTX, oauthErrInvalidRequest, "invalid form", http.StatusBadRequest)
+		return
+	}
+	client, ok := a.authenticateOAuthClient(requestCTX, w, r)
+	if !ok {
+		return
+	}
+	if !client.Confidential {
+		setOAuthErr(requestCTX, oauthErrUnauthorizedClient, "public clients can not introspect tokens", http.StatusBadRequest)
+		return
+	}
+	uc := a.verifyOAuthToken(r.PostForm.Get("token"))
+	if uc == nil {
+		requestCTX.SetRawJSONResponse(&IntrospectionResp{Active: false}, http.StatusOK)
+		return
+	}
+	requestCTX.SetRawJSONResponse(&IntrospectionResp{
+		Active:    true,
+		Scope:     strings.Join(uc.GetPermissions(), " "),
+		ClientID:  uc.ClientID,
+		Subject:   uc.ID,
+		TokenType: "Bearer",
+		ExpiresAt: uc.ExpiresAt,
+		IssuedAt:  uc.IssuedAt,
+		TokenID:   uc.TokenID(),
+	}, http.StatusOK)
+}
+
+// revokeToken revokes an access token issued to the authenticated client.
+// Invalid tokens and tokens of other clients are ignored, the response is always successful (RFC 7009 section 2.2).
+func (a *API) revokeToken(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		setOAuthErr(requestCTX, oauthErrInvalidRequest, "invalid form", http.StatusBadRequest)
+		return
+	}
+	client, ok := a.authenticateOAuthClient(requestCTX, w, r)
+	if !ok {
+		return
+	}
+	if a.Revocations == nil {
+		setOAuthErr(requestCTX, oauthErrUnsupportedTokenType, "token revocation is not configured", http.StatusBadRequest)
+		return
+	}
+	if uc := a.verifyOAuthToken(r.PostForm.Get("token")); uc != nil && uc.ClientID == client.ID {
+		if err := a.Revocations.Revoke(uc); err != nil {
+			a.Logger.Error().Err(err).Msg("failed to revoke oauth access token")
+			setOAuthErr(requestCTX, oauthErrServerError, "", http.StatusInternalServerError)
+			return
+		}
+	}
+	requestCTX.SetRawJSONResponse(struct{}{}, http.StatusOK)
+}
+
+// authenticateOAuthClient authenticates the client using http basic auth or client_id and client_secret form parameters.
+// It sets the error response and returns false if authentication fails.
+func (a *API) authenticateOAuthClient(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) (*app.OAuthClientResp, bool) {
+	id, secret, basic := r.BasicAuth()
+	if basic {
+		// credentials are form encoded before being put in basic auth header, see RFC 6749 section 2.3.1
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if id == "" {
+		setOAuthErr(requestCTX, oauthErrInvalidClient, "client authentication required", http.StatusUnauthorized)
+		return nil, false
+	}
+	client, err := a.App.OAuth.AuthenticateClient(id, secret)
+	if err != nil {
+		if errors.GetType(err) != errors.Unauthorized {
+			a.Logger.Error().Err(err).Msg("failed to authenticate oauth client")
+			setOAuthErr(requestCTX, oauthErrServerError, "", http.StatusInternalServerError)
+			return nil, false
+		}
+		if basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		setOAuthErr(requestCTX, oauthErrInvalidClient, "", http.StatusUnauthorized)
+		return nil, false
+	}
+	return client, true
+}
+
+// verifyOAuthToken returns claim of a valid and not revoked token, otherwise nil
+func (a *API) verifyOAuthToken(token string) *auth.UserClaim {
+	if token == "" {
+		return nil
+	}
+	claim, err := a.TokenAuth.VerifyToken(token)
+	if err != nil {
+		return nil
+	}
+	uc, ok := claim.(*auth.UserClaim)
+	if !ok || uc.MFAPending {
+		return nil
+	}
+	if a.Revocations != nil {
+		if revoked, err := a.Revocations.IsRevoked(uc); err != nil || revoked {
+			return nil
+		}
+	}
+	return uc
+}
+
+// userHasScope returns true if the user can delegate the scope, i.e. the scope is one of the user's permissions
+func (a *API) userHasScope(uc *auth.UserClaim, scope string) bool {
+	policy := a.Policy
+	if policy == nil {
+		policy = auth.NewPolicy()
+	}
+	return policy.HasPermission(uc, scope)
+}
+
+func setOAuthErr(requestCTX *handler.RequestContext, code, description string, statusCode int) {
+	requestCTX.SetRawJSONResponse(&OAuthErrorResp{Error: code, ErrorDescription: description}, statusCode)
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,333 @@
+package api
+
+import (
+	"encoding/json"
+	"go-app/app"
+	"go-app/mock"
+	"go-app/server/auth"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	errors "github.com/vasupal1996/goerror"
+)
+
+func TestAPI_oauthToken(t *testing.T) {
+	api := NewTestAPI(getTestConfig())
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	oauth := mock.NewMockOAuth(ctrl)
+	api.App.OAuth = oauth
+
+	confidential := &app.OAuthClientResp{
+		ID:           "c1",
+		Name:         "reports",
+		Confidential: true,
+		GrantTypes:   []string{app.GrantTypeClientCredentials},
+		Scopes:       []string{"orders:read", "orders:write"},
+	}
+	public := &app.OAuthClientResp{
+		ID:           "c2",
+		Name:         "spa",
+		RedirectURIs: []string{"https://spa.example.com/cb"},
+		GrantTypes:   []string{app.GrantTypeAuthorizationCode},
+		Scopes:       []string{"orders:read"},
+	}
+
+	type TestCase struct {
+		Name        string
+		Form        url.Values
+		BasicAuth   []string
+		Prepare     func()
+		WantCode    int
+		WantError   string
+		WantSubject string
+		WantScope   string
+	}
+
+	tests := []TestCase{
+		{
+			Name:      "missing client",
+			Form:      url.Values{"grant_type": {"client_credentials"}},
+			WantCode:  http.StatusUnauthorized,
+			WantError: oauthErrInvalidClient,
+		},
+		{
+			Name:      "invalid client secret",
+			Form:      url.Values{"grant_type": {"client_credentials"}},
+			BasicAuth: []string{"c1", "wrong"},
+			Prepare: func() {
+				oauth.EXPECT().AuthenticateClient("c1", "wrong").Times(1).Return(nil, errors.New("invalid client", &errors.Unauthorized))
+			},
+			WantCode:  http.StatusUnauthorized,
+			WantError: oauthErrInvalidClient,
+		},
+		{
+			Name:      "client credentials with default scopes",
+			Form:      url.Values{"grant_type": {"client_credentials"}},
+			BasicAuth: []string{"c1", "secret"},
+			Prepare: func() {
+				oauth.EXPECT().AuthenticateClient("c1", "secret").Times(1).Return(confidential, nil)
+			},
+			WantCode:    http.StatusOK,
+			WantSubject: "c1",
+			WantScope:   "orders:read orders:write",
+		},
+		{
+			Name: "client credentials in form with narrower scope",
+			Form: url.Values{"grant_type": {"client_credentials"}, "client_id": {"c1"}, "client_secret": {"secret"}, "scope": {"orders:read"}},
+			Prepare: func() {
+				oauth.EXPECT().AuthenticateClient("c1", "secret").Times(1).Return(confidential, nil)
+			},
+			WantCode:    http.StatusOK,
+			WantSubject: "c1",
+			WantScope:   "orders:read",
+		},
+		{
+			Name:      "scope not allowed for client",
+			Form:      url.Values{"grant_type": {"client_credentials"}, "scope": {"users:write"}},
+			BasicAuth: []string{"c1", "secret"},
+			Prepare: func() {
+				oauth.EXPECT().AuthenticateClient("c1", "secret").Times(1).Return(confidential, nil)
+			},
+			WantCode:  http.StatusBadRequest,
+			WantError: oauthErrInvalidScope,
+		},
+		{
+			Name:      "grant type not allowed for client",
+			Form:      url.Values{"grant_type": {"authorization_code"}, "code": {"abc"}},
+			BasicAuth: []string{"c1", "secret"},
+			Prepare: func() {
+				oauth.EXPECT().AuthenticateClient("c1", "secret").Times(1).Return(confidential, nil)
+			},
+			WantCode:  http.StatusBadRequest,
+			WantError: oauthErrUnauthorizedClient,
+		},
+		{
+			Name:      "unsupported grant type",
+			Form:      url.Values{"grant_type": {"password"}},
+			BasicAuth: []string{"c1", "secret"},
+			Prepare: func() {
+				oauth.EXPECT().AuthenticateClient("c1", "secret").Times(1).Return(confidential, nil)
+			},
+			WantCode:  http.StatusBadRequest,
+			WantError: oauthErrUnsupportedGrantType,
+		},
+		{
+			Name: "authorization code",
+			Form: url.Values{"grant_type": {"authorization_code"}, "client_id": {"c2"}, "code": {"abc"}, "redirect_uri": {"https://spa.example.com/cb"}, "code_verifier": {"verifier"}},
+			Prepare: func() {
+				oauth.EXPECT().AuthenticateClient("c2", "").Times(1).Return(public, nil)
+				oauth.EXPECT().ExchangeAuthorizationCode(gomock.Eq(&app.ExchangeAuthorizationCodeOpts{
+					ClientID: "c2", Code: "abc", RedirectURI: "https://spa.example.com/cb", CodeVerifier: "verifier",
+				})).Times(1).Return(&app.AuthorizationGrant{UserID: "u1", Scopes: []string{"orders:read"}}, nil)
+			},
+			WantCode:    http.StatusOK,
+			WantSubject: "u1",
+			WantScope:   "orders:read",
+		},
+		{
+			Name: "invalid authorization code",
+			Form: url.Values{"grant_type": {"authorization_code"}, "client_id": {"c2"}, "code": {"used"}},
+			Prepare: func() {
+				oauth.EXPECT().AuthenticateClient("c2", "").Times(1).Return(public, nil)
+				oauth.EXPECT().ExchangeAuthorizationCode(gomock.Any()).Times(1).Return(nil, errors.New("invalid authorization code", &errors.BadRequest))
+			},
+			WantCode:  http.StatusBadRequest,
+			WantError: oauthErrInvalidGrant,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			if tt.Prepare != nil {
+				tt.Prepare()
+			}
+			req, _ := http.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(tt.Form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.BasicAuth != nil {
+				req.SetBasicAuth(tt.BasicAuth[0], tt.BasicAuth[1])
+			}
+			recorder := httptest.NewRecorder()
+			api.Router.Root.ServeHTTP(recorder, req)
+			assert.Equal(t, tt.WantCode, recorder.Code)
+			assert.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+			if tt.WantError != "" {
+				resp := OAuthErrorResp{}
+				assert.Nil(t, json.NewDecoder(recorder.Body).Decode(&resp))
+				assert.Equal(t, tt.WantError, resp.Error)
+				return
+			}
+			resp := OAuthTokenResp{}
+			assert.Nil(t, json.NewDecoder(recorder.Body).Decode(&resp))
+			assert.Equal(t, "Bearer", resp.TokenType)
+			assert.Equal(t, tt.WantScope, resp.Scope)
+			assert.Greater(t, resp.ExpiresIn, int64(0))
+			claim, err := api.TokenAuth.VerifyToken(resp.AccessToken)
+			assert.Nil(t, err)
+			uc := claim.(*auth.UserClaim)
+			assert.Equal(t, tt.WantSubject, uc.ID)
+			assert.NotEmpty(t, uc.ClientID)
+			assert.Empty(t, uc.GetRoles())
+			assert.Equal(t, strings.Fields(tt.WantScope), uc.GetPermissions())
+		})
+	}
+}
+
+func TestAPI_oauthAuthorize(t *testing.T) {
+	api := NewTestAPI(getTestConfig())
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	oauth := mock.NewMockOAuth(ctrl)
+	api.App.OAuth = oauth
+
+	userToken, _ := api.TokenAuth.SignToken(&auth.UserClaim{ID: "u1", Type: "user", Permissions: []string{"orders:read"}})
+	oauthToken, _ := api.TokenAuth.SignToken(&auth.UserClaim{ID: "u1", ClientID: "c2", Scope: "orders:read"})
+
+	serve := func(method, target, token string, form url.Values) *httptest.ResponseRecorder {
+		var body *strings.Reader
+		if form != nil {
+			body = strings.NewReader(form.Encode())
+		} else {
+			body = strings.NewReader("")
+		}
+		req, _ := http.NewRequest(method, target, body)
+		if form != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		api.Router.Root.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	q := url.Values{
+		"client_id":             {"c2"},
+		"redirect_uri":          {"https://spa.example.com/cb"},
+		"response_type":         {"code"},
+		"scope":                 {"orders:read orders:write"},
+		"state":                 {"xyz"},
+		"code_challenge":        {"challenge"},
+		"code_challenge_method": {"S256"},
+	}
+	target := "/oauth/authorize?" + q.Encode()
+
+	r := serve(http.MethodGet, target, "", nil)
+	assert.Equal(t, http.StatusUnauthorized, r.Code)
+
+	// oauth tokens can not be used to authorize other clients
+	r = serve(http.MethodGet, target, oauthToken, nil)
+	assert.Equal(t, http.StatusForbidden, r.Code)
+
+	oauth.EXPECT().CreateAuthorizationRequest(gomock.Eq(&app.AuthorizationRequestOpts{
+		UserID:              "u1",
+		ClientID:            "c2",
+		RedirectURI:         "https://spa.example.com/cb",
+		ResponseType:        "code",
+		Scopes:              []string{"orders:read", "orders:write"},
+		State:               "xyz",
+		CodeChallenge:       "challenge",
+		CodeChallengeMethod: "S256",
+	})).Times(1).Return(&app.AuthorizationRequestResp{
+		ID:     "req1",
+		Client: &app.OAuthClientResp{ID: "c2", Name: "<spa>"},
+		Scopes: []string{"orders:read", "orders:write"},
+	}, nil)
+	r = serve(http.MethodGet, target, userToken, nil)
+	assert.Equal(t, http.StatusOK, r.Code)
+	assert.Equal(t, "text/html; charset=utf-8", r.Header().Get("Content-Type"))
+	assert.Equal(t, "DENY", r.Header().Get("X-Frame-Options"))
+	body := r.Body.String()
+	assert.Contains(t, body, "&lt;spa&gt;")
+	assert.Contains(t, body, `name="request_id" value="req1"`)
+	assert.Contains(t, body, `name="scope" value="orders:read"`)
+	// the user does not have orders:write so it can not be delegated
+	assert.NotContains(t, body, `name="scope" value="orders:write"`)
+
+	oauth.EXPECT().CreateAuthorizationRequest(gomock.Any()).Times(1).Return(nil, errors.New("redirect uri is not registered for the client", &errors.BadRequest))
+	r = serve(http.MethodGet, target, userToken, nil)
+	assert.Equal(t, http.StatusBadRequest, r.Code)
+	assert.Empty(t, r.Header().Get("Location"))
+
+	// scopes the user does not have are dropped even if posted
+	oauth.EXPECT().ApproveAuthorizationRequest(gomock.Eq(&app.ApproveAuthorizationOpts{
+		RequestID: "req1",
+		UserID:    "u1",
+		Approved:  true,
+		Scopes:    []string{"orders:read"},
+	})).Times(1).Return("https://spa.example.com/cb?code=abc&state=xyz", nil)
+	r = serve(http.MethodPost, "/oauth/authorize", userToken, url.Values{"request_id": {"req1"}, "approve": {"true"}, "scope": {"orders:read", "orders:write"}})
+	assert.Equal(t, http.StatusSeeOther, r.Code)
+	assert.Equal(t, "https://spa.example.com/cb?code=abc&state=xyz", r.Header().Get("Location"))
+
+	oauth.EXPECT().ApproveAuthorizationRequest(gomock.Eq(&app.ApproveAuthorizationOpts{
+		RequestID: "req2",
+		UserID:    "u1",
+		Approved:  false,
+		Scopes:    []string{},
+	})).Times(1).Return("https://spa.example.com/cb?error=access_denied&state=xyz", nil)
+	r = serve(http.MethodPost, "/oauth/authorize", userToken, url.Values{"request_id": {"req2"}, "approve": {"false"}})
+	assert.Equal(t, http.StatusSeeOther, r.Code)
+	assert.Equal(t, "https://spa.example.com/cb?error=access_denied&state=xyz", r.Header().Get("Location"))
+}
+
+func TestAPI_oauthIntrospectAndRevoke(t *testing.T) {
+	api := NewTestAPI(getTestConfig())
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	oauth := mock.NewMockOAuth(ctrl)
+	api.App.OAuth = oauth
+
+	client := &app.OAuthClientResp{ID: "c1", Confidential: true, GrantTypes: []string{app.GrantTypeClientCredentials}, Scopes: []string{"orders:read"}}
+	oauth.EXPECT().AuthenticateClient("c1", "secret").AnyTimes().Return(client, nil)
+	oauth.EXPECT().AuthenticateClient("c2", "").AnyTimes().Return(&app.OAuthClientResp{ID: "c2"}, nil)
+
+	serve := func(path, clientID, secret string, form url.Values) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(clientID, secret)
+		recorder := httptest.NewRecorder()
+		api.Router.Root.ServeHTTP(recorder, req)
+		return recorder
+	}
+	introspect := func(token string) IntrospectionResp {
+		r := serve("/oauth/introspect", "c1", "secret", url.Values{"token": {token}})
+		assert.Equal(t, http.StatusOK, r.Code)
+		resp := IntrospectionResp{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&resp))
+		return resp
+	}
+
+	own, _ := api.TokenAuth.SignToken(&auth.UserClaim{ID: "c1", ClientID: "c1", Scope: "orders:read"})
+	other, _ := api.TokenAuth.SignToken(&auth.UserClaim{ID: "u1", ClientID: "c3", Scope: "orders:read"})
+
+	resp := introspect(own)
+	assert.True(t, resp.Active)
+	assert.Equal(t, "c1", resp.ClientID)
+	assert.Equal(t, "c1", resp.Subject)
+	assert.Equal(t, "orders:read", resp.Scope)
+	assert.NotZero(t, resp.ExpiresAt)
+	assert.False(t, introspect("invalid").Active)
+
+	// public clients can not introspect
+	r := serve("/oauth/introspect", "c2", "", url.Values{"token": {own}})
+	assert.Equal(t, http.StatusBadRequest, r.Code)
+
+	// tokens of other clients are not revoked but the response is still successful
+	r = serve("/oauth/revoke", "c1", "secret", url.Values{"token": {other}})
+	assert.Equal(t, http.StatusOK, r.Code)
+	assert.True(t, introspect(other).Active)
+
+	r = serve("/oauth/revoke", "c1", "secret", url.Values{"token": {own}})
+	assert.Equal(t, http.StatusOK, r.Code)
+	assert.False(t, introspect(own).Active)
+
+	r = serve("/oauth/revoke", "c1", "secret", url.Values{"token": {"invalid"}})
+	assert.Equal(t, http.StatusOK, r.Code)
+}
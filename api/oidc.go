@@ -0,0 +1,72 @@
+package api
+
+import (
+	goErr "errors"
+	"go-app/app"
+	"go-app/server/auth"
+	"go-app/server/handler"
+	"net/http"
+
+	errors "github.com/vasupal1996/goerror"
+)
+
+// oidcLogin redirects the user to the external identity provider
+func (a *API) oidcLogin(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	if a.OIDC == nil {
+		requestCTX.SetErr(errors.New("oidc login is not enabled", &errors.NotFound), http.StatusNotFound)
+		return
+	}
+	redirect, state, err := a.OIDC.AuthorizationURL()
+	if err != nil {
+		a.Logger.Error().Err(err).Msg("failed to start oidc login")
+		requestCTX.SetErr(errors.Wrap(err, "failed to start oidc login", &errors.SomethingWentWrong), http.StatusInternalServerError)
+		return
+	}
+	// binds the login to this browser so a callback url can not be used to sign in another browser
+	http.SetCookie(w, a.OIDC.StateCookie(state))
+	requestCTX.SetRedirectResponse(redirect, http.StatusFound)
+}
+
+// oidcCallback completes the login at the external identity provider and signs in the local user linked to it
+func (a *API) oidcCallback(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	if a.OIDC == nil {
+		requestCTX.SetErr(errors.New("oidc login is not enabled", &errors.NotFound), http.StatusNotFound)
+		return
+	}
+	q := r.URL.Query()
+	if err := a.OIDC.VerifyStateCookie(r, q.Get("state")); err != nil {
+		requestCTX.SetErr(errors.Wrap(err, "invalid oidc login", &errors.BadRequest), http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, a.OIDC.ClearStateCookie())
+	if e := q.Get("error"); e != "" {
+		requestCTX.SetErr(errors.New("identity provider returned error: "+e, &errors.Unauthorized), http.StatusUnauthorized)
+		return
+	}
+	claims, err := a.OIDC.Exchange(q.Get("state"), q.Get("code"))
+	if err != nil {
+		switch {
+		case goErr.Is(err, auth.ErrInvalidOIDCState):
+			requestCTX.SetErr(errors.Wrap(err, "invalid oidc login", &errors.BadRequest), http.StatusBadRequest)
+		case goErr.Is(err, auth.ErrInvalidIDToken):
+			a.Logger.Warn().Err(err).Msg("oidc id token rejected")
+			requestCTX.SetErr(errors.Wrap(err, "invalid oidc login", &errors.Unauthorized), http.StatusUnauthorized)
+		default:
+			a.Logger.Error().Err(err).Msg("failed to complete oidc login")
+			requestCTX.SetErr(errors.Wrap(err, "failed to complete oidc login", &errors.SomethingWentWrong), http.StatusInternalServerError)
+		}
+		return
+	}
+	claim, err := a.App.User.LoginExternal(&app.ExternalLoginOpts{
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		LinkByEmail:   a.OIDC.Config.LinkByEmail,
+	})
+	if err != nil {
+		requestCTX.SetErr(err, statusCodeFromErr(err))
+		return
+	}
+	a.completeLogin(requestCTX, w, r, claim)
+}
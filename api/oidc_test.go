@@ -0,0 +1,151 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"go-app/app"
+	"go-app/mock"
+	"go-app/server/auth"
+	"go-app/server/config"
+	memorystorage "go-app/server/storage/memory"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	errors "github.com/vasupal1996/goerror"
+)
+
+// newTestOIDCProvider serves discovery, jwks and token endpoints of a provider which accepts any code.
+// The id token carries the nonce of the authorization url passed to the returned authorize func.
+func newTestOIDCProvider(t *testing.T) (*httptest.Server, func(authURL string) url.Values) {
+	priv, _ := rsa.GenerateKey(rand.Reader, 2048)
+	key := &auth.SigningKey{ID: "k1", Method: jwt.SigningMethodRS256, PrivateKey: priv, PublicKey: &priv.PublicKey}
+	nonces := map[string]string{}
+	var srv *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&auth.OIDCDiscovery{
+			Issuer:                srv.URL,
+			AuthorizationEndpoint: srv.URL + "/authorize",
+			TokenEndpoint:         srv.URL + "/token",
+			JWKSURI:               srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		jwk, _ := auth.NewJWK(key)
+		json.NewEncoder(w).Encode(&auth.JWKS{Keys: []*auth.JWK{jwk}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		token := jwt.NewWithClaims(key.Method, &auth.IDTokenClaims{
+			Issuer:        srv.URL,
+			Subject:       "provider-user",
+			Audience:      auth.Audience{"client"},
+			ExpiresAt:     time.Now().Add(time.Hour).Unix(),
+			IssuedAt:      time.Now().Unix(),
+			Nonce:         nonces[r.PostFormValue("code")],
+			Email:         "user@example.com",
+			EmailVerified: true,
+		})
+		token.Header["kid"] = key.ID
+		s, err := token.SignedString(key.PrivateKey)
+		assert.Nil(t, err)
+		json.NewEncoder(w).Encode(map[string]string{"id_token": s})
+	})
+	srv = httptest.NewServer(mux)
+	authorize := func(authURL string) url.Values {
+		u, _ := url.Parse(authURL)
+		code := "code-" + u.Query().Get("state")
+		nonces[code] = u.Query().Get("nonce")
+		return url.Values{"code": {code}, "state": {u.Query().Get("state")}}
+	}
+	return srv, authorize
+}
+
+func TestAPI_oidcLogin(t *testing.T) {
+	provider, authorize := newTestOIDCProvider(t)
+	defer provider.Close()
+
+	api := NewTestAPI(getTestConfig())
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	user := mock.NewMockUser(ctrl)
+	api.App.User = user
+
+	serve := func(target string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		api.Router.Root.ServeHTTP(recorder, req)
+		return recorder
+	}
+	stateCookie := func(r *httptest.ResponseRecorder) *http.Cookie {
+		for _, c := range r.Result().Cookies() {
+			if c.Name == auth.OIDCStateCookieName {
+				return c
+			}
+		}
+		return nil
+	}
+
+	r := serve("/api/auth/oidc/login")
+	assert.Equal(t, http.StatusNotFound, r.Code)
+
+	api.OIDC = auth.NewOIDCClient(memorystorage.NewMemoryStorageWithCleanupInterval(0), &config.OIDCConfig{
+		Issuer:       provider.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "https://app.example.com/api/auth/oidc/callback",
+	})
+
+	r = serve("/api/auth/oidc/login")
+	assert.Equal(t, http.StatusFound, r.Code)
+	location := r.Header().Get("Location")
+	assert.Contains(t, location, provider.URL+"/authorize?")
+	cookie := stateCookie(r)
+	assert.NotNil(t, cookie)
+	assert.True(t, cookie.HttpOnly)
+	callback := authorize(location)
+
+	// callback of a login started in another browser is rejected
+	r = serve("/api/auth/oidc/callback?" + callback.Encode())
+	assert.Equal(t, http.StatusBadRequest, r.Code)
+	other := serve("/api/auth/oidc/login")
+	r = serve("/api/auth/oidc/callback?"+callback.Encode(), stateCookie(other))
+	assert.Equal(t, http.StatusBadRequest, r.Code)
+
+	user.EXPECT().LoginExternal(gomock.Eq(&app.ExternalLoginOpts{
+		Issuer:        provider.URL,
+		Subject:       "provider-user",
+		Email:         "user@example.com",
+		EmailVerified: true,
+	})).Times(1).Return(&auth.UserClaim{ID: "u1", Type: "user"}, nil)
+	r = serve("/api/auth/oidc/callback?"+callback.Encode(), cookie)
+	assert.Equal(t, http.StatusOK, r.Code)
+	assert.True(t, stateCookie(r).MaxAge < 0, "state cookie is cleared")
+	resp := decodeTokenResp(t, r)
+	assert.NotEmpty(t, resp.RefreshToken)
+	claim, err := api.TokenAuth.VerifyToken(resp.Token)
+	assert.Nil(t, err)
+	assert.Equal(t, "u1", claim.(*auth.UserClaim).ID)
+
+	// state can not be replayed
+	r = serve("/api/auth/oidc/callback?"+callback.Encode(), cookie)
+	assert.Equal(t, http.StatusBadRequest, r.Code)
+
+	r = serve("/api/auth/oidc/callback?error=access_denied&state="+callback.Get("state"), cookie)
+	assert.Equal(t, http.StatusUnauthorized, r.Code)
+
+	// local account errors are returned as they are
+	r = serve("/api/auth/oidc/login")
+	user.EXPECT().LoginExternal(gomock.Any()).Times(1).Return(nil, errors.New("email is already registered, sign in with password", &errors.BadRequest))
+	r = serve("/api/auth/oidc/callback?"+authorize(r.Header().Get("Location")).Encode(), stateCookie(r))
+	assert.Equal(t, http.StatusBadRequest, r.Code)
+}
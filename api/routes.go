@@ -4,6 +4,32 @@ package api
 func (a *API) InitRoutes() {
 	a.Router.Root.Handle("/", a.requestHandler(a.home)).Methods("GET")
 	a.Router.Root.Handle("/", a.requestHandler(a.saveHello)).Methods("POST")
+
+	a.Router.Root.Handle("/status/tls", a.requestHandler(a.tlsStatus)).Methods("GET")
+	a.Router.Root.Handle("/.well-known/jwks.json", a.requestHandler(a.jwks)).Methods("GET")
+	a.Router.Root.Handle("/oauth/authorize", a.requestWithAuthHandler(a.authorize)).Methods("GET")
+	a.Router.Root.Handle("/oauth/authorize", a.requestWithAuthHandler(a.approveAuthorization)).Methods("POST")
+	a.Router.Root.Handle("/oauth/token", a.requestHandler(a.oauthToken)).Methods("POST")
+	a.Router.Root.Handle("/oauth/introspect", a.requestHandler(a.introspectToken)).Methods("POST")
+	a.Router.Root.Handle("/oauth/revoke", a.requestHandler(a.revokeToken)).Methods("POST")
+	a.Router.APIRoot.Handle("/auth/register", a.requestHandler(a.register)).Methods("POST")
+	a.Router.APIRoot.Handle("/auth/login", a.requestHandler(a.login)).Methods("POST")
+	a.Router.APIRoot.Handle("/auth/oidc/login", a.requestHandler(a.oidcLogin)).Methods("GET")
+	a.Router.APIRoot.Handle("/auth/oidc/callback", a.requestHandler(a.oidcCallback)).Methods("GET")
+	a.Router.APIRoot.Handle("/auth/password", a.requestWithAuthHandler(a.changePassword)).Methods("PUT")
+	a.Router.APIRoot.Handle("/auth/password/forgot", a.requestHandler(a.forgotPassword)).Methods("POST")
+	a.Router.APIRoot.Handle("/auth/password/reset", a.requestHandler(a.resetPassword)).Methods("POST")
+	a.Router.APIRoot.Handle("/auth/mfa/enroll", a.requestWithSudoHandler(a.enrollMFA)).Methods("POST")
+	a.Router.APIRoot.Handle("/auth/mfa/confirm", a.requestWithSudoHandler(a.confirmMFA)).Methods("POST")
+	a.Router.APIRoot.Handle("/auth/mfa/verify", a.requestWithMFAPendingHandler(a.verifyMFA)).Methods("POST")
+	a.Router.APIRoot.Handle("/auth/refresh", a.requestHandler(a.refreshToken)).Methods("POST")
+	a.Router.APIRoot.Handle("/auth/logout", a.requestWithAuthHandler(a.logout)).Methods("POST")
+
+	a.Router.APIRoot.Handle("/admin/api-keys", a.requestWithSudoHandler(a.createAPIKey)).Methods("POST")
+	a.Router.APIRoot.Handle("/admin/api-keys", a.requestWithSudoHandler(a.listAPIKeys)).Methods("GET")
+	a.Router.APIRoot.Handle("/admin/api-keys/{id}", a.requestWithSudoHandler(a.revokeAPIKey)).Methods("DELETE")
+	a.Router.APIRoot.Handle("/admin/oauth/clients", a.requestWithSudoHandler(a.registerOAuthClient)).Methods("POST")
+	a.Router.APIRoot.Handle("/admin/oauth/clients/{id}", a.requestWithSudoHandler(a.getOAuthClient)).Methods("GET")
 }
 
 // InitTestRoutes := intializing all the testing and development endpoints
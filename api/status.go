@@ -0,0 +1,34 @@
+package api
+
+import (
+	"go-app/server/handler"
+	"net/http"
+
+	errors "github.com/vasupal1996/goerror"
+)
+
+// healthz reports liveness of the process, dependencies are not checked
+func (a *API) healthz(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	requestCTX.SetRawJSONResponse(a.Health.Liveness(), http.StatusOK)
+}
+
+// readyz reports whether the server can serve requests along with result and latency of every dependency check.
+// It fails with 503 when any check fails or once the server is shutting down, errors of the checks are only logged.
+func (a *API) readyz(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	report := a.Health.Readiness(r.Context())
+	if !report.OK() {
+		a.Logger.Warn().Err(report.Err()).Msg("server is not ready")
+		requestCTX.SetRawJSONResponse(report, http.StatusServiceUnavailable)
+		return
+	}
+	requestCTX.SetRawJSONResponse(report, http.StatusOK)
+}
+
+// tlsStatus returns details of the served tls certificate including the days left until it expires
+func (a *API) tlsStatus(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	if a.Certs == nil {
+		requestCTX.SetErr(errors.New("tls is not enabled", &errors.NotFound), http.StatusNotFound)
+		return
+	}
+	requestCTX.SetAppResponse(a.Certs.Status(), http.StatusOK)
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"go-app/server/health"
+	"go-app/server/tlsconfig"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPI_health(t *testing.T) {
+	api := NewTestAPI(getTestConfig())
+	var redisErr error
+	api.Health.Register("redis", health.CheckFunc(func(ctx context.Context) error { return redisErr }))
+	serve := func(target string) (int, *health.Report) {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		api.Router.Root.ServeHTTP(recorder, req)
+		assert.NotContains(t, recorder.Body.String(), "connection refused", "errors of the checks are not exposed")
+		report := &health.Report{}
+		assert.Nil(t, json.NewDecoder(recorder.Body).Decode(report))
+		return recorder.Code, report
+	}
+
+	type TestCase struct {
+		Name         string
+		RedisErr     error
+		ShuttingDown bool
+		Target       string
+		Code         int
+		Status       string
+	}
+	tests := []TestCase{
+		{Name: "ready", Target: "/readyz", Code: http.StatusOK, Status: health.StatusOK},
+		{Name: "failing check", Target: "/readyz", RedisErr: errors.New("connection refused"), Code: http.StatusServiceUnavailable, Status: health.StatusFailing},
+		{Name: "live with failing check", Target: "/healthz", RedisErr: errors.New("connection refused"), Code: http.StatusOK, Status: health.StatusOK},
+		{Name: "shutting down", Target: "/readyz", ShuttingDown: true, Code: http.StatusServiceUnavailable, Status: health.StatusShuttingDown},
+		{Name: "live while shutting down", Target: "/healthz", ShuttingDown: true, Code: http.StatusOK, Status: health.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			redisErr = tt.RedisErr
+			if tt.ShuttingDown {
+				api.Health.SetShuttingDown()
+			}
+			code, report := serve(tt.Target)
+			assert.Equal(t, tt.Code, code)
+			assert.Equal(t, tt.Status, report.Status)
+			if tt.Target == "/readyz" && !tt.ShuttingDown {
+				assert.Len(t, report.Checks, 1)
+				assert.Equal(t, "redis", report.Checks[0].Name)
+			}
+		})
+	}
+}
+
+func TestAPI_tlsStatus(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "api")
+	defer os.RemoveAll(dir)
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(20*24*time.Hour + time.Hour),
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	assert.Nil(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.Nil(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	api := NewTestAPI(getTestConfig())
+	serve := func() *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/status/tls", nil)
+		api.Router.Root.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// tls is not enabled
+	assert.Equal(t, http.StatusNotFound, serve().Code)
+
+	certs, err := tlsconfig.NewCertReloader(certFile, keyFile, api.Logger)
+	assert.Nil(t, err)
+	api.Certs = certs
+	r := serve()
+	assert.Equal(t, http.StatusOK, r.Code)
+	var resp struct {
+		Payload tlsconfig.CertStatus `json:"payload"`
+	}
+	assert.Nil(t, json.NewDecoder(r.Body).Decode(&resp))
+	assert.Equal(t, "CN=localhost", resp.Payload.Subject)
+	assert.Equal(t, 20, resp.Payload.DaysUntilExpiry)
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"go-app/app"
+	"go-app/server/auth"
+	"go-app/server/handler"
+	"net/http"
+
+	errors "github.com/vasupal1996/goerror"
+)
+
+// register creates a new user account
+func (a *API) register(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	opts := app.RegisterOpts{}
+	if err := a.DecodeJSONBody(r, &opts); err != nil {
+		requestCTX.SetErr(err, http.StatusBadRequest)
+		return
+	}
+	if errs := a.Validator.Validate(&opts); errs != nil {
+		requestCTX.SetErrs(errs, http.StatusBadRequest)
+		return
+	}
+	resp, err := a.App.User.Register(&opts)
+	if err != nil {
+		requestCTX.SetErr(err, statusCodeFromErr(err))
+		return
+	}
+	requestCTX.SetAppResponse(resp, http.StatusCreated)
+}
+
+// login verifies credentials and returns a signed token, a refresh token and, if enabled, starts a cookie session
+func (a *API) login(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	opts := app.LoginOpts{}
+	if err := a.DecodeJSONBody(r, &opts); err != nil {
+		requestCTX.SetErr(err, http.StatusBadRequest)
+		return
+	}
+	if errs := a.Validator.Validate(&opts); errs != nil {
+		requestCTX.SetErrs(errs, http.StatusBadRequest)
+		return
+	}
+	claim, err := a.App.User.Login(&opts)
+	if err != nil {
+		requestCTX.SetErr(err, statusCodeFromErr(err))
+		return
+	}
+	a.completeLogin(requestCTX, w, r, claim)
+}
+
+// completeLogin signs in the authenticated user, or responds with a partial token if the user has mfa enabled
+func (a *API) completeLogin(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request, claim *auth.UserClaim) {
+	if a.App.MFA != nil {
+		enabled, err := a.App.MFA.IsEnabled(claim.ID)
+		if err != nil {
+			requestCTX.SetErr(err, statusCodeFromErr(err))
+			return
+		}
+		if enabled {
+			a.signMFAPending(requestCTX, claim)
+			return
+		}
+	}
+	a.signIn(requestCTX, w, r, claim)
+}
+
+// signMFAPending responds with a partial token which is only accepted by the mfa verification endpoint
+func (a *API) signMFAPending(requestCTX *handler.RequestContext, claim *auth.UserClaim) {
+	partial := &auth.UserClaim{ID: claim.ID, Type: claim.Type, Roles: claim.Roles, Permissions: claim.Permissions, MFAPending: true}
+	token, err := a.TokenAuth.SignToken(partial)
+	if err != nil {
+		requestCTX.SetErr(errors.Wrap(err, "failed to sign token", &errors.SomethingWentWrong), http.StatusInternalServerError)
+		return
+	}
+	requestCTX.SetAppResponse(&TokenResp{Token: token, MFARequired: true}, http.StatusOK)
+}
+
+// signIn responds with a signed token and a refresh token for the claim and, if enabled, starts a cookie session
+func (a *API) signIn(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request, claim *auth.UserClaim) {
+	resp := TokenResp{}
+	var err error
+	if resp.Token, err = a.TokenAuth.SignToken(claim); err != nil {
+		requestCTX.SetErr(errors.Wrap(err, "failed to sign token", &errors.SomethingWentWrong), http.StatusInternalServerError)
+		return
+	}
+	if a.RefreshTokens != nil {
+		if resp.RefreshToken, err = a.RefreshTokens.Issue(claim); err != nil {
+			requestCTX.SetErr(errors.Wrap(err, "failed to issue refresh token", &errors.SomethingWentWrong), http.StatusInternalServerError)
+			return
+		}
+	}
+	if a.Sessions != nil {
+		if err := a.Sessions.Create(w, r, claim); err != nil {
+			requestCTX.SetErr(errors.Wrap(err, "failed to create session", &errors.SomethingWentWrong), http.StatusInternalServerError)
+			return
+		}
+	}
+	requestCTX.SetAppResponse(&resp, http.StatusOK)
+}
+
+// changePassword replaces password of the logged in user
+func (a *API) changePassword(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	uc, ok := requestCTX.UserClaim.(*auth.UserClaim)
+	if !ok || uc.ClientID != "" {
+		requestCTX.SetErr(errors.New("password can only be changed by users", &errors.PermissionDenied), http.StatusForbidden)
+		return
+	}
+	opts := app.ChangePasswordOpts{}
+	if err := a.DecodeJSONBody(r, &opts); err != nil {
+		requestCTX.SetErr(err, http.StatusBadRequest)
+		return
+	}
+	if errs := a.Validator.Validate(&opts); errs != nil {
+		requestCTX.SetErrs(errs, http.StatusBadRequest)
+		return
+	}
+	opts.UserID = uc.ID
+	if err := a.App.User.ChangePassword(&opts); err != nil {
+		requestCTX.SetErr(err, statusCodeFromErr(err))
+		return
+	}
+	requestCTX.SetAppResponse("password changed", http.StatusOK)
+}
+
+// forgotPassword sends a password reset token to the user. The response is the same whether the email exists or not.
+func (a *API) forgotPassword(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	opts := app.ForgotPasswordOpts{}
+	if err := a.DecodeJSONBody(r, &opts); err != nil {
+		requestCTX.SetErr(err, http.StatusBadRequest)
+		return
+	}
+	if errs := a.Validator.Validate(&opts); errs != nil {
+		requestCTX.SetErrs(errs, http.StatusBadRequest)
+		return
+	}
+	if err := a.App.User.ForgotPassword(&opts); err != nil {
+		requestCTX.SetErr(err, statusCodeFromErr(err))
+		return
+	}
+	requestCTX.SetAppResponse("if the email is registered, a password reset token has been sent", http.StatusOK)
+}
+
+// resetPassword sets a new password using the reset token
+func (a *API) resetPassword(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+	opts := app.ResetPasswordOpts{}
+	if err := a.DecodeJSONBody(r, &opts); err != nil {
+		requestCTX.SetErr(err, http.StatusBadRequest)
+		return
+	}
+	if errs := a.Validator.Validate(&opts); errs != nil {
+		requestCTX.SetErrs(errs, http.StatusBadRequest)
+		return
+	}
+	if err := a.App.User.ResetPassword(&opts); err != nil {
+		requestCTX.SetErr(err, statusCodeFromErr(err))
+		return
+	}
+	requestCTX.SetAppResponse("password reset", http.StatusOK)
+}
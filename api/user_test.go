@@ -0,0 +1,188 @@
+package api
+
+import (
+	"encoding/json"
+	"go-app/app"
+	"go-app/mock"
+	"go-app/server/auth"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	errors "github.com/vasupal1996/goerror"
+)
+
+func TestAPI_user(t *testing.T) {
+	api := NewTestAPI(getTestConfig())
+	userToken, _ := api.TokenAuth.SignToken(&auth.UserClaim{ID: "60096eb2f03a83b5ae315c78", Type: "user"})
+
+	tests := []struct {
+		name          string
+		method        string
+		url           string
+		token         string
+		body          io.Reader
+		buildStubs    func(u *mock.MockUser)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "Register Invalid Email",
+			method: http.MethodPost,
+			url:    "/api/auth/register",
+			body:   strings.NewReader(`{"email":"abc","password":"password1"}`),
+			buildStubs: func(u *mock.MockUser) {
+				u.EXPECT().Register(gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, r.Code)
+			},
+		},
+		{
+			name:   "Register Short Password",
+			method: http.MethodPost,
+			url:    "/api/auth/register",
+			body:   strings.NewReader(`{"email":"a@b.com","password":"short"}`),
+			buildStubs: func(u *mock.MockUser) {
+				u.EXPECT().Register(gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, r.Code)
+			},
+		},
+		{
+			name:   "Register Existing Email",
+			method: http.MethodPost,
+			url:    "/api/auth/register",
+			body:   strings.NewReader(`{"email":"a@b.com","password":"password1"}`),
+			buildStubs: func(u *mock.MockUser) {
+				u.EXPECT().Register(gomock.Eq(&app.RegisterOpts{Email: "a@b.com", Password: "password1"})).Times(1).
+					Return(nil, errors.New("email is already registered", &errors.BadRequest))
+			},
+			checkResponse: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, r.Code)
+				assert.Contains(t, r.Body.String(), "email is already registered")
+			},
+		},
+		{
+			name:   "Register",
+			method: http.MethodPost,
+			url:    "/api/auth/register",
+			body:   strings.NewReader(`{"email":"a@b.com","password":"password1"}`),
+			buildStubs: func(u *mock.MockUser) {
+				u.EXPECT().Register(gomock.Any()).Times(1).Return(&app.UserResp{Email: "a@b.com", Type: "user"}, nil)
+			},
+			checkResponse: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusCreated, r.Code)
+				assert.NotContains(t, r.Body.String(), "password")
+			},
+		},
+		{
+			name:   "Login Invalid Credentials",
+			method: http.MethodPost,
+			url:    "/api/auth/login",
+			body:   strings.NewReader(`{"email":"a@b.com","password":"wrong"}`),
+			buildStubs: func(u *mock.MockUser) {
+				u.EXPECT().Login(gomock.Any()).Times(1).Return(nil, errors.New("invalid email or password", &errors.Unauthorized))
+			},
+			checkResponse: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, r.Code)
+			},
+		},
+		{
+			name:   "Login",
+			method: http.MethodPost,
+			url:    "/api/auth/login",
+			body:   strings.NewReader(`{"email":"a@b.com","password":"password1"}`),
+			buildStubs: func(u *mock.MockUser) {
+				u.EXPECT().Login(gomock.Eq(&app.LoginOpts{Email: "a@b.com", Password: "password1"})).Times(1).
+					Return(&auth.UserClaim{ID: "1", Type: "user"}, nil)
+			},
+			checkResponse: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, r.Code)
+				resp := struct {
+					Payload TokenResp `json:"payload"`
+				}{}
+				assert.Nil(t, json.NewDecoder(r.Body).Decode(&resp))
+				claim, err := api.TokenAuth.VerifyToken(resp.Payload.Token)
+				assert.Nil(t, err)
+				assert.Equal(t, "1", claim.(*auth.UserClaim).ID)
+				_, rotated, err := api.RefreshTokens.Rotate(resp.Payload.RefreshToken)
+				assert.Nil(t, err)
+				assert.Equal(t, "1", rotated.ID)
+			},
+		},
+		{
+			name:   "Change Password Without Token",
+			method: http.MethodPut,
+			url:    "/api/auth/password",
+			body:   strings.NewReader(`{"old_password":"password1","new_password":"password2"}`),
+			buildStubs: func(u *mock.MockUser) {
+				u.EXPECT().ChangePassword(gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, r.Code)
+			},
+		},
+		{
+			name:   "Change Password",
+			method: http.MethodPut,
+			url:    "/api/auth/password",
+			token:  userToken,
+			body:   strings.NewReader(`{"old_password":"password1","new_password":"password2"}`),
+			buildStubs: func(u *mock.MockUser) {
+				opts := &app.ChangePasswordOpts{UserID: "60096eb2f03a83b5ae315c78", OldPassword: "password1", NewPassword: "password2"}
+				u.EXPECT().ChangePassword(gomock.Eq(opts)).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, r.Code)
+			},
+		},
+		{
+			name:   "Forgot Password",
+			method: http.MethodPost,
+			url:    "/api/auth/password/forgot",
+			body:   strings.NewReader(`{"email":"unknown@b.com"}`),
+			buildStubs: func(u *mock.MockUser) {
+				u.EXPECT().ForgotPassword(gomock.Eq(&app.ForgotPasswordOpts{Email: "unknown@b.com"})).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, r.Code)
+			},
+		},
+		{
+			name:   "Reset Password Invalid Token",
+			method: http.MethodPost,
+			url:    "/api/auth/password/reset",
+			body:   strings.NewReader(`{"token":"abc","password":"password2"}`),
+			buildStubs: func(u *mock.MockUser) {
+				u.EXPECT().ResetPassword(gomock.Any()).Times(1).Return(errors.New("invalid or expired reset token", &errors.BadRequest))
+			},
+			checkResponse: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, r.Code)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			u := mock.NewMockUser(ctrl)
+			tt.buildStubs(u)
+			api.App.User = u
+
+			recorder := httptest.NewRecorder()
+			req, err := http.NewRequest(tt.method, tt.url, tt.body)
+			assert.Nil(t, err)
+			if tt.token != "" {
+				req.Header.Set("Authorization", tt.token)
+			}
+			api.Router.Root.ServeHTTP(recorder, req)
+			tt.checkResponse(t, recorder)
+		})
+	}
+}
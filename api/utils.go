@@ -90,3 +90,19 @@ func (a *API) DecodeJSONBody(r *http.Request, dst interface{}) error {
 	}
 	return nil
 }
+
+// statusCodeFromErr returns http status code matching the goerror type of err
+func statusCodeFromErr(err error) int {
+	switch errors.GetType(err) {
+	case errors.BadRequest:
+		return http.StatusBadRequest
+	case errors.NotFound:
+		return http.StatusNotFound
+	case errors.Unauthorized:
+		return http.StatusUnauthorized
+	case errors.PermissionDenied:
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
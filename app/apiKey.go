@@ -0,0 +1,203 @@
+//go:generate $GOPATH/bin/mockgen -destination=../mock/mock_apiKey.go -package=mock go-app/app APIKey
+
+package app
+
+import (
+	"context"
+	"go-app/server/auth"
+	"time"
+
+	"github.com/rs/zerolog"
+	errors "github.com/vasupal1996/goerror"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// apiKeyCollection stores api keys
+const apiKeyCollection = "api_key"
+
+// apiKeyLastUsedResolution limits how often last used time of a key is written to the database
+const apiKeyLastUsedResolution = time.Minute
+
+// APIKey defines methods of api key service to be implemented
+type APIKey interface {
+	CreateAPIKey(*CreateAPIKeyOpts) (*CreateAPIKeyResp, error)
+	ListAPIKeys() ([]APIKeyResp, error)
+	RevokeAPIKey(string) error
+	VerifyAPIKey(string) (auth.Claim, error)
+}
+
+// APIKeyOpts contains arguments to be accepted for new instance of api key service
+type APIKeyOpts struct {
+	App    *App
+	DB     *mongo.Database
+	Logger *zerolog.Logger
+}
+
+// APIKeyImpl implements api key service
+type APIKeyImpl struct {
+	App    *App
+	DB     *mongo.Database
+	Logger *zerolog.Logger
+}
+
+// InitAPIKey returns initializes api key service
+func InitAPIKey(opts *APIKeyOpts) APIKey {
+	k := &APIKeyImpl{
+		App:    opts.App,
+		DB:     opts.DB,
+		Logger: opts.Logger,
+	}
+	_, err := k.DB.Collection(apiKeyCollection).Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.M{"hash": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		k.Logger.Error().Err(err).Msg("failed to create api key index")
+	}
+	return k
+}
+
+// apiKeyModel is the api key document stored in the database. Only the hash of the key is stored.
+type apiKeyModel struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	Name       string             `bson:"name"`
+	Prefix     string             `bson:"prefix"`
+	Hash       string             `bson:"hash"`
+	Scopes     []string           `bson:"scopes"`
+	CreatedBy  string             `bson:"created_by,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at"`
+	ExpiresAt  *time.Time         `bson:"expires_at,omitempty"`
+	LastUsedAt *time.Time         `bson:"last_used_at,omitempty"`
+	RevokedAt  *time.Time         `bson:"revoked_at,omitempty"`
+}
+
+func (m *apiKeyModel) toResp() APIKeyResp {
+	return APIKeyResp{
+		ID:         m.ID,
+		Name:       m.Name,
+		Prefix:     m.Prefix,
+		Scopes:     m.Scopes,
+		CreatedBy:  m.CreatedBy,
+		CreatedAt:  m.CreatedAt,
+		ExpiresAt:  m.ExpiresAt,
+		LastUsedAt: m.LastUsedAt,
+		RevokedAt:  m.RevokedAt,
+	}
+}
+
+// CreateAPIKeyOpts contains name, scopes and optional expiry of a new api key
+type CreateAPIKeyOpts struct {
+	Name      string     `json:"name" validate:"required"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,required"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedBy string     `json:"-"`
+}
+
+// APIKeyResp returns api key details without the key itself
+type APIKeyResp struct {
+	ID         primitive.ObjectID `json:"id"`
+	Name       string             `json:"name"`
+	Prefix     string             `json:"prefix"`
+	Scopes     []string           `json:"scopes"`
+	CreatedBy  string             `json:"created_by,omitempty"`
+	CreatedAt  time.Time          `json:"created_at"`
+	ExpiresAt  *time.Time         `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time         `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time         `json:"revoked_at,omitempty"`
+}
+
+// CreateAPIKeyResp returns the newly created key. The key is only ever returned here.
+type CreateAPIKeyResp struct {
+	APIKeyResp
+	Key string `json:"key"`
+}
+
+// CreateAPIKey generates a new api key and saves its hash in the database
+func (k *APIKeyImpl) CreateAPIKey(opts *CreateAPIKeyOpts) (*CreateAPIKeyResp, error) {
+	now := time.Now().UTC()
+	if opts.ExpiresAt != nil && !opts.ExpiresAt.After(now) {
+		return nil, errors.New("expires_at must be in future", &errors.BadRequest)
+	}
+	key, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate api key", &errors.SomethingWentWrong)
+	}
+	m := apiKeyModel{
+		Name:      opts.Name,
+		Prefix:    key[:12],
+		Hash:      hash,
+		Scopes:    opts.Scopes,
+		CreatedBy: opts.CreatedBy,
+		CreatedAt: now,
+		ExpiresAt: opts.ExpiresAt,
+	}
+	res, err := k.DB.Collection(apiKeyCollection).InsertOne(context.TODO(), &m)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to save api key", &errors.DBError)
+	}
+	m.ID = res.InsertedID.(primitive.ObjectID)
+	return &CreateAPIKeyResp{APIKeyResp: m.toResp(), Key: key}, nil
+}
+
+// ListAPIKeys returns all the api keys, newest first
+func (k *APIKeyImpl) ListAPIKeys() ([]APIKeyResp, error) {
+	ctx := context.TODO()
+	cur, err := k.DB.Collection(apiKeyCollection).Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list api keys", &errors.DBError)
+	}
+	var keys []apiKeyModel
+	if err := cur.All(ctx, &keys); err != nil {
+		return nil, errors.Wrap(err, "failed to list api keys", &errors.DBError)
+	}
+	resp := make([]APIKeyResp, 0, len(keys))
+	for i := range keys {
+		resp = append(resp, keys[i].toResp())
+	}
+	return resp, nil
+}
+
+// RevokeAPIKey revokes the api key with the given id
+func (k *APIKeyImpl) RevokeAPIKey(id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid api key id", &errors.BadRequest)
+	}
+	filter := bson.M{"_id": oid, "revoked_at": bson.M{"$exists": false}}
+	res, err := k.DB.Collection(apiKeyCollection).UpdateOne(context.TODO(), filter, bson.M{"$set": bson.M{"revoked_at": time.Now().UTC()}})
+	if err != nil {
+		return errors.Wrap(err, "failed to revoke api key", &errors.DBError)
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("api key not found", &errors.NotFound)
+	}
+	return nil
+}
+
+// VerifyAPIKey returns claim of the api key if it exists, is not revoked and has not expired
+func (k *APIKeyImpl) VerifyAPIKey(key string) (auth.Claim, error) {
+	if !auth.IsAPIKey(key) {
+		return nil, auth.ErrInvalidAPIKey
+	}
+	ctx := context.TODO()
+	m := apiKeyModel{}
+	if err := k.DB.Collection(apiKeyCollection).FindOne(ctx, bson.M{"hash": auth.HashAPIKey(key)}).Decode(&m); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, auth.ErrInvalidAPIKey
+		}
+		return nil, errors.Wrap(err, "failed to find api key", &errors.DBError)
+	}
+	now := time.Now().UTC()
+	if m.RevokedAt != nil || (m.ExpiresAt != nil && !m.ExpiresAt.After(now)) {
+		return nil, auth.ErrInvalidAPIKey
+	}
+	if m.LastUsedAt == nil || now.Sub(*m.LastUsedAt) >= apiKeyLastUsedResolution {
+		if _, err := k.DB.Collection(apiKeyCollection).UpdateOne(ctx, bson.M{"_id": m.ID}, bson.M{"$set": bson.M{"last_used_at": now}}); err != nil {
+			k.Logger.Error().Err(err).Str("api_key", m.ID.Hex()).Msg("failed to update api key last used time")
+		}
+	}
+	return &auth.APIKeyClaim{ID: m.ID.Hex(), Name: m.Name, Scopes: m.Scopes, ExpiresAt: m.ExpiresAt}, nil
+}
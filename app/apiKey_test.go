@@ -0,0 +1,80 @@
+package app
+
+import (
+	"go-app/server/auth"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeyImpl_VerifyAPIKey(t *testing.T) {
+	app := NewTestApp(getTestConfig())
+	defer CleanTestApp(app)
+	k := InitAPIKey(&APIKeyOpts{App: app, DB: app.MongoDB.Client.Database(app.Config.APIKeyConfig.DBName), Logger: app.Logger})
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	valid, err := k.CreateAPIKey(&CreateAPIKeyOpts{Name: "valid", Scopes: []string{"orders:read"}, ExpiresAt: &future})
+	assert.Nil(t, err)
+	revoked, err := k.CreateAPIKey(&CreateAPIKeyOpts{Name: "revoked", Scopes: []string{"orders:read"}})
+	assert.Nil(t, err)
+	assert.Nil(t, k.RevokeAPIKey(revoked.ID.Hex()))
+	_, err = k.CreateAPIKey(&CreateAPIKeyOpts{Name: "expired", Scopes: []string{"orders:read"}, ExpiresAt: &past})
+	assert.NotNil(t, err)
+
+	tests := []struct {
+		name    string
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Valid Key",
+			key:  valid.Key,
+			want: "valid",
+		},
+		{
+			name:    "Revoked Key",
+			key:     revoked.Key,
+			wantErr: true,
+		},
+		{
+			name:    "Unknown Key",
+			key:     "gak_unknown",
+			wantErr: true,
+		},
+		{
+			name:    "Malformed Key",
+			key:     "abc",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := k.VerifyAPIKey(tt.key)
+			if tt.wantErr {
+				assert.Equal(t, auth.ErrInvalidAPIKey, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got.(*auth.APIKeyClaim).Name)
+			assert.Equal(t, []string{"orders:read"}, got.GetPermissions())
+		})
+	}
+
+	keys, err := k.ListAPIKeys()
+	assert.Nil(t, err)
+	assert.Len(t, keys, 2)
+	for _, key := range keys {
+		switch key.Name {
+		case "valid":
+			assert.NotNil(t, key.LastUsedAt)
+			assert.Nil(t, key.RevokedAt)
+		case "revoked":
+			assert.Nil(t, key.LastUsedAt)
+			assert.NotNil(t, key.RevokedAt)
+		}
+	}
+	assert.NotNil(t, k.RevokeAPIKey(revoked.ID.Hex()))
+}
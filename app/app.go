@@ -2,6 +2,7 @@ package app
 
 import (
 	"go-app/server/config"
+	"go-app/server/health"
 	mongostorage "go-app/server/storage/mongodb"
 
 	"github.com/rs/zerolog"
@@ -12,6 +13,8 @@ type Options struct {
 	MongoDB *mongostorage.MongoStorage
 	Logger  *zerolog.Logger
 	Config  *config.APPConfig
+	Health  *health.Registry
+	Revoker CredentialRevoker
 }
 
 // App := contains resources to implement business logic
@@ -19,9 +22,17 @@ type App struct {
 	MongoDB *mongostorage.MongoStorage
 	Logger  *zerolog.Logger
 	Config  *config.APPConfig
+	// Health is used by services to register readiness checks of their own dependencies, e.g. a third party api
+	Health *health.Registry
+	// Revoker revokes credentials of users, e.g. when their password is changed
+	Revoker CredentialRevoker
 
 	// List of services this app is implementing
 	Example Example
+	APIKey  APIKey
+	User    User
+	MFA     MFA
+	OAuth   OAuth
 }
 
 // NewApp returns new app instance
@@ -30,5 +41,7 @@ func NewApp(opts *Options) *App {
 		MongoDB: opts.MongoDB,
 		Logger:  opts.Logger,
 		Config:  opts.Config,
+		Health:  opts.Health,
+		Revoker: opts.Revoker,
 	}
 }
@@ -5,6 +5,7 @@ import (
 	"go-app/server/config"
 	"go-app/server/logger"
 	mongostorage "go-app/server/storage/mongodb"
+	"log"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -12,7 +13,10 @@ import (
 
 // NewTestApp returns app instance for testing
 func NewTestApp(c *config.Config) *App {
-	m := mongostorage.NewMongoStorage(&c.DatabaseConfig)
+	m, err := mongostorage.NewMongoStorage(&c.DatabaseConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
 	l := logger.NewLogger(nil, logger.NewZeroLogConsoleWriter(logger.NewStandardConsoleWriter()), nil)
 	a := &App{
 		MongoDB: m,
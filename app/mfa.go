@@ -0,0 +1,286 @@
+//go:generate $GOPATH/bin/mockgen -destination=../mock/mock_mfa.go -package=mock go-app/app MFA
+
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"go-app/server/auth"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	errors "github.com/vasupal1996/goerror"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mfaCollection stores totp secrets and recovery codes of the users
+const mfaCollection = "mfa"
+
+// DefaultMFAIssuer is used when MFAConfig.Issuer is not set
+const DefaultMFAIssuer = "go-app"
+
+// mfa verification settings
+const (
+	mfaRecoveryCodeCount   = 10
+	mfaMaxFailedAttempts   = 5
+	mfaLockDuration        = 15 * time.Minute
+	mfaRecoveryCodeLength  = 10
+	mfaRecoveryCodeGroupAt = 5
+)
+
+var errInvalidMFACode = errors.New("invalid mfa code", &errors.Unauthorized)
+
+// MFA defines methods of multi-factor authentication service to be implemented
+type MFA interface {
+	ConfirmEnrollment(*ConfirmMFAOpts) (*ConfirmMFAResp, error)
+	Enroll(*EnrollMFAOpts) (*EnrollMFAResp, error)
+	IsEnabled(string) (bool, error)
+	Verify(*VerifyMFAOpts) error
+}
+
+// MFAOpts contains arguments to be accepted for new instance of mfa service
+type MFAOpts struct {
+	App    *App
+	DB     *mongo.Database
+	Logger *zerolog.Logger
+}
+
+// MFAImpl implements mfa service using TOTP authenticators and single use recovery codes
+type MFAImpl struct {
+	App    *App
+	DB     *mongo.Database
+	Logger *zerolog.Logger
+
+	issuer string
+	now    func() time.Time
+}
+
+// InitMFA returns initializes mfa service
+func InitMFA(opts *MFAOpts) MFA {
+	m := &MFAImpl{
+		App:    opts.App,
+		DB:     opts.DB,
+		Logger: opts.Logger,
+		issuer: DefaultMFAIssuer,
+		now:    time.Now,
+	}
+	if opts.App != nil && opts.App.Config != nil && opts.App.Config.MFAConfig.Issuer != "" {
+		m.issuer = opts.App.Config.MFAConfig.Issuer
+	}
+	return m
+}
+
+// mfaModel is the mfa document of a user, its id is the user id. Recovery codes are stored hashed.
+type mfaModel struct {
+	UserID         string     `bson:"_id"`
+	Secret         string     `bson:"secret"`
+	Enabled        bool       `bson:"enabled"`
+	RecoveryCodes  []string   `bson:"recovery_codes,omitempty"`
+	LastCounter    int64      `bson:"last_counter"`
+	FailedAttempts int        `bson:"failed_attempts"`
+	LockedUntil    *time.Time `bson:"locked_until,omitempty"`
+	CreatedAt      time.Time  `bson:"created_at"`
+	EnabledAt      *time.Time `bson:"enabled_at,omitempty"`
+}
+
+// EnrollMFAOpts contains the user starting enrollment and the account name shown by authenticator apps
+type EnrollMFAOpts struct {
+	UserID  string
+	Account string
+}
+
+// EnrollMFAResp returns the totp secret and its otpauth uri to be shown as a qr code
+type EnrollMFAResp struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// Enroll generates a new totp secret for the user. MFA is enabled only after the enrollment is confirmed with a code.
+func (m *MFAImpl) Enroll(opts *EnrollMFAOpts) (*EnrollMFAResp, error) {
+	doc, err := m.find(opts.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if doc != nil && doc.Enabled {
+		return nil, errors.New("mfa is already enabled", &errors.BadRequest)
+	}
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate totp secret", &errors.SomethingWentWrong)
+	}
+	doc = &mfaModel{UserID: opts.UserID, Secret: secret, CreatedAt: m.now().UTC()}
+	_, err = m.DB.Collection(mfaCollection).ReplaceOne(context.TODO(), bson.M{"_id": opts.UserID}, doc, options.Replace().SetUpsert(true))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to save totp secret", &errors.DBError)
+	}
+	return &EnrollMFAResp{Secret: secret, URI: auth.TOTPURI(m.issuer, opts.Account, secret)}, nil
+}
+
+// ConfirmMFAOpts contains a code generated by the authenticator app the user enrolled
+type ConfirmMFAOpts struct {
+	UserID string `json:"-"`
+	Code   string `json:"code" validate:"required"`
+}
+
+// ConfirmMFAResp returns recovery codes. They are only ever returned here.
+type ConfirmMFAResp struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// ConfirmEnrollment enables mfa if code is valid for the enrolled secret and generates recovery codes
+func (m *MFAImpl) ConfirmEnrollment(opts *ConfirmMFAOpts) (*ConfirmMFAResp, error) {
+	doc, err := m.find(opts.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, errors.New("mfa enrollment not started", &errors.BadRequest)
+	}
+	if doc.Enabled {
+		return nil, errors.New("mfa is already enabled", &errors.BadRequest)
+	}
+	counter, ok := auth.ValidateTOTP(doc.Secret, opts.Code, m.now(), doc.LastCounter)
+	if !ok {
+		return nil, errInvalidMFACode
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate recovery codes", &errors.SomethingWentWrong)
+	}
+	update := bson.M{"$set": bson.M{"enabled": true, "enabled_at": m.now().UTC(), "last_counter": counter, "recovery_codes": hashes}}
+	if _, err := m.DB.Collection(mfaCollection).UpdateOne(context.TODO(), bson.M{"_id": opts.UserID, "enabled": false}, update); err != nil {
+		return nil, errors.Wrap(err, "failed to enable mfa", &errors.DBError)
+	}
+	return &ConfirmMFAResp{RecoveryCodes: codes}, nil
+}
+
+// IsEnabled returns true if the user confirmed mfa enrollment
+func (m *MFAImpl) IsEnabled(userID string) (bool, error) {
+	doc, err := m.find(userID)
+	if err != nil {
+		return false, err
+	}
+	return doc != nil && doc.Enabled, nil
+}
+
+// VerifyMFAOpts contains the second login step code, either a totp code or a recovery code
+type VerifyMFAOpts struct {
+	UserID string `json:"-"`
+	Code   string `json:"code" validate:"required"`
+}
+
+// Verify checks a totp code or consumes a recovery code of the user.
+// Verification is locked for a while after too many failed attempts.
+func (m *MFAImpl) Verify(opts *VerifyMFAOpts) error {
+	doc, err := m.find(opts.UserID)
+	if err != nil {
+		return err
+	}
+	if doc == nil || !doc.Enabled {
+		return errors.New("mfa is not enabled", &errors.BadRequest)
+	}
+	now := m.now().UTC()
+	errLocked := errors.New("too many failed mfa attempts, try again later", &errors.PermissionDenied)
+	if doc.LockedUntil != nil && doc.LockedUntil.After(now) {
+		return errLocked
+	}
+
+	// the attempt is counted atomically before the code is checked, so concurrent guesses can not exceed the limit
+	ctx := context.TODO()
+	coll := m.DB.Collection(mfaCollection)
+	unlocked := bson.M{
+		"_id":     opts.UserID,
+		"enabled": true,
+		"$or":     bson.A{bson.M{"locked_until": bson.M{"$exists": false}}, bson.M{"locked_until": bson.M{"$lte": now}}},
+	}
+	after := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	doc = &mfaModel{}
+	if err := coll.FindOneAndUpdate(ctx, unlocked, bson.M{"$inc": bson.M{"failed_attempts": 1}}, after).Decode(doc); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return errLocked
+		}
+		return errors.Wrap(err, "failed to update mfa", &errors.DBError)
+	}
+	if doc.FailedAttempts > mfaMaxFailedAttempts {
+		// the lock is being set by the attempt which reached the limit
+		return errLocked
+	}
+
+	reset := bson.M{"$set": bson.M{"failed_attempts": 0}, "$unset": bson.M{"locked_until": ""}}
+	if counter, ok := auth.ValidateTOTP(doc.Secret, opts.Code, now, doc.LastCounter); ok {
+		// filtering on last counter makes sure a code is accepted only once even for concurrent requests
+		filter := bson.M{"_id": opts.UserID, "last_counter": bson.M{"$lt": counter}}
+		update := bson.M{"$set": bson.M{"last_counter": counter, "failed_attempts": 0}, "$unset": bson.M{"locked_until": ""}}
+		res, err := coll.UpdateOne(ctx, filter, update)
+		if err != nil {
+			return errors.Wrap(err, "failed to update mfa", &errors.DBError)
+		}
+		if res.ModifiedCount == 1 {
+			return nil
+		}
+	} else if hash := hashRecoveryCode(opts.Code); hash != "" {
+		res, err := coll.UpdateOne(ctx, bson.M{"_id": opts.UserID, "recovery_codes": hash}, bson.M{"$pull": bson.M{"recovery_codes": hash}})
+		if err != nil {
+			return errors.Wrap(err, "failed to update mfa", &errors.DBError)
+		}
+		if res.ModifiedCount == 1 {
+			if _, err := coll.UpdateOne(ctx, bson.M{"_id": opts.UserID}, reset); err != nil {
+				m.Logger.Error().Err(err).Str("user", opts.UserID).Msg("failed to reset mfa failed attempts")
+			}
+			m.Logger.Info().Str("user", opts.UserID).Msg("mfa recovery code used")
+			return nil
+		}
+	}
+
+	if doc.FailedAttempts >= mfaMaxFailedAttempts {
+		lock := bson.M{"$set": bson.M{"failed_attempts": 0, "locked_until": now.Add(mfaLockDuration)}}
+		if _, err := coll.UpdateOne(ctx, bson.M{"_id": opts.UserID}, lock); err != nil {
+			return errors.Wrap(err, "failed to update mfa", &errors.DBError)
+		}
+	}
+	return errInvalidMFACode
+}
+
+// find returns nil without error if user has not started enrollment
+func (m *MFAImpl) find(userID string) (*mfaModel, error) {
+	doc := mfaModel{}
+	if err := m.DB.Collection(mfaCollection).FindOne(context.TODO(), bson.M{"_id": userID}).Decode(&doc); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to find mfa", &errors.DBError)
+	}
+	return &doc, nil
+}
+
+// generateRecoveryCodes returns recovery codes formatted as xxxxx-xxxxx and their hashes
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, mfaRecoveryCodeCount)
+	hashes := make([]string, 0, mfaRecoveryCodeCount)
+	for i := 0; i < mfaRecoveryCodeCount; i++ {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))[:mfaRecoveryCodeLength]
+		codes = append(codes, code[:mfaRecoveryCodeGroupAt]+"-"+code[mfaRecoveryCodeGroupAt:])
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode returns empty string if code can not be a recovery code
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if len(code) != mfaRecoveryCodeLength {
+		return ""
+	}
+	h := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(h[:])
+}
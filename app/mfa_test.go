@@ -0,0 +1,88 @@
+package app
+
+import (
+	"go-app/server/auth"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMFAImpl(t *testing.T) {
+	app := NewTestApp(getTestConfig())
+	defer CleanTestApp(app)
+	now := time.Now()
+	m := InitMFA(&MFAOpts{App: app, DB: app.MongoDB.Client.Database(app.Config.MFAConfig.DBName), Logger: app.Logger}).(*MFAImpl)
+	m.now = func() time.Time { return now }
+
+	enabled, err := m.IsEnabled("1")
+	assert.Nil(t, err)
+	assert.False(t, enabled)
+	assert.NotNil(t, m.Verify(&VerifyMFAOpts{UserID: "1", Code: "123456"}))
+
+	enroll, err := m.Enroll(&EnrollMFAOpts{UserID: "1", Account: "admin@example.com"})
+	assert.Nil(t, err)
+	assert.Contains(t, enroll.URI, "secret="+enroll.Secret)
+	_, err = m.ConfirmEnrollment(&ConfirmMFAOpts{UserID: "1", Code: "000000"})
+	assert.NotNil(t, err)
+	code, _ := auth.TOTPCode(enroll.Secret, now)
+	confirm, err := m.ConfirmEnrollment(&ConfirmMFAOpts{UserID: "1", Code: code})
+	assert.Nil(t, err)
+	assert.Len(t, confirm.RecoveryCodes, mfaRecoveryCodeCount)
+	enabled, _ = m.IsEnabled("1")
+	assert.True(t, enabled)
+	_, err = m.Enroll(&EnrollMFAOpts{UserID: "1"})
+	assert.NotNil(t, err)
+
+	// code used for confirmation can not be used again
+	assert.NotNil(t, m.Verify(&VerifyMFAOpts{UserID: "1", Code: code}))
+	next, _ := auth.TOTPCode(enroll.Secret, now.Add(auth.TOTPPeriod))
+	m.now = func() time.Time { return now.Add(auth.TOTPPeriod) }
+	assert.Nil(t, m.Verify(&VerifyMFAOpts{UserID: "1", Code: next}))
+
+	// recovery codes are single use and accepted in upper case
+	recovery := confirm.RecoveryCodes[0]
+	assert.Nil(t, m.Verify(&VerifyMFAOpts{UserID: "1", Code: strings.ToUpper(recovery)}))
+	assert.NotNil(t, m.Verify(&VerifyMFAOpts{UserID: "1", Code: recovery}))
+	assert.Nil(t, m.Verify(&VerifyMFAOpts{UserID: "1", Code: confirm.RecoveryCodes[1]}))
+
+	// verification is locked after too many failures
+	for i := 0; i < mfaMaxFailedAttempts; i++ {
+		assert.Equal(t, errInvalidMFACode, m.Verify(&VerifyMFAOpts{UserID: "1", Code: "000000"}))
+	}
+	err = m.Verify(&VerifyMFAOpts{UserID: "1", Code: confirm.RecoveryCodes[2]})
+	assert.Contains(t, err.Error(), "too many failed mfa attempts")
+	m.now = func() time.Time { return now.Add(mfaLockDuration + time.Minute) }
+	assert.Nil(t, m.Verify(&VerifyMFAOpts{UserID: "1", Code: confirm.RecoveryCodes[2]}))
+}
+
+func TestMFAImpl_ConcurrentGuesses(t *testing.T) {
+	app := NewTestApp(getTestConfig())
+	defer CleanTestApp(app)
+	now := time.Now()
+	m := InitMFA(&MFAOpts{App: app, DB: app.MongoDB.Client.Database(app.Config.MFAConfig.DBName), Logger: app.Logger}).(*MFAImpl)
+	m.now = func() time.Time { return now }
+	enroll, _ := m.Enroll(&EnrollMFAOpts{UserID: "2", Account: "admin@example.com"})
+	code, _ := auth.TOTPCode(enroll.Secret, now)
+	_, err := m.ConfirmEnrollment(&ConfirmMFAOpts{UserID: "2", Code: code})
+	assert.Nil(t, err)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	checked := 0
+	for i := 0; i < 4*mfaMaxFailedAttempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if m.Verify(&VerifyMFAOpts{UserID: "2", Code: "000000"}) == errInvalidMFACode {
+				mu.Lock()
+				checked++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, mfaMaxFailedAttempts, checked, "codes of concurrent attempts are checked only up to the limit")
+}
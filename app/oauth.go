@@ -0,0 +1,451 @@
+//go:generate $GOPATH/bin/mockgen -destination=../mock/mock_oauth.go -package=mock go-app/app OAuth
+
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"net/url"
+	"time"
+
+	"github.com/rs/zerolog"
+	errors "github.com/vasupal1996/goerror"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// collections of oauth service
+const (
+	oauthClientCollection = "oauth_client"
+	oauthCodeCollection   = "oauth_code"
+)
+
+// oauth grant types supported by the token endpoint
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeClientCredentials = "client_credentials"
+)
+
+// PKCECodeChallengeMethodS256 is the only supported PKCE method, plain is rejected
+const PKCECodeChallengeMethodS256 = "S256"
+
+// default lifetimes used when they are not set in OAuthConfig
+const (
+	DefaultAuthorizationCodeExpiresAt    = time.Minute
+	DefaultAuthorizationRequestExpiresAt = 10 * time.Minute
+)
+
+// errInvalidGrant is returned for every invalid, expired or already used authorization code
+var errInvalidGrant = errors.New("invalid authorization code", &errors.BadRequest)
+
+// OAuth defines methods of oauth2 authorization server service to be implemented
+type OAuth interface {
+	ApproveAuthorizationRequest(*ApproveAuthorizationOpts) (string, error)
+	AuthenticateClient(string, string) (*OAuthClientResp, error)
+	CreateAuthorizationRequest(*AuthorizationRequestOpts) (*AuthorizationRequestResp, error)
+	ExchangeAuthorizationCode(*ExchangeAuthorizationCodeOpts) (*AuthorizationGrant, error)
+	GetClient(string) (*OAuthClientResp, error)
+	RegisterClient(*RegisterClientOpts) (*RegisterClientResp, error)
+}
+
+// OAuthOpts contains arguments to be accepted for new instance of oauth service
+type OAuthOpts struct {
+	App    *App
+	DB     *mongo.Database
+	Logger *zerolog.Logger
+}
+
+// OAuthImpl implements oauth service
+type OAuthImpl struct {
+	App    *App
+	DB     *mongo.Database
+	Logger *zerolog.Logger
+
+	codeExpiresAt time.Duration
+	now           func() time.Time
+}
+
+// InitOAuth returns initializes oauth service
+func InitOAuth(opts *OAuthOpts) OAuth {
+	o := &OAuthImpl{
+		App:           opts.App,
+		DB:            opts.DB,
+		Logger:        opts.Logger,
+		codeExpiresAt: DefaultAuthorizationCodeExpiresAt,
+		now:           time.Now,
+	}
+	if opts.App != nil && opts.App.Config != nil && opts.App.Config.OAuthConfig.CodeExpiresAt != 0 {
+		o.codeExpiresAt = time.Duration(opts.App.Config.OAuthConfig.CodeExpiresAt) * time.Second
+	}
+	// expired authorization requests and codes are removed by mongodb
+	_, err := o.DB.Collection(oauthCodeCollection).Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.M{"expires_at": 1}, Options: options.Index().SetExpireAfterSeconds(0)},
+		{Keys: bson.M{"code_hash": 1}, Options: options.Index().SetSparse(true)},
+	})
+	if err != nil {
+		o.Logger.Error().Err(err).Msg("failed to create oauth code indexes")
+	}
+	return o
+}
+
+// oauthClientModel is the registered client document. Only the hash of the secret of confidential clients is stored.
+type oauthClientModel struct {
+	ID           string    `bson:"_id"`
+	Name         string    `bson:"name"`
+	SecretHash   string    `bson:"secret_hash,omitempty"`
+	RedirectURIs []string  `bson:"redirect_uris,omitempty"`
+	GrantTypes   []string  `bson:"grant_types"`
+	Scopes       []string  `bson:"scopes"`
+	CreatedAt    time.Time `bson:"created_at"`
+}
+
+func (m *oauthClientModel) toResp() *OAuthClientResp {
+	return &OAuthClientResp{
+		ID:           m.ID,
+		Name:         m.Name,
+		Confidential: m.SecretHash != "",
+		RedirectURIs: m.RedirectURIs,
+		GrantTypes:   m.GrantTypes,
+		Scopes:       m.Scopes,
+		CreatedAt:    m.CreatedAt,
+	}
+}
+
+// oauthCodeModel is an authorization request waiting for user consent. Once approved it carries the authorization code hash.
+type oauthCodeModel struct {
+	ID            string    `bson:"_id"`
+	CodeHash      string    `bson:"code_hash,omitempty"`
+	ClientID      string    `bson:"client_id"`
+	UserID        string    `bson:"user_id"`
+	RedirectURI   string    `bson:"redirect_uri"`
+	Scopes        []string  `bson:"scopes"`
+	State         string    `bson:"state,omitempty"`
+	CodeChallenge string    `bson:"code_challenge"`
+	ExpiresAt     time.Time `bson:"expires_at"`
+}
+
+// RegisterClientOpts contains details of a new oauth client.
+// Public clients (e.g. single page or mobile apps) get no secret and can only use authorization code grant with PKCE.
+type RegisterClientOpts struct {
+	Name         string   `json:"name" validate:"required"`
+	Confidential bool     `json:"confidential"`
+	RedirectURIs []string `json:"redirect_uris" validate:"dive,url"`
+	GrantTypes   []string `json:"grant_types" validate:"required,min=1,dive,oneof=authorization_code client_credentials"`
+	Scopes       []string `json:"scopes" validate:"required,min=1,dive,required"`
+}
+
+// OAuthClientResp returns client details without its secret
+type OAuthClientResp struct {
+	ID           string    `json:"client_id"`
+	Name         string    `json:"name"`
+	Confidential bool      `json:"confidential"`
+	RedirectURIs []string  `json:"redirect_uris,omitempty"`
+	GrantTypes   []string  `json:"grant_types"`
+	Scopes       []string  `json:"scopes"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// HasGrantType returns true if the client is allowed to use the grant type
+func (c *OAuthClientResp) HasGrantType(grantType string) bool {
+	return containsString(c.GrantTypes, grantType)
+}
+
+// RegisterClientResp returns the newly registered client. The secret is only ever returned here.
+type RegisterClientResp struct {
+	*OAuthClientResp
+	Secret string `json:"client_secret,omitempty"`
+}
+
+// RegisterClient registers a new oauth client
+func (o *OAuthImpl) RegisterClient(opts *RegisterClientOpts) (*RegisterClientResp, error) {
+	for _, gt := range opts.GrantTypes {
+		if gt == GrantTypeClientCredentials && !opts.Confidential {
+			return nil, errors.New("client_credentials grant requires a confidential client", &errors.BadRequest)
+		}
+		if gt == GrantTypeAuthorizationCode && len(opts.RedirectURIs) == 0 {
+			return nil, errors.New("authorization_code grant requires at least one redirect uri", &errors.BadRequest)
+		}
+	}
+	for _, uri := range opts.RedirectURIs {
+		if u, err := url.Parse(uri); err != nil || !u.IsAbs() || u.Fragment != "" {
+			return nil, errors.New("redirect uri must be an absolute url without fragment", &errors.BadRequest)
+		}
+	}
+	id, err := randomToken(16)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate client id", &errors.SomethingWentWrong)
+	}
+	m := oauthClientModel{
+		ID:           id,
+		Name:         opts.Name,
+		RedirectURIs: opts.RedirectURIs,
+		GrantTypes:   opts.GrantTypes,
+		Scopes:       opts.Scopes,
+		CreatedAt:    o.now().UTC(),
+	}
+	resp := RegisterClientResp{}
+	if opts.Confidential {
+		if resp.Secret, err = randomToken(32); err != nil {
+			return nil, errors.Wrap(err, "failed to generate client secret", &errors.SomethingWentWrong)
+		}
+		m.SecretHash = hashToken(resp.Secret)
+	}
+	if _, err := o.DB.Collection(oauthClientCollection).InsertOne(context.TODO(), &m); err != nil {
+		return nil, errors.Wrap(err, "failed to save oauth client", &errors.DBError)
+	}
+	resp.OAuthClientResp = m.toResp()
+	return &resp, nil
+}
+
+// GetClient returns the client with the given id
+func (o *OAuthImpl) GetClient(id string) (*OAuthClientResp, error) {
+	m, err := o.findClient(id)
+	if err != nil {
+		return nil, err
+	}
+	return m.toResp(), nil
+}
+
+// AuthenticateClient returns the client if the secret matches. Public clients are authenticated without a secret.
+func (o *OAuthImpl) AuthenticateClient(id, secret string) (*OAuthClientResp, error) {
+	m, err := o.findClient(id)
+	if err != nil {
+		if errors.GetType(err) == errors.NotFound {
+			return nil, errors.New("invalid client", &errors.Unauthorized)
+		}
+		return nil, err
+	}
+	if m.SecretHash == "" {
+		if secret != "" {
+			return nil, errors.New("invalid client", &errors.Unauthorized)
+		}
+		return m.toResp(), nil
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(m.SecretHash)) != 1 {
+		return nil, errors.New("invalid client", &errors.Unauthorized)
+	}
+	return m.toResp(), nil
+}
+
+// AuthorizationRequestOpts contains parameters of /oauth/authorize request of the logged in user
+type AuthorizationRequestOpts struct {
+	UserID              string
+	ClientID            string
+	RedirectURI         string
+	ResponseType        string
+	Scopes              []string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// AuthorizationRequestResp returns the pending authorization request to be shown on the consent page
+type AuthorizationRequestResp struct {
+	ID     string
+	Client *OAuthClientResp
+	Scopes []string
+}
+
+// CreateAuthorizationRequest validates the authorization request and stores it until the user approves or denies it.
+// The id of the request is random and bound to the user, it is used as anti csrf token of the consent form.
+func (o *OAuthImpl) CreateAuthorizationRequest(opts *AuthorizationRequestOpts) (*AuthorizationRequestResp, error) {
+	m, err := o.findClient(opts.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	client := m.toResp()
+	if !client.HasGrantType(GrantTypeAuthorizationCode) {
+		return nil, errors.New("client is not allowed to use authorization code grant", &errors.BadRequest)
+	}
+	if !containsString(client.RedirectURIs, opts.RedirectURI) {
+		return nil, errors.New("redirect uri is not registered for the client", &errors.BadRequest)
+	}
+	if opts.ResponseType != "code" {
+		return nil, errors.New("response_type must be code", &errors.BadRequest)
+	}
+	if opts.CodeChallenge == "" || opts.CodeChallengeMethod != PKCECodeChallengeMethodS256 {
+		return nil, errors.New("code_challenge with S256 code_challenge_method is required", &errors.BadRequest)
+	}
+	scopes := opts.Scopes
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	for _, s := range scopes {
+		if !containsString(client.Scopes, s) {
+			return nil, errors.New("scope "+s+" is not allowed for the client", &errors.BadRequest)
+		}
+	}
+	id, err := randomToken(32)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate authorization request id", &errors.SomethingWentWrong)
+	}
+	req := oauthCodeModel{
+		ID:            hashToken(id),
+		ClientID:      client.ID,
+		UserID:        opts.UserID,
+		RedirectURI:   opts.RedirectURI,
+		Scopes:        scopes,
+		State:         opts.State,
+		CodeChallenge: opts.CodeChallenge,
+		ExpiresAt:     o.now().UTC().Add(DefaultAuthorizationRequestExpiresAt),
+	}
+	if _, err := o.DB.Collection(oauthCodeCollection).InsertOne(context.TODO(), &req); err != nil {
+		return nil, errors.Wrap(err, "failed to save authorization request", &errors.DBError)
+	}
+	return &AuthorizationRequestResp{ID: id, Client: client, Scopes: scopes}, nil
+}
+
+// ApproveAuthorizationOpts contains the decision of the user on an authorization request.
+// Scopes limits the granted scopes to the ones the user actually has, nil grants all the requested scopes.
+type ApproveAuthorizationOpts struct {
+	RequestID string
+	UserID    string
+	Approved  bool
+	Scopes    []string
+}
+
+// ApproveAuthorizationRequest returns the client redirect url carrying either the authorization code or access_denied error
+func (o *OAuthImpl) ApproveAuthorizationRequest(opts *ApproveAuthorizationOpts) (string, error) {
+	ctx := context.TODO()
+	coll := o.DB.Collection(oauthCodeCollection)
+	filter := bson.M{"_id": hashToken(opts.RequestID), "user_id": opts.UserID, "code_hash": bson.M{"$exists": false}}
+	req := oauthCodeModel{}
+	if err := coll.FindOne(ctx, filter).Decode(&req); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return "", errors.New("authorization request not found", &errors.BadRequest)
+		}
+		return "", errors.Wrap(err, "failed to find authorization request", &errors.DBError)
+	}
+	if !req.ExpiresAt.After(o.now()) {
+		return "", errors.New("authorization request expired", &errors.BadRequest)
+	}
+	redirect, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid redirect uri", &errors.BadRequest)
+	}
+	q := redirect.Query()
+	if req.State != "" {
+		q.Set("state", req.State)
+	}
+
+	if !opts.Approved {
+		if _, err := coll.DeleteOne(ctx, filter); err != nil {
+			return "", errors.Wrap(err, "failed to delete authorization request", &errors.DBError)
+		}
+		q.Set("error", "access_denied")
+		redirect.RawQuery = q.Encode()
+		return redirect.String(), nil
+	}
+
+	scopes := req.Scopes
+	if opts.Scopes != nil {
+		scopes = intersectStrings(req.Scopes, opts.Scopes)
+	}
+	code, err := randomToken(32)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate authorization code", &errors.SomethingWentWrong)
+	}
+	update := bson.M{"$set": bson.M{"code_hash": hashToken(code), "scopes": scopes, "expires_at": o.now().UTC().Add(o.codeExpiresAt)}}
+	res, err := coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to save authorization code", &errors.DBError)
+	}
+	if res.ModifiedCount != 1 {
+		return "", errors.New("authorization request not found", &errors.BadRequest)
+	}
+	q.Set("code", code)
+	redirect.RawQuery = q.Encode()
+	return redirect.String(), nil
+}
+
+// ExchangeAuthorizationCodeOpts contains parameters of authorization_code grant token request
+type ExchangeAuthorizationCodeOpts struct {
+	ClientID     string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+}
+
+// AuthorizationGrant contains the user and scopes an authorization code was issued for
+type AuthorizationGrant struct {
+	UserID string
+	Scopes []string
+}
+
+// ExchangeAuthorizationCode verifies and consumes the authorization code. A code can be exchanged only once.
+func (o *OAuthImpl) ExchangeAuthorizationCode(opts *ExchangeAuthorizationCodeOpts) (*AuthorizationGrant, error) {
+	code := oauthCodeModel{}
+	err := o.DB.Collection(oauthCodeCollection).FindOneAndDelete(context.TODO(), bson.M{"code_hash": hashToken(opts.Code)}).Decode(&code)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errInvalidGrant
+		}
+		return nil, errors.Wrap(err, "failed to find authorization code", &errors.DBError)
+	}
+	if !code.ExpiresAt.After(o.now()) || code.ClientID != opts.ClientID || code.RedirectURI != opts.RedirectURI {
+		return nil, errInvalidGrant
+	}
+	if !VerifyPKCE(opts.CodeVerifier, code.CodeChallenge) {
+		return nil, errors.New("invalid code_verifier", &errors.BadRequest)
+	}
+	return &AuthorizationGrant{UserID: code.UserID, Scopes: code.Scopes}, nil
+}
+
+// VerifyPKCE returns true if S256 challenge was derived from the verifier (RFC 7636)
+func VerifyPKCE(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	h := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(h[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+func (o *OAuthImpl) findClient(id string) (*oauthClientModel, error) {
+	m := oauthClientModel{}
+	if err := o.DB.Collection(oauthClientCollection).FindOne(context.TODO(), bson.M{"_id": id}).Decode(&m); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("oauth client not found", &errors.NotFound)
+		}
+		return nil, errors.Wrap(err, "failed to find oauth client", &errors.DBError)
+	}
+	return &m, nil
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// intersectStrings returns elements of a which are also in b, keeping the order of a
+func intersectStrings(a, b []string) []string {
+	out := []string{}
+	for _, v := range a {
+		if containsString(b, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
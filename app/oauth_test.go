@@ -0,0 +1,132 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	errors "github.com/vasupal1996/goerror"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	// example of RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	assert.True(t, VerifyPKCE(verifier, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"))
+	assert.False(t, VerifyPKCE(verifier, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cN"))
+	assert.False(t, VerifyPKCE("short", "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"))
+}
+
+func TestOAuthImpl(t *testing.T) {
+	app := NewTestApp(getTestConfig())
+	defer CleanTestApp(app)
+	now := time.Now()
+	o := InitOAuth(&OAuthOpts{App: app, DB: app.MongoDB.Client.Database(app.Config.OAuthConfig.DBName), Logger: app.Logger}).(*OAuthImpl)
+	o.now = func() time.Time { return now }
+
+	_, err := o.RegisterClient(&RegisterClientOpts{Name: "spa", GrantTypes: []string{GrantTypeClientCredentials}, Scopes: []string{"orders:read"}})
+	assert.Equal(t, errors.BadRequest, errors.GetType(err))
+	_, err = o.RegisterClient(&RegisterClientOpts{Name: "spa", GrantTypes: []string{GrantTypeAuthorizationCode}, Scopes: []string{"orders:read"}})
+	assert.Equal(t, errors.BadRequest, errors.GetType(err))
+
+	confidential, err := o.RegisterClient(&RegisterClientOpts{Name: "reports", Confidential: true, GrantTypes: []string{GrantTypeClientCredentials}, Scopes: []string{"orders:read"}})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, confidential.Secret)
+	c, err := o.AuthenticateClient(confidential.ID, confidential.Secret)
+	assert.Nil(t, err)
+	assert.True(t, c.Confidential)
+	_, err = o.AuthenticateClient(confidential.ID, "wrong")
+	assert.Equal(t, errors.Unauthorized, errors.GetType(err))
+	_, err = o.AuthenticateClient("unknown", "")
+	assert.Equal(t, errors.Unauthorized, errors.GetType(err))
+
+	public, err := o.RegisterClient(&RegisterClientOpts{
+		Name:         "spa",
+		RedirectURIs: []string{"https://spa.example.com/cb"},
+		GrantTypes:   []string{GrantTypeAuthorizationCode},
+		Scopes:       []string{"orders:read", "orders:write"},
+	})
+	assert.Nil(t, err)
+	assert.Empty(t, public.Secret)
+	_, err = o.AuthenticateClient(public.ID, "")
+	assert.Nil(t, err)
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	h := sha256.Sum256([]byte(verifier))
+	reqOpts := AuthorizationRequestOpts{
+		UserID:              "u1",
+		ClientID:            public.ID,
+		RedirectURI:         "https://spa.example.com/cb",
+		ResponseType:        "code",
+		State:               "xyz",
+		CodeChallenge:       base64.RawURLEncoding.EncodeToString(h[:]),
+		CodeChallengeMethod: PKCECodeChallengeMethodS256,
+	}
+
+	invalid := reqOpts
+	invalid.RedirectURI = "https://evil.example.com/cb"
+	_, err = o.CreateAuthorizationRequest(&invalid)
+	assert.NotNil(t, err)
+	invalid = reqOpts
+	invalid.CodeChallengeMethod = "plain"
+	_, err = o.CreateAuthorizationRequest(&invalid)
+	assert.NotNil(t, err)
+	invalid = reqOpts
+	invalid.Scopes = []string{"users:write"}
+	_, err = o.CreateAuthorizationRequest(&invalid)
+	assert.NotNil(t, err)
+
+	// denied request redirects with access_denied
+	req, err := o.CreateAuthorizationRequest(&reqOpts)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"orders:read", "orders:write"}, req.Scopes)
+	redirect, err := o.ApproveAuthorizationRequest(&ApproveAuthorizationOpts{RequestID: req.ID, UserID: "u1", Approved: false})
+	assert.Nil(t, err)
+	assert.Equal(t, "https://spa.example.com/cb?error=access_denied&state=xyz", redirect)
+
+	// request is bound to the user who created it
+	req, err = o.CreateAuthorizationRequest(&reqOpts)
+	assert.Nil(t, err)
+	_, err = o.ApproveAuthorizationRequest(&ApproveAuthorizationOpts{RequestID: req.ID, UserID: "u2", Approved: true})
+	assert.NotNil(t, err)
+	redirect, err = o.ApproveAuthorizationRequest(&ApproveAuthorizationOpts{RequestID: req.ID, UserID: "u1", Approved: true, Scopes: []string{"orders:read"}})
+	assert.Nil(t, err)
+	u, _ := url.Parse(redirect)
+	assert.Equal(t, "xyz", u.Query().Get("state"))
+	code := u.Query().Get("code")
+	assert.NotEmpty(t, code)
+	_, err = o.ApproveAuthorizationRequest(&ApproveAuthorizationOpts{RequestID: req.ID, UserID: "u1", Approved: true})
+	assert.NotNil(t, err)
+
+	exchange := ExchangeAuthorizationCodeOpts{ClientID: public.ID, Code: code, RedirectURI: "https://spa.example.com/cb", CodeVerifier: verifier}
+	grant, err := o.ExchangeAuthorizationCode(&exchange)
+	assert.Nil(t, err)
+	assert.Equal(t, "u1", grant.UserID)
+	assert.Equal(t, []string{"orders:read"}, grant.Scopes)
+	// codes are single use
+	_, err = o.ExchangeAuthorizationCode(&exchange)
+	assert.Equal(t, errInvalidGrant, err)
+
+	// wrong verifier consumes the code as well
+	req, _ = o.CreateAuthorizationRequest(&reqOpts)
+	redirect, _ = o.ApproveAuthorizationRequest(&ApproveAuthorizationOpts{RequestID: req.ID, UserID: "u1", Approved: true})
+	u, _ = url.Parse(redirect)
+	exchange.Code = u.Query().Get("code")
+	exchange.CodeVerifier = "wrong-verifier-wrong-verifier-wrong-verifier"
+	_, err = o.ExchangeAuthorizationCode(&exchange)
+	assert.NotNil(t, err)
+	exchange.CodeVerifier = verifier
+	_, err = o.ExchangeAuthorizationCode(&exchange)
+	assert.Equal(t, errInvalidGrant, err)
+
+	// expired code
+	req, _ = o.CreateAuthorizationRequest(&reqOpts)
+	redirect, _ = o.ApproveAuthorizationRequest(&ApproveAuthorizationOpts{RequestID: req.ID, UserID: "u1", Approved: true})
+	u, _ = url.Parse(redirect)
+	exchange.Code = u.Query().Get("code")
+	o.now = func() time.Time { return now.Add(o.codeExpiresAt + time.Second) }
+	_, err = o.ExchangeAuthorizationCode(&exchange)
+	assert.Equal(t, errInvalidGrant, err)
+}
@@ -1,9 +1,38 @@
 package app
 
+import "go-app/server/auth"
+
 // InitService this initializes all the busines logic services
-func InitService(a *App) {
+func InitService(a *App) error {
+	hasher, err := auth.NewPasswordHasher(&a.Config.UserConfig.PasswordHashConfig)
+	if err != nil {
+		return err
+	}
 	a.Example = InitExample(&ExampleOpts{
 		DB:     a.MongoDB.Client.Database(a.Config.ExampleConfig.DBName),
 		Logger: a.Logger,
 	})
+	a.APIKey = InitAPIKey(&APIKeyOpts{
+		App:    a,
+		DB:     a.MongoDB.Client.Database(a.Config.APIKeyConfig.DBName),
+		Logger: a.Logger,
+	})
+	a.User = InitUser(&UserOpts{
+		App:     a,
+		DB:      a.MongoDB.Client.Database(a.Config.UserConfig.DBName),
+		Logger:  a.Logger,
+		Hasher:  hasher,
+		Revoker: a.Revoker,
+	})
+	a.MFA = InitMFA(&MFAOpts{
+		App:    a,
+		DB:     a.MongoDB.Client.Database(a.Config.MFAConfig.DBName),
+		Logger: a.Logger,
+	})
+	a.OAuth = InitOAuth(&OAuthOpts{
+		App:    a,
+		DB:     a.MongoDB.Client.Database(a.Config.OAuthConfig.DBName),
+		Logger: a.Logger,
+	})
+	return nil
 }
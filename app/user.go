@@ -0,0 +1,430 @@
+//go:generate $GOPATH/bin/mockgen -destination=../mock/mock_user.go -package=mock go-app/app User
+
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"go-app/server/auth"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	errors "github.com/vasupal1996/goerror"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// userCollection stores user accounts
+const userCollection = "user"
+
+// UserTypeUser is the type of every registered user
+const UserTypeUser = "user"
+
+// DefaultResetTokenExpiresAt is used when UserConfig.ResetTokenExpiresAt is not set
+const DefaultResetTokenExpiresAt = time.Hour
+
+// errInvalidCredentials does not tell whether email or password was wrong so that accounts can not be enumerated
+var errInvalidCredentials = errors.New("invalid email or password", &errors.Unauthorized)
+
+// User defines methods of user accounts service to be implemented
+type User interface {
+	ChangePassword(*ChangePasswordOpts) error
+	ForgotPassword(*ForgotPasswordOpts) error
+	GetUser(string) (*UserResp, error)
+	Login(*LoginOpts) (*auth.UserClaim, error)
+	LoginExternal(*ExternalLoginOpts) (*auth.UserClaim, error)
+	Register(*RegisterOpts) (*UserResp, error)
+	ResetPassword(*ResetPasswordOpts) error
+}
+
+// ResetTokenNotifier delivers password reset tokens to users, e.g. by email
+type ResetTokenNotifier interface {
+	NotifyResetToken(email, token string) error
+}
+
+// CredentialRevoker revokes every access token, refresh token and session issued to a user before the given time
+type CredentialRevoker interface {
+	RevokeUser(id string, before time.Time) error
+}
+
+// UserOpts contains arguments to be accepted for new instance of user service
+type UserOpts struct {
+	App      *App
+	DB       *mongo.Database
+	Logger   *zerolog.Logger
+	Hasher   *auth.PasswordHasher
+	Notifier ResetTokenNotifier
+	Revoker  CredentialRevoker
+}
+
+// UserImpl implements user service
+type UserImpl struct {
+	App      *App
+	DB       *mongo.Database
+	Logger   *zerolog.Logger
+	Hasher   *auth.PasswordHasher
+	Notifier ResetTokenNotifier
+	// Revoker signs the user out everywhere when the password is changed or reset
+	Revoker CredentialRevoker
+
+	resetTokenExpiresAt time.Duration
+	// dummyHash is verified when user does not exist so that login takes the same time for unknown emails
+	dummyHash string
+}
+
+// InitUser returns initializes user service
+func InitUser(opts *UserOpts) User {
+	u := &UserImpl{
+		App:                 opts.App,
+		DB:                  opts.DB,
+		Logger:              opts.Logger,
+		Hasher:              opts.Hasher,
+		Notifier:            opts.Notifier,
+		Revoker:             opts.Revoker,
+		resetTokenExpiresAt: DefaultResetTokenExpiresAt,
+	}
+	if opts.App != nil && opts.App.Config != nil && opts.App.Config.UserConfig.ResetTokenExpiresAt != 0 {
+		u.resetTokenExpiresAt = time.Duration(opts.App.Config.UserConfig.ResetTokenExpiresAt) * time.Minute
+	}
+	u.dummyHash, _ = u.Hasher.Hash("dummy password")
+	_, err := u.DB.Collection(userCollection).Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.M{"email": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"reset_token_hash": 1}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "identities.issuer", Value: 1}, {Key: "identities.subject", Value: 1}}, Options: options.Index().SetUnique(true).SetSparse(true)},
+	})
+	if err != nil {
+		u.Logger.Error().Err(err).Msg("failed to create user indexes")
+	}
+	return u
+}
+
+// userModel is the user document stored in the database.
+// Users signed up with an external provider have no password until they reset it.
+type userModel struct {
+	ID                  primitive.ObjectID `bson:"_id,omitempty"`
+	Email               string             `bson:"email"`
+	PasswordHash        string             `bson:"password_hash,omitempty"`
+	Type                string             `bson:"type"`
+	Roles               []string           `bson:"roles,omitempty"`
+	Identities          []externalIdentity `bson:"identities,omitempty"`
+	CreatedAt           time.Time          `bson:"created_at"`
+	PasswordChangedAt   time.Time          `bson:"password_changed_at"`
+	ResetTokenHash      string             `bson:"reset_token_hash,omitempty"`
+	ResetTokenExpiresAt *time.Time         `bson:"reset_token_expires_at,omitempty"`
+}
+
+// externalIdentity links a user to the subject of an external identity provider
+type externalIdentity struct {
+	Issuer  string `bson:"issuer"`
+	Subject string `bson:"subject"`
+}
+
+func (m *userModel) toClaim() *auth.UserClaim {
+	return &auth.UserClaim{ID: m.ID.Hex(), Type: m.Type, Roles: m.Roles}
+}
+
+// RegisterOpts contains email and password of a new user
+type RegisterOpts struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+// UserResp returns user details without password
+type UserResp struct {
+	ID        primitive.ObjectID `json:"id"`
+	Email     string             `json:"email"`
+	Type      string             `json:"type"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+// Register creates a new user account
+func (u *UserImpl) Register(opts *RegisterOpts) (*UserResp, error) {
+	hash, err := u.Hasher.Hash(opts.Password)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to hash password", &errors.SomethingWentWrong)
+	}
+	now := time.Now().UTC()
+	m := userModel{
+		Email:             normalizeEmail(opts.Email),
+		PasswordHash:      hash,
+		Type:              UserTypeUser,
+		CreatedAt:         now,
+		PasswordChangedAt: now,
+	}
+	res, err := u.DB.Collection(userCollection).InsertOne(context.TODO(), &m)
+	if err != nil {
+		if isDuplicateKeyError(err) {
+			return nil, errors.New("email is already registered", &errors.BadRequest)
+		}
+		return nil, errors.Wrap(err, "failed to save user", &errors.DBError)
+	}
+	return &UserResp{ID: res.InsertedID.(primitive.ObjectID), Email: m.Email, Type: m.Type, CreatedAt: m.CreatedAt}, nil
+}
+
+// GetUser returns the user with the given id
+func (u *UserImpl) GetUser(id string) (*UserResp, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid user id", &errors.BadRequest)
+	}
+	m, err := u.findUser(bson.M{"_id": oid})
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, errors.New("user not found", &errors.NotFound)
+	}
+	return &UserResp{ID: m.ID, Email: m.Email, Type: m.Type, CreatedAt: m.CreatedAt}, nil
+}
+
+// LoginOpts contains credentials of the user
+type LoginOpts struct {
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// Login returns claim of the user if credentials are valid.
+// Password is rehashed when it was hashed with other algorithm or parameters than the configured ones.
+func (u *UserImpl) Login(opts *LoginOpts) (*auth.UserClaim, error) {
+	m, err := u.findUser(bson.M{"email": normalizeEmail(opts.Email)})
+	if err != nil {
+		return nil, err
+	}
+	if m == nil || m.PasswordHash == "" {
+		u.Hasher.Verify(u.dummyHash, opts.Password)
+		return nil, errInvalidCredentials
+	}
+	ok, err := u.Hasher.Verify(m.PasswordHash, opts.Password)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to verify password", &errors.SomethingWentWrong)
+	}
+	if !ok {
+		return nil, errInvalidCredentials
+	}
+	if u.Hasher.NeedsRehash(m.PasswordHash) {
+		if hash, err := u.Hasher.Hash(opts.Password); err == nil {
+			if _, err := u.DB.Collection(userCollection).UpdateOne(context.TODO(), bson.M{"_id": m.ID}, bson.M{"$set": bson.M{"password_hash": hash}}); err != nil {
+				u.Logger.Error().Err(err).Str("user", m.ID.Hex()).Msg("failed to rehash password")
+			}
+		}
+	}
+	return m.toClaim(), nil
+}
+
+// ExternalLoginOpts contains the identity verified by an external provider, e.g. claims of an oidc id token
+type ExternalLoginOpts struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	// LinkByEmail signs in an existing user with the same verified email and links the identity to it
+	LinkByEmail bool
+}
+
+// LoginExternal returns claim of the user linked to the external identity. A new user is created on first login.
+// Provider must return a verified email for new users, existing accounts are only linked when LinkByEmail is set.
+func (u *UserImpl) LoginExternal(opts *ExternalLoginOpts) (*auth.UserClaim, error) {
+	identity := externalIdentity{Issuer: opts.Issuer, Subject: opts.Subject}
+	m, err := u.findUser(bson.M{"identities": bson.M{"$elemMatch": bson.M{"issuer": identity.Issuer, "subject": identity.Subject}}})
+	if err != nil {
+		return nil, err
+	}
+	if m != nil {
+		return m.toClaim(), nil
+	}
+	if opts.Email == "" || !opts.EmailVerified {
+		return nil, errors.New("identity provider did not return a verified email", &errors.Unauthorized)
+	}
+	email := normalizeEmail(opts.Email)
+	if m, err = u.findUser(bson.M{"email": email}); err != nil {
+		return nil, err
+	}
+	if m != nil {
+		if !opts.LinkByEmail {
+			return nil, errors.New("email is already registered, sign in with password", &errors.BadRequest)
+		}
+		if _, err := u.DB.Collection(userCollection).UpdateOne(context.TODO(), bson.M{"_id": m.ID}, bson.M{"$addToSet": bson.M{"identities": identity}}); err != nil {
+			return nil, errors.Wrap(err, "failed to link identity", &errors.DBError)
+		}
+		u.Logger.Info().Str("user", m.ID.Hex()).Str("issuer", identity.Issuer).Msg("external identity linked by email")
+		return m.toClaim(), nil
+	}
+
+	m = &userModel{
+		Email:      email,
+		Type:       UserTypeUser,
+		Identities: []externalIdentity{identity},
+		CreatedAt:  time.Now().UTC(),
+	}
+	res, err := u.DB.Collection(userCollection).InsertOne(context.TODO(), m)
+	if err != nil {
+		if isDuplicateKeyError(err) {
+			return nil, errors.New("email is already registered, sign in with password", &errors.BadRequest)
+		}
+		return nil, errors.Wrap(err, "failed to save user", &errors.DBError)
+	}
+	m.ID = res.InsertedID.(primitive.ObjectID)
+	return m.toClaim(), nil
+}
+
+// ChangePasswordOpts contains current and new password of the logged in user
+type ChangePasswordOpts struct {
+	UserID      string `json:"-"`
+	OldPassword string `json:"old_password" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=8,max=72"`
+}
+
+// ChangePassword replaces password of the user after verifying the current one
+func (u *UserImpl) ChangePassword(opts *ChangePasswordOpts) error {
+	id, err := primitive.ObjectIDFromHex(opts.UserID)
+	if err != nil {
+		return errors.New("invalid user id", &errors.BadRequest)
+	}
+	m, err := u.findUser(bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return errors.New("user not found", &errors.NotFound)
+	}
+	if m.PasswordHash == "" {
+		return errors.New("password is not set, use password reset to set one", &errors.BadRequest)
+	}
+	ok, err := u.Hasher.Verify(m.PasswordHash, opts.OldPassword)
+	if err != nil {
+		return errors.Wrap(err, "failed to verify password", &errors.SomethingWentWrong)
+	}
+	if !ok {
+		return errors.New("invalid password", &errors.BadRequest)
+	}
+	// password must not have been changed meanwhile, e.g. by a reset
+	m, err = u.setPassword(bson.M{"_id": m.ID, "password_hash": m.PasswordHash}, opts.NewPassword)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return errors.New("password was changed meanwhile, try again", &errors.BadRequest)
+	}
+	return nil
+}
+
+// ForgotPasswordOpts contains email of the user who forgot the password
+type ForgotPasswordOpts struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ForgotPassword generates a password reset token and passes it to the notifier.
+// No error is returned for unknown emails so that accounts can not be enumerated.
+func (u *UserImpl) ForgotPassword(opts *ForgotPasswordOpts) error {
+	m, err := u.findUser(bson.M{"email": normalizeEmail(opts.Email)})
+	if err != nil || m == nil {
+		return err
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return errors.Wrap(err, "failed to generate reset token", &errors.SomethingWentWrong)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	expiresAt := time.Now().UTC().Add(u.resetTokenExpiresAt)
+	update := bson.M{"$set": bson.M{"reset_token_hash": hashResetToken(token), "reset_token_expires_at": expiresAt}}
+	if _, err := u.DB.Collection(userCollection).UpdateOne(context.TODO(), bson.M{"_id": m.ID}, update); err != nil {
+		return errors.Wrap(err, "failed to save reset token", &errors.DBError)
+	}
+	if u.Notifier == nil {
+		u.Logger.Warn().Str("user", m.ID.Hex()).Msg("password reset requested but no reset token notifier is configured")
+		return nil
+	}
+	if err := u.Notifier.NotifyResetToken(m.Email, token); err != nil {
+		return errors.Wrap(err, "failed to send reset token", &errors.SomethingWentWrong)
+	}
+	return nil
+}
+
+// ResetPasswordOpts contains reset token and the new password
+type ResetPasswordOpts struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+// ResetPassword replaces password of the user the reset token was issued for.
+// The token is consumed in the same update which sets the password so it can be used only once.
+func (u *UserImpl) ResetPassword(opts *ResetPasswordOpts) error {
+	m, err := u.setPassword(bson.M{
+		"reset_token_hash":       hashResetToken(opts.Token),
+		"reset_token_expires_at": bson.M{"$gt": time.Now().UTC()},
+	}, opts.Password)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return errors.New("invalid or expired reset token", &errors.BadRequest)
+	}
+	return nil
+}
+
+// setPassword atomically stores hash of the new password for the user matching the filter and invalidates any pending
+// reset token. Credentials issued to the user before the change are revoked, a failed revocation is logged since the
+// password has already changed. Nil user is returned if no user matches.
+func (u *UserImpl) setPassword(filter bson.M, password string) (*userModel, error) {
+	hash, err := u.Hasher.Hash(password)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to hash password", &errors.SomethingWentWrong)
+	}
+	changedAt := time.Now().UTC()
+	update := bson.M{
+		"$set":   bson.M{"password_hash": hash, "password_changed_at": changedAt},
+		"$unset": bson.M{"reset_token_hash": "", "reset_token_expires_at": ""},
+	}
+	m := userModel{}
+	if err := u.DB.Collection(userCollection).FindOneAndUpdate(context.TODO(), filter, update).Decode(&m); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to update password", &errors.DBError)
+	}
+	if u.Revoker != nil {
+		if err := u.Revoker.RevokeUser(m.ID.Hex(), changedAt); err != nil {
+			u.Logger.Error().Err(err).Str("user", m.ID.Hex()).Msg("failed to revoke credentials after password change")
+		}
+	}
+	return &m, nil
+}
+
+// findUser returns nil user without error if no user matches the filter
+func (u *UserImpl) findUser(filter bson.M) (*userModel, error) {
+	m := userModel{}
+	if err := u.DB.Collection(userCollection).FindOne(context.TODO(), filter).Decode(&m); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to find user", &errors.DBError)
+	}
+	return &m, nil
+}
+
+// isDuplicateKeyError returns true if err was caused by a unique index violation
+func isDuplicateKeyError(err error) bool {
+	if we, ok := err.(mongo.WriteException); ok {
+		for _, e := range we.WriteErrors {
+			if e.Code == 11000 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func hashResetToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
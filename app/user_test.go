@@ -0,0 +1,123 @@
+package app
+
+import (
+	"go-app/server/auth"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testResetTokenNotifier stores the last reset token sent to each email
+type testResetTokenNotifier map[string]string
+
+func (n testResetTokenNotifier) NotifyResetToken(email, token string) error {
+	n[email] = token
+	return nil
+}
+
+// testCredentialRevoker stores the time credentials of each user were last revoked
+type testCredentialRevoker map[string]time.Time
+
+func (r testCredentialRevoker) RevokeUser(id string, before time.Time) error {
+	r[id] = before
+	return nil
+}
+
+func TestUserImpl(t *testing.T) {
+	app := NewTestApp(getTestConfig())
+	defer CleanTestApp(app)
+	hasher, err := auth.NewPasswordHasher(&app.Config.UserConfig.PasswordHashConfig)
+	assert.Nil(t, err)
+	notifier := testResetTokenNotifier{}
+	revoker := testCredentialRevoker{}
+	u := InitUser(&UserOpts{App: app, DB: app.MongoDB.Client.Database(app.Config.UserConfig.DBName), Logger: app.Logger, Hasher: hasher, Notifier: notifier, Revoker: revoker})
+
+	registered, err := u.Register(&RegisterOpts{Email: " Alice@Example.com", Password: "password1"})
+	assert.Nil(t, err)
+	assert.Equal(t, "alice@example.com", registered.Email)
+	_, err = u.Register(&RegisterOpts{Email: "alice@example.com", Password: "password1"})
+	assert.NotNil(t, err)
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantErr  bool
+	}{
+		{name: "Valid Credentials", email: "ALICE@example.com", password: "password1"},
+		{name: "Wrong Password", email: "alice@example.com", password: "password2", wantErr: true},
+		{name: "Unknown Email", email: "bob@example.com", password: "password1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claim, err := u.Login(&LoginOpts{Email: tt.email, Password: tt.password})
+			if tt.wantErr {
+				assert.Equal(t, errInvalidCredentials, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, registered.ID.Hex(), claim.ID)
+			assert.Equal(t, UserTypeUser, claim.Type)
+		})
+	}
+
+	// change password
+	assert.NotNil(t, u.ChangePassword(&ChangePasswordOpts{UserID: registered.ID.Hex(), OldPassword: "wrong", NewPassword: "password2"}))
+	assert.Empty(t, revoker)
+	assert.Nil(t, u.ChangePassword(&ChangePasswordOpts{UserID: registered.ID.Hex(), OldPassword: "password1", NewPassword: "password2"}))
+	changedAt, ok := revoker[registered.ID.Hex()]
+	assert.True(t, ok, "credentials issued before the change are revoked")
+	_, err = u.Login(&LoginOpts{Email: "alice@example.com", Password: "password2"})
+	assert.Nil(t, err)
+
+	// reset password, token can be used only once
+	assert.Nil(t, u.ForgotPassword(&ForgotPasswordOpts{Email: "bob@example.com"}))
+	assert.Nil(t, u.ForgotPassword(&ForgotPasswordOpts{Email: "alice@example.com"}))
+	assert.Len(t, notifier, 1)
+	token := notifier["alice@example.com"]
+	assert.NotNil(t, u.ResetPassword(&ResetPasswordOpts{Token: "invalid", Password: "password3"}))
+	assert.Nil(t, u.ResetPassword(&ResetPasswordOpts{Token: token, Password: "password3"}))
+	assert.False(t, revoker[registered.ID.Hex()].Before(changedAt), "credentials issued before the reset are revoked")
+	assert.NotNil(t, u.ResetPassword(&ResetPasswordOpts{Token: token, Password: "password4"}))
+	_, err = u.Login(&LoginOpts{Email: "alice@example.com", Password: "password3"})
+	assert.Nil(t, err)
+}
+
+func TestUserImpl_LoginExternal(t *testing.T) {
+	app := NewTestApp(getTestConfig())
+	defer CleanTestApp(app)
+	hasher, _ := auth.NewPasswordHasher(&app.Config.UserConfig.PasswordHashConfig)
+	u := InitUser(&UserOpts{App: app, DB: app.MongoDB.Client.Database(app.Config.UserConfig.DBName), Logger: app.Logger, Hasher: hasher})
+
+	_, err := u.LoginExternal(&ExternalLoginOpts{Issuer: "https://idp", Subject: "s1", Email: "new@example.com"})
+	assert.NotNil(t, err, "unverified email can not create a user")
+
+	// first login creates a user without password
+	claim, err := u.LoginExternal(&ExternalLoginOpts{Issuer: "https://idp", Subject: "s1", Email: "New@example.com", EmailVerified: true})
+	assert.Nil(t, err)
+	assert.Equal(t, UserTypeUser, claim.Type)
+	again, err := u.LoginExternal(&ExternalLoginOpts{Issuer: "https://idp", Subject: "s1"})
+	assert.Nil(t, err)
+	assert.Equal(t, claim.ID, again.ID)
+	_, err = u.Login(&LoginOpts{Email: "new@example.com", Password: ""})
+	assert.NotNil(t, err)
+	err = u.ChangePassword(&ChangePasswordOpts{UserID: claim.ID, OldPassword: "", NewPassword: "password2"})
+	assert.NotNil(t, err)
+
+	// existing password account is only linked when enabled
+	registered, err := u.Register(&RegisterOpts{Email: "old@example.com", Password: "password1"})
+	assert.Nil(t, err)
+	_, err = u.LoginExternal(&ExternalLoginOpts{Issuer: "https://idp", Subject: "s2", Email: "old@example.com", EmailVerified: true})
+	assert.NotNil(t, err)
+	claim, err = u.LoginExternal(&ExternalLoginOpts{Issuer: "https://idp", Subject: "s2", Email: "old@example.com", EmailVerified: true, LinkByEmail: true})
+	assert.Nil(t, err)
+	assert.Equal(t, registered.ID.Hex(), claim.ID)
+	claim, err = u.LoginExternal(&ExternalLoginOpts{Issuer: "https://idp", Subject: "s2"})
+	assert.Nil(t, err)
+	assert.Equal(t, registered.ID.Hex(), claim.ID)
+
+	// same subject of another issuer is another identity
+	_, err = u.LoginExternal(&ExternalLoginOpts{Issuer: "https://other-idp", Subject: "s1"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,50 @@
+// Command secret encrypts config secrets with the master key, the output is stored in config files as it is, e.g.
+//
+//	export GOAPP_MASTER_KEY=$(go run ./cmd/secret -genkey)
+//	echo -n "db-password" | go run ./cmd/secret
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"go-app/server/config"
+	"io/ioutil"
+	"os"
+)
+
+func main() {
+	genKey := flag.Bool("genkey", false, "print a new base64 encoded master key")
+	flag.Parse()
+	if err := run(*genKey); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(genKey bool) error {
+	if genKey {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return err
+		}
+		fmt.Println(base64.StdEncoding.EncodeToString(key))
+		return nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(os.Getenv(config.MasterKeyEnv))
+	if err != nil || len(key) != 32 {
+		return fmt.Errorf("%s must be a base64 encoded 32 bytes key", config.MasterKeyEnv)
+	}
+	secret, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+	ref, err := config.EncryptSecret(key, string(secret))
+	if err != nil {
+		return err
+	}
+	fmt.Println(ref)
+	return nil
+}
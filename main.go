@@ -1,47 +1,61 @@
 package main
 
 import (
+	"fmt"
 	"go-app/server"
-	"net"
+	"go-app/server/config"
 	"os"
 	"os/signal"
+	"syscall"
+
+	"github.com/spf13/pflag"
 )
 
 func main() {
-	s := server.NewServer()
-	s.StartServer()
-	defer s.StopServer()
+	os.Exit(run())
+}
 
-	err := sendSystemdNotification()
+// run starts the server and blocks until an interrupt or termination signal is received.
+// When run by systemd the server notifies readiness, shutdown and pings the watchdog, see sdnotify package.
+// SIGHUP restarts the server without refusing connections, a new process takes over the listener and sends SIGTERM
+// to this one once it is serving.
+// It returns the exit code of the process, deferred calls are run before the process exits.
+func run() int {
+	conf, err := config.GetConfig(os.Args[1:])
+	if err == pflag.ErrHelp {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
 
+	s, err := server.NewServer(conf)
 	if err != nil {
-		s.Log.Error().Err(err).Msg("Systemd notification error")
+		fmt.Fprintf(os.Stderr, "failed to initialize server: %s\n", err)
+		return 1
+	}
+	if err := s.StartServer(); err != nil {
+		// components which were started are already stopped
+		fmt.Fprintf(os.Stderr, "failed to start server: %s\n", err)
+		return 1
 	}
 
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	<-c
-
-	s.Log.Debug().Msg("Stopped server")
-	s.StopServer()
-	os.Exit(0)
-}
-
-func sendSystemdNotification() error {
-	notifySocket := os.Getenv("NOTIFY_SOCKET")
-	if notifySocket != "" {
-		state := "READY=1"
-		socketAddr := &net.UnixAddr{
-			Name: notifySocket,
-			Net:  "unixgram",
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	sig := <-c
+	for ; sig == syscall.SIGHUP; sig = <-c {
+		if err := s.Restart(); err != nil {
+			s.Log.Error().Err(err).Msg("failed to restart server")
 		}
-		conn, err := net.DialUnix(socketAddr.Net, nil, socketAddr)
-		if err != nil {
-			return err
-		}
-		defer conn.Close()
-		_, err = conn.Write([]byte(state))
-		return err
 	}
-	return nil
+	signal.Stop(c)
+
+	s.Log.Info().Str("signal", sig.String()).Msg("Received signal")
+	if err := s.StopServer(); err != nil {
+		// loggers are already flushed
+		fmt.Fprintf(os.Stderr, "failed to stop server gracefully: %s\n", err)
+		return 1
+	}
+	return 0
 }
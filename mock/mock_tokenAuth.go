@@ -34,53 +34,28 @@ func (m *MockTokenAuth) EXPECT() *MockTokenAuthMockRecorder {
 	return m.recorder
 }
 
-// GetClaim mocks base method
-func (m *MockTokenAuth) GetClaim() auth.Claim {
-	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetClaim")
-	ret0, _ := ret[0].(auth.Claim)
-	return ret0
-}
-
-// GetClaim indicates an expected call of GetClaim
-func (mr *MockTokenAuthMockRecorder) GetClaim() *gomock.Call {
-	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetClaim", reflect.TypeOf((*MockTokenAuth)(nil).GetClaim))
-}
-
-// SetClaim mocks base method
-func (m *MockTokenAuth) SetClaim(arg0 auth.Claim) {
-	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "SetClaim", arg0)
-}
-
-// SetClaim indicates an expected call of SetClaim
-func (mr *MockTokenAuthMockRecorder) SetClaim(arg0 interface{}) *gomock.Call {
-	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetClaim", reflect.TypeOf((*MockTokenAuth)(nil).SetClaim), arg0)
-}
-
 // SignToken mocks base method
-func (m *MockTokenAuth) SignToken() (string, error) {
+func (m *MockTokenAuth) SignToken(arg0 auth.Claim) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SignToken")
+	ret := m.ctrl.Call(m, "SignToken", arg0)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // SignToken indicates an expected call of SignToken
-func (mr *MockTokenAuthMockRecorder) SignToken() *gomock.Call {
+func (mr *MockTokenAuthMockRecorder) SignToken(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SignToken", reflect.TypeOf((*MockTokenAuth)(nil).SignToken))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SignToken", reflect.TypeOf((*MockTokenAuth)(nil).SignToken), arg0)
 }
 
 // VerifyToken mocks base method
-func (m *MockTokenAuth) VerifyToken(arg0 string) error {
+func (m *MockTokenAuth) VerifyToken(arg0 string) (auth.Claim, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "VerifyToken", arg0)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(auth.Claim)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // VerifyToken indicates an expected call of VerifyToken
@@ -126,6 +101,34 @@ func (mr *MockClaimMockRecorder) GetJWTToken() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJWTToken", reflect.TypeOf((*MockClaim)(nil).GetJWTToken))
 }
 
+// GetPermissions mocks base method
+func (m *MockClaim) GetPermissions() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPermissions")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// GetPermissions indicates an expected call of GetPermissions
+func (mr *MockClaimMockRecorder) GetPermissions() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPermissions", reflect.TypeOf((*MockClaim)(nil).GetPermissions))
+}
+
+// GetRoles mocks base method
+func (m *MockClaim) GetRoles() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRoles")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// GetRoles indicates an expected call of GetRoles
+func (mr *MockClaimMockRecorder) GetRoles() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRoles", reflect.TypeOf((*MockClaim)(nil).GetRoles))
+}
+
 // IsAdmin mocks base method
 func (m *MockClaim) IsAdmin() bool {
 	m.ctrl.T.Helper()
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// HeaderAPIKey header name to look for api key of machine clients
+const HeaderAPIKey = "X-API-Key"
+
+// RoleAPIKey is the role carried by every claim resolved from an api key
+const RoleAPIKey = "api_key"
+
+// apiKeyPrefix makes keys easy to recognise in logs and secret scanners
+const apiKeyPrefix = "gak_"
+
+// ErrInvalidAPIKey is returned when api key is malformed, unknown, revoked or expired
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// APIKeyAuth defines method for resolving an api key into a claim
+type APIKeyAuth interface {
+	VerifyAPIKey(string) (Claim, error)
+}
+
+// APIKeyClaim is the claim of a request authenticated with an api key. Scopes of the key are its permissions.
+type APIKeyClaim struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// ToJSON converts claim to json
+func (c *APIKeyClaim) ToJSON() string {
+	b, _ := json.Marshal(c)
+	return string(b)
+}
+
+// GetJWTToken returns nil as api keys are not jwt tokens
+func (c *APIKeyClaim) GetJWTToken() *jwt.Token {
+	return nil
+}
+
+// IsAdmin returns false, api keys are only granted their scopes
+func (c *APIKeyClaim) IsAdmin() bool {
+	return false
+}
+
+// GetRoles returns RoleAPIKey
+func (c *APIKeyClaim) GetRoles() []string {
+	return []string{RoleAPIKey}
+}
+
+// GetPermissions returns scopes of the api key
+func (c *APIKeyClaim) GetPermissions() []string {
+	return c.Scopes
+}
+
+// GenerateAPIKey returns a new random api key and its hash. Only the hash should be stored.
+func GenerateAPIKey() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, HashAPIKey(key), nil
+}
+
+// HashAPIKey returns hex encoded sha256 hash of the api key.
+// Keys carry 256 bits of randomness so a fast hash is enough and allows looking keys up by hash.
+func HashAPIKey(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
+
+// IsAPIKey returns true if s looks like a key returned by GenerateAPIKey
+func IsAPIKey(s string) bool {
+	return strings.HasPrefix(s, apiKeyPrefix) && len(s) > len(apiKeyPrefix)
+}
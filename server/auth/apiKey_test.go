@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, hash, err := GenerateAPIKey()
+	assert.Nil(t, err)
+	assert.True(t, IsAPIKey(key))
+	assert.Equal(t, HashAPIKey(key), hash)
+	assert.NotContains(t, hash, key)
+
+	other, otherHash, err := GenerateAPIKey()
+	assert.Nil(t, err)
+	assert.NotEqual(t, key, other)
+	assert.NotEqual(t, hash, otherHash)
+
+	assert.False(t, IsAPIKey(""))
+	assert.False(t, IsAPIKey("gak_"))
+	assert.False(t, IsAPIKey("Bearer abc"))
+}
+
+func TestAPIKeyClaim_Policy(t *testing.T) {
+	p := NewPolicy()
+	p.Grant(RoleAPIKey, "status:read")
+	c := &APIKeyClaim{ID: "1", Name: "partner", Scopes: []string{"orders:read"}}
+	assert.False(t, c.IsAdmin())
+	assert.True(t, p.HasPermission(c, "orders:read"))
+	assert.True(t, p.HasPermission(c, "status:read"))
+	assert.False(t, p.HasPermission(c, "orders:write"))
+	assert.False(t, p.HasRole(c, RoleAdmin))
+}
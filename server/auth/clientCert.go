@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"crypto/x509"
+	"errors"
+	"go-app/server/config"
+)
+
+// ErrInvalidClientCertificate is returned when a client certificate does not identify a service
+var ErrInvalidClientCertificate = errors.New("invalid client certificate")
+
+// ClientCertAuth derives the claim of a caller from its client certificate verified during tls handshake
+type ClientCertAuth interface {
+	VerifyClientCertificate(*x509.Certificate) (Claim, error)
+}
+
+// ClientCertificateAuth identifies services by the common name of their client certificate.
+// Certificates are already verified against the client CA by the tls listener, scopes configured for the common name are
+// granted to the service.
+type ClientCertificateAuth struct {
+	Scopes map[string][]string
+}
+
+// NewClientCertificateAuth returns a new ClientCertificateAuth instance with scopes of the configured clients
+func NewClientCertificateAuth(c *config.TLSConfig) *ClientCertificateAuth {
+	cca := &ClientCertificateAuth{Scopes: make(map[string][]string, len(c.Clients))}
+	for _, client := range c.Clients {
+		cca.Scopes[client.CommonName] = client.Scopes
+	}
+	return cca
+}
+
+// VerifyClientCertificate returns ServiceClaim of the certificate subject. Services which are not configured are granted no scopes.
+func (cca *ClientCertificateAuth) VerifyClientCertificate(cert *x509.Certificate) (Claim, error) {
+	if cert == nil || cert.Subject.CommonName == "" {
+		return nil, ErrInvalidClientCertificate
+	}
+	return &ServiceClaim{ID: cert.Subject.CommonName, Scopes: cca.Scopes[cert.Subject.CommonName]}, nil
+}
@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"go-app/server/config"
+	"go-app/server/storage"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const sessionKeyPrefix = "session:"
+
+// default cookie session settings used when they are not set in config
+const (
+	DefaultSessionCookieName      = "session_id"
+	DefaultSessionIdleTimeout     = 30 * time.Minute
+	DefaultSessionAbsoluteTimeout = 24 * time.Hour
+)
+
+var (
+	// ErrSessionNotFound is returned when request does not carry a session cookie or the session does not exist
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrSessionExpired is returned when session passed its idle or absolute timeout
+	ErrSessionExpired = errors.New("session expired")
+)
+
+type sessionData struct {
+	UserClaim *UserClaim `json:"claim"`
+	CreatedAt int64      `json:"created_at"`
+	LastSeen  int64      `json:"last_seen"`
+}
+
+// CookieSession implements Session by storing session data in redis or memory storage and session id in a cookie
+type CookieSession struct {
+	Storage         storage.Redis
+	Config          *config.SessionConfig
+	IdleTimeout     time.Duration
+	AbsoluteTimeout time.Duration
+	now             func() time.Time
+}
+
+// NewCookieSession returns a new CookieSession instance
+func NewCookieSession(s storage.Redis, c *config.SessionConfig) *CookieSession {
+	cs := &CookieSession{
+		Storage:         s,
+		Config:          c,
+		IdleTimeout:     time.Duration(c.IdleTimeout) * time.Minute,
+		AbsoluteTimeout: time.Duration(c.AbsoluteTimeout) * time.Minute,
+		now:             time.Now,
+	}
+	if cs.IdleTimeout == 0 {
+		cs.IdleTimeout = DefaultSessionIdleTimeout
+	}
+	if cs.AbsoluteTimeout == 0 {
+		cs.AbsoluteTimeout = DefaultSessionAbsoluteTimeout
+	}
+	return cs
+}
+
+// NewSessionID returns a new random session id
+func (cs *CookieSession) NewSessionID() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Create starts a new session for the claim and sets the session cookie.
+// The session the request was carrying, if any, is deleted so a session id is never reused across logins.
+func (cs *CookieSession) Create(w http.ResponseWriter, r *http.Request, c Claim) error {
+	uc, ok := c.(*UserClaim)
+	if !ok || uc == nil {
+		return errors.New("invalid claim: expected *UserClaim")
+	}
+	if uc.MFAPending {
+		return errors.New("session can not be created before mfa verification")
+	}
+	if id := cs.sessionID(r); id != "" {
+		if err := cs.Storage.Delete(sessionKeyPrefix + id); err != nil {
+			return err
+		}
+	}
+	now := cs.now()
+	id := cs.NewSessionID()
+	data := &sessionData{UserClaim: uc, CreatedAt: now.Unix(), LastSeen: now.Unix()}
+	if err := cs.commit(id, data); err != nil {
+		return err
+	}
+	http.SetCookie(w, cs.cookie(id, time.Unix(data.CreatedAt, 0).Add(cs.AbsoluteTimeout)))
+	return nil
+}
+
+// Get returns claim of the session the request is carrying and extends its idle timeout
+func (cs *CookieSession) Get(r *http.Request) (Claim, error) {
+	id, data, err := cs.find(r)
+	if err != nil {
+		return nil, err
+	}
+	data.LastSeen = cs.now().Unix()
+	if err := cs.commit(id, data); err != nil {
+		return nil, err
+	}
+	return data.UserClaim, nil
+}
+
+// Update replaces the claim stored in the session the request is carrying
+func (cs *CookieSession) Update(w http.ResponseWriter, r *http.Request, c Claim) error {
+	uc, ok := c.(*UserClaim)
+	if !ok || uc == nil {
+		return errors.New("invalid claim: expected *UserClaim")
+	}
+	id, data, err := cs.find(r)
+	if err != nil {
+		return err
+	}
+	data.UserClaim = uc
+	data.LastSeen = cs.now().Unix()
+	return cs.commit(id, data)
+}
+
+// Delete removes the session the request is carrying and expires the session cookie
+func (cs *CookieSession) Delete(w http.ResponseWriter, r *http.Request) error {
+	id := cs.sessionID(r)
+	if id == "" {
+		return ErrSessionNotFound
+	}
+	cookie := cs.cookie("", time.Unix(0, 0))
+	cookie.MaxAge = -1
+	http.SetCookie(w, cookie)
+	return cs.Storage.Delete(sessionKeyPrefix + id)
+}
+
+func (cs *CookieSession) find(r *http.Request) (string, *sessionData, error) {
+	id := cs.sessionID(r)
+	if id == "" {
+		return "", nil, ErrSessionNotFound
+	}
+	b, found, err := cs.Storage.Find(sessionKeyPrefix + id)
+	if err != nil {
+		return "", nil, err
+	}
+	if !found {
+		return "", nil, ErrSessionNotFound
+	}
+	data := sessionData{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return "", nil, err
+	}
+	now := cs.now()
+	if now.After(time.Unix(data.LastSeen, 0).Add(cs.IdleTimeout)) || now.After(time.Unix(data.CreatedAt, 0).Add(cs.AbsoluteTimeout)) {
+		cs.Storage.Delete(sessionKeyPrefix + id)
+		return "", nil, ErrSessionExpired
+	}
+	if data.UserClaim != nil {
+		revoked, err := isUserRevoked(cs.Storage, data.UserClaim.ID, data.CreatedAt)
+		if err != nil {
+			return "", nil, err
+		}
+		if revoked {
+			cs.Storage.Delete(sessionKeyPrefix + id)
+			return "", nil, ErrSessionNotFound
+		}
+	}
+	return id, &data, nil
+}
+
+// commit stores session data until whichever of idle or absolute timeout comes first
+func (cs *CookieSession) commit(id string, data *sessionData) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	expiry := time.Unix(data.LastSeen, 0).Add(cs.IdleTimeout)
+	if absolute := time.Unix(data.CreatedAt, 0).Add(cs.AbsoluteTimeout); absolute.Before(expiry) {
+		expiry = absolute
+	}
+	return cs.Storage.Commit(sessionKeyPrefix+id, b, expiry)
+}
+
+func (cs *CookieSession) cookieName() string {
+	if cs.Config.CookieName != "" {
+		return cs.Config.CookieName
+	}
+	return DefaultSessionCookieName
+}
+
+func (cs *CookieSession) sessionID(r *http.Request) string {
+	cookie, err := r.Cookie(cs.cookieName())
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+func (cs *CookieSession) cookie(value string, expires time.Time) *http.Cookie {
+	path := cs.Config.Path
+	if path == "" {
+		path = "/"
+	}
+	return &http.Cookie{
+		Name:     cs.cookieName(),
+		Value:    value,
+		Path:     path,
+		Domain:   cs.Config.Domain,
+		Expires:  expires,
+		Secure:   cs.Config.Secure,
+		HttpOnly: cs.Config.HTTPOnly,
+		SameSite: parseSameSite(cs.Config.SameSite),
+	}
+}
+
+func parseSameSite(s string) http.SameSite {
+	switch strings.ToLower(s) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
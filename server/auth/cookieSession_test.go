@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"go-app/server/config"
+	memorystorage "go-app/server/storage/memory"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestCookieSession() *CookieSession {
+	return NewCookieSession(memorystorage.NewMemoryStorageWithCleanupInterval(0), &config.SessionConfig{
+		CookieName:      "sid",
+		Secure:          true,
+		HTTPOnly:        true,
+		SameSite:        "strict",
+		IdleTimeout:     10,
+		AbsoluteTimeout: 60,
+	})
+}
+
+// requestWithCookies returns a new request carrying cookies set in the recorder
+func requestWithCookies(recorder *httptest.ResponseRecorder) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range recorder.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	return r
+}
+
+func TestCookieSession_Create(t *testing.T) {
+	cs := getTestCookieSession()
+	uc := getTestUserClaim()
+
+	recorder := httptest.NewRecorder()
+	assert.Nil(t, cs.Create(recorder, httptest.NewRequest(http.MethodPost, "/", nil), uc))
+
+	cookies := recorder.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "sid", cookies[0].Name)
+		assert.True(t, cookies[0].Secure)
+		assert.True(t, cookies[0].HttpOnly)
+		assert.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+		assert.Equal(t, "/", cookies[0].Path)
+	}
+
+	got, err := cs.Get(requestWithCookies(recorder))
+	assert.Nil(t, err)
+	assert.Equal(t, uc, got)
+}
+
+func TestCookieSession_RegenerateOnCreate(t *testing.T) {
+	cs := getTestCookieSession()
+
+	first := httptest.NewRecorder()
+	assert.Nil(t, cs.Create(first, httptest.NewRequest(http.MethodPost, "/", nil), getTestUserClaim()))
+
+	second := httptest.NewRecorder()
+	assert.Nil(t, cs.Create(second, requestWithCookies(first), getTestUserClaim()))
+	assert.NotEqual(t, first.Result().Cookies()[0].Value, second.Result().Cookies()[0].Value)
+
+	_, err := cs.Get(requestWithCookies(first))
+	assert.Equal(t, ErrSessionNotFound, err)
+	_, err = cs.Get(requestWithCookies(second))
+	assert.Nil(t, err)
+}
+
+func TestCookieSession_Timeouts(t *testing.T) {
+	tests := []struct {
+		name string
+		// requests made after the session was created, relative to creation time
+		requests []time.Duration
+		wantErr  error
+	}{
+		{
+			name:     "Active Session",
+			requests: []time.Duration{5 * time.Minute},
+			wantErr:  nil,
+		},
+		{
+			name:     "Idle Timeout",
+			requests: []time.Duration{11 * time.Minute},
+			wantErr:  ErrSessionExpired,
+		},
+		{
+			name:     "Activity Extends Idle Timeout",
+			requests: []time.Duration{8 * time.Minute, 16 * time.Minute, 24 * time.Minute},
+			wantErr:  nil,
+		},
+		{
+			name:     "Absolute Timeout",
+			requests: []time.Duration{9 * time.Minute, 18 * time.Minute, 27 * time.Minute, 36 * time.Minute, 45 * time.Minute, 54 * time.Minute, 61 * time.Minute},
+			wantErr:  ErrSessionExpired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := getTestCookieSession()
+			created := time.Now()
+			recorder := httptest.NewRecorder()
+			assert.Nil(t, cs.Create(recorder, httptest.NewRequest(http.MethodPost, "/", nil), getTestUserClaim()))
+
+			var err error
+			for _, d := range tt.requests {
+				at := created.Add(d)
+				cs.now = func() time.Time { return at }
+				_, err = cs.Get(requestWithCookies(recorder))
+			}
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestCookieSession_UpdateAndDelete(t *testing.T) {
+	cs := getTestCookieSession()
+	recorder := httptest.NewRecorder()
+	assert.Nil(t, cs.Create(recorder, httptest.NewRequest(http.MethodPost, "/", nil), getTestUserClaim()))
+
+	updated := getTestUserClaim()
+	updated.Type = "admin"
+	assert.Nil(t, cs.Update(httptest.NewRecorder(), requestWithCookies(recorder), updated))
+	got, err := cs.Get(requestWithCookies(recorder))
+	assert.Nil(t, err)
+	assert.Equal(t, updated, got)
+
+	deleteRecorder := httptest.NewRecorder()
+	assert.Nil(t, cs.Delete(deleteRecorder, requestWithCookies(recorder)))
+	assert.Equal(t, -1, deleteRecorder.Result().Cookies()[0].MaxAge)
+	_, err = cs.Get(requestWithCookies(recorder))
+	assert.Equal(t, ErrSessionNotFound, err)
+}
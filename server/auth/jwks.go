@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// JWKSProvider is implemented by token authenticators which can publish their public keys
+type JWKSProvider interface {
+	JWKS() *JWKS
+}
+
+// JWKS represents a JSON Web Key Set document (RFC 7517)
+type JWKS struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// JWK represents a single public JSON Web Key
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA public key attributes
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC and OKP public key attributes
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// NewJWK returns public JWK of the signing key. HMAC keys can not be published and return an error.
+func NewJWK(k *SigningKey) (*JWK, error) {
+	jwk := &JWK{Kid: k.ID, Use: "sig", Alg: k.Method.Alg()}
+	switch pub := k.PublicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encodeBase64URL(pub.N.Bytes())
+		jwk.E = encodeBase64URL(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = encodeBase64URL(padBytes(pub.X.Bytes(), size))
+		jwk.Y = encodeBase64URL(padBytes(pub.Y.Bytes(), size))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = encodeBase64URL(pub)
+	default:
+		return nil, fmt.Errorf("key %q can not be published", k.ID)
+	}
+	return jwk, nil
+}
+
+// SigningKey returns verify only SigningKey parsed from the JWK
+func (j *JWK) SigningKey() (*SigningKey, error) {
+	k := &SigningKey{ID: j.Kid}
+	alg := j.Alg
+	switch j.Kty {
+	case "RSA":
+		n, err := decodeBase64URL(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBase64URL(j.E)
+		if err != nil {
+			return nil, err
+		}
+		k.PublicKey = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if alg == "" {
+			alg = jwt.SigningMethodRS256.Alg()
+		}
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve, alg = elliptic.P256(), defaultAlg(alg, jwt.SigningMethodES256)
+		case "P-384":
+			curve, alg = elliptic.P384(), defaultAlg(alg, jwt.SigningMethodES384)
+		case "P-521":
+			curve, alg = elliptic.P521(), defaultAlg(alg, jwt.SigningMethodES512)
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err := decodeBase64URL(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBase64URL(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("invalid EC public key")
+		}
+		k.PublicKey = pub
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err := decodeBase64URL(j.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 public key")
+		}
+		k.PublicKey = ed25519.PublicKey(x)
+		alg = defaultAlg(alg, SigningMethodEdDSA)
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", j.Kty)
+	}
+
+	k.Method = jwt.GetSigningMethod(alg)
+	if k.Method == nil || !methodAcceptsKey(k.Method, k.PublicKey) {
+		return nil, fmt.Errorf("key %q does not match algorithm %s", j.Kid, alg)
+	}
+	return k, nil
+}
+
+func defaultAlg(alg string, m jwt.SigningMethod) string {
+	if alg == "" {
+		return m.Alg()
+	}
+	return alg
+}
+
+func padBytes(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	out := make([]byte, size)
+	copy(out[size-len(b):], b)
+	return out
+}
+
+func encodeBase64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
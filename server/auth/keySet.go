@@ -0,0 +1,287 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"go-app/server/config"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// SigningKey is a single jwt key identified by its ID (`kid` header).
+// PrivateKey is nil for keys which are only used to verify tokens.
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey interface{}
+	PublicKey  interface{}
+	ActiveFrom time.Time
+}
+
+// CanSign returns true if key can be used to sign tokens
+func (k *SigningKey) CanSign() bool {
+	return k.PrivateKey != nil
+}
+
+// KeySet contains all the keys used to sign and verify tokens.
+// Only the newest active key signs new tokens, older keys keep verifying tokens for the grace period after they
+// were replaced.
+type KeySet struct {
+	mu          sync.RWMutex
+	keys        []*SigningKey
+	gracePeriod time.Duration
+	now         func() time.Time
+}
+
+// NewKeySet returns a new KeySet instance containing keys
+func NewKeySet(gracePeriod time.Duration, keys ...*SigningKey) (*KeySet, error) {
+	ks := &KeySet{gracePeriod: gracePeriod, now: time.Now}
+	if err := ks.SetKeys(keys...); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// NewKeySetFromConfig returns a new KeySet instance with keys loaded from configuration.
+// If no keys are configured, JWTSignKey is used as the only HS256 key.
+func NewKeySetFromConfig(c *config.TokenAuthConfig) (*KeySet, error) {
+	gracePeriod := time.Duration(c.GracePeriod) * time.Minute
+	if len(c.Keys) == 0 {
+		if c.JWTSignKey == "" {
+			return nil, errors.New("token auth: either jwtSignKey or keys must be configured")
+		}
+		return NewKeySet(gracePeriod, &SigningKey{
+			Method:     jwt.SigningMethodHS256,
+			PrivateKey: []byte(c.JWTSignKey),
+			PublicKey:  []byte(c.JWTSignKey),
+		})
+	}
+	var keys []*SigningKey
+	for _, kc := range c.Keys {
+		k, err := NewSigningKeyFromConfig(&kc)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return NewKeySet(gracePeriod, keys...)
+}
+
+// SetKeys replaces all the keys of the key set
+func (ks *KeySet) SetKeys(keys ...*SigningKey) error {
+	ids := make(map[string]bool)
+	for _, k := range keys {
+		if ids[k.ID] {
+			return fmt.Errorf("token auth: duplicate key id %q", k.ID)
+		}
+		ids[k.ID] = true
+	}
+	sorted := make([]*SigningKey, len(keys))
+	copy(sorted, keys)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ActiveFrom.Before(sorted[j].ActiveFrom)
+	})
+	ks.mu.Lock()
+	ks.keys = sorted
+	ks.mu.Unlock()
+	return nil
+}
+
+// Keys returns all the keys including the ones which are not active yet or are past their grace period
+func (ks *KeySet) Keys() []*SigningKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	keys := make([]*SigningKey, len(ks.keys))
+	copy(keys, ks.keys)
+	return keys
+}
+
+// current returns index of the newest active key or -1 if none of the key is active.
+func (ks *KeySet) current(now time.Time) int {
+	for i := len(ks.keys) - 1; i >= 0; i-- {
+		if !ks.keys[i].ActiveFrom.After(now) {
+			return i
+		}
+	}
+	return -1
+}
+
+// SigningKey returns the newest active key which is used to sign new tokens
+func (ks *KeySet) SigningKey() (*SigningKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	i := ks.current(ks.now())
+	if i < 0 || !ks.keys[i].CanSign() {
+		return nil, errors.New("token auth: no active signing key")
+	}
+	return ks.keys[i], nil
+}
+
+// VerificationKey returns the key identified by kid if it is still allowed to verify tokens.
+// Tokens without kid are verified using the newest active key.
+func (ks *KeySet) VerificationKey(kid string) (*SigningKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	now := ks.now()
+	current := ks.current(now)
+	if current < 0 {
+		return nil, errors.New("token auth: no active key")
+	}
+	if kid == "" {
+		return ks.keys[current], nil
+	}
+	for i := current; i >= 0; i-- {
+		if ks.keys[i].ID != kid {
+			continue
+		}
+		if ks.retired(i, current, now) {
+			return nil, fmt.Errorf("token auth: key %q is retired", kid)
+		}
+		return ks.keys[i], nil
+	}
+	return nil, fmt.Errorf("token auth: unknown key %q", kid)
+}
+
+// retired returns true if key at index i was replaced by a newer key more than grace period ago
+func (ks *KeySet) retired(i, current int, now time.Time) bool {
+	return i < current && !now.Before(ks.keys[i+1].ActiveFrom.Add(ks.gracePeriod))
+}
+
+// JWKS returns public keys which are either usable now or will become active in future, so that verifiers can
+// cache them before the rotation. HMAC keys are never published.
+func (ks *KeySet) JWKS() *JWKS {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	now := ks.now()
+	current := ks.current(now)
+	jwks := &JWKS{Keys: []*JWK{}}
+	for i, k := range ks.keys {
+		if ks.retired(i, current, now) {
+			continue
+		}
+		if jwk, err := NewJWK(k); err == nil {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+	return jwks
+}
+
+// NewSigningKeyFromConfig returns a new SigningKey loaded from secret or PEM encoded key files
+func NewSigningKeyFromConfig(c *config.TokenKeyConfig) (*SigningKey, error) {
+	alg := c.Algorithm
+	if alg == "" {
+		alg = jwt.SigningMethodHS256.Alg()
+	}
+	method := jwt.GetSigningMethod(alg)
+	if method == nil || method.Alg() == "none" {
+		return nil, fmt.Errorf("token auth: unsupported algorithm %q for key %q", alg, c.ID)
+	}
+	k := &SigningKey{
+		ID:         c.ID,
+		Method:     method,
+		ActiveFrom: c.ActiveFrom,
+	}
+
+	if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+		if c.Secret == "" {
+			return nil, fmt.Errorf("token auth: secret is required for key %q", c.ID)
+		}
+		k.PrivateKey = []byte(c.Secret)
+		k.PublicKey = []byte(c.Secret)
+		return k, nil
+	}
+
+	switch {
+	case c.PrivateKeyFile != "":
+		data, err := ioutil.ReadFile(c.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("token auth: failed to read private key of %q: %w", c.ID, err)
+		}
+		priv, err := ParsePrivateKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("token auth: failed to parse private key of %q: %w", c.ID, err)
+		}
+		k.PrivateKey = priv
+		k.PublicKey = priv.Public()
+	case c.PublicKeyFile != "":
+		data, err := ioutil.ReadFile(c.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("token auth: failed to read public key of %q: %w", c.ID, err)
+		}
+		pub, err := ParsePublicKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("token auth: failed to parse public key of %q: %w", c.ID, err)
+		}
+		k.PublicKey = pub
+	default:
+		return nil, fmt.Errorf("token auth: privateKeyFile or publicKeyFile is required for key %q", c.ID)
+	}
+
+	if !methodAcceptsKey(method, k.PublicKey) {
+		return nil, fmt.Errorf("token auth: key %q does not match algorithm %s", c.ID, alg)
+	}
+	return k, nil
+}
+
+// methodAcceptsKey checks if public key type can be used with the signing method
+func methodAcceptsKey(method jwt.SigningMethod, pub interface{}) bool {
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		_, ok := pub.(*rsa.PublicKey)
+		return ok
+	case *jwt.SigningMethodECDSA:
+		_, ok := pub.(*ecdsa.PublicKey)
+		return ok
+	case *SigningMethodEd25519:
+		_, ok := pub.(ed25519.PublicKey)
+		return ok
+	}
+	return false
+}
+
+// ParsePrivateKeyPEM parses PKCS#8, PKCS#1 (RSA) or SEC 1 (EC) PEM encoded private key
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM data")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key type")
+		}
+		return signer, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("unsupported private key format")
+}
+
+// ParsePublicKeyPEM parses PKIX or PKCS#1 (RSA) PEM encoded public key
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM data")
+	}
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("unsupported public key format")
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"go-app/server/config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestKeyPEM generates a private key for alg and writes it PKCS#8 encoded into dir
+func writeTestKeyPEM(t *testing.T, dir, alg string) string {
+	var key interface{}
+	var err error
+	switch alg {
+	case "RS256", "PS256":
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "EdDSA":
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		t.Fatalf("failed to generate %s key: %s", alg, err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal %s key: %s", alg, err)
+	}
+	path := filepath.Join(dir, alg+".pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("failed to write %s key: %s", alg, err)
+	}
+	return path
+}
+
+func TestTokenAuthentication_Algorithms(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keys")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name string
+		key  config.TokenKeyConfig
+	}{
+		{
+			name: "HS256",
+			key:  config.TokenKeyConfig{ID: "hs", Algorithm: "HS256", Secret: "secret"},
+		},
+		{
+			name: "RS256",
+			key:  config.TokenKeyConfig{ID: "rs", Algorithm: "RS256", PrivateKeyFile: writeTestKeyPEM(t, dir, "RS256")},
+		},
+		{
+			name: "PS256",
+			key:  config.TokenKeyConfig{ID: "ps", Algorithm: "PS256", PrivateKeyFile: writeTestKeyPEM(t, dir, "PS256")},
+		},
+		{
+			name: "ES256",
+			key:  config.TokenKeyConfig{ID: "es", Algorithm: "ES256", PrivateKeyFile: writeTestKeyPEM(t, dir, "ES256")},
+		},
+		{
+			name: "EdDSA",
+			key:  config.TokenKeyConfig{ID: "ed", Algorithm: "EdDSA", PrivateKeyFile: writeTestKeyPEM(t, dir, "EdDSA")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tAuth, err := NewTokenAuthentication(&config.TokenAuthConfig{Keys: []config.TokenKeyConfig{tt.key}})
+			if !assert.Nil(t, err) {
+				return
+			}
+			uc := getTestUserClaim()
+			tokenString, err := tAuth.SignToken(uc)
+			assert.Nil(t, err)
+			got, err := tAuth.VerifyToken(tokenString)
+			assert.Nil(t, err)
+			assert.Equal(t, uc, got)
+		})
+	}
+}
+
+func TestTokenAuthentication_KeyMismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keys")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = NewTokenAuthentication(&config.TokenAuthConfig{Keys: []config.TokenKeyConfig{
+		{ID: "rs", Algorithm: "ES256", PrivateKeyFile: writeTestKeyPEM(t, dir, "RS256")},
+	}})
+	assert.NotNil(t, err)
+
+	_, err = NewTokenAuthentication(&config.TokenAuthConfig{Keys: []config.TokenKeyConfig{
+		{ID: "none", Algorithm: "none"},
+	}})
+	assert.NotNil(t, err)
+}
+
+func TestTokenAuthentication_AlgorithmConfusion(t *testing.T) {
+	tAuth, err := NewTokenAuthentication(&config.TokenAuthConfig{Keys: []config.TokenKeyConfig{
+		{ID: "k1", Algorithm: "HS256", Secret: "secret"},
+	}})
+	assert.Nil(t, err)
+
+	// token claims to be HS512 while key k1 only allows HS256
+	token := jwt.NewWithClaims(jwt.SigningMethodHS512, getTestUserClaim())
+	token.Header["kid"] = "k1"
+	signed, _ := token.SignedString([]byte("secret"))
+	_, err = tAuth.VerifyToken(base64.StdEncoding.EncodeToString([]byte(signed)))
+	assert.NotNil(t, err)
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	now := time.Now()
+	old := &SigningKey{ID: "old", Method: jwt.SigningMethodHS256, PrivateKey: []byte("old"), PublicKey: []byte("old"), ActiveFrom: now.Add(-48 * time.Hour)}
+	current := &SigningKey{ID: "current", Method: jwt.SigningMethodHS256, PrivateKey: []byte("current"), PublicKey: []byte("current"), ActiveFrom: now.Add(-time.Hour)}
+	next := &SigningKey{ID: "next", Method: jwt.SigningMethodHS256, PrivateKey: []byte("next"), PublicKey: []byte("next"), ActiveFrom: now.Add(time.Hour)}
+
+	tests := []struct {
+		name        string
+		gracePeriod time.Duration
+		kid         string
+		wantSigner  string
+		wantErr     bool
+	}{
+		{
+			name:        "Newest Active Key Signs And Verifies",
+			gracePeriod: 2 * time.Hour,
+			kid:         "current",
+			wantSigner:  "current",
+		},
+		{
+			name:        "Old Key Verifies Within Grace Period",
+			gracePeriod: 2 * time.Hour,
+			kid:         "old",
+			wantSigner:  "current",
+		},
+		{
+			name:        "Old Key Rejected After Grace Period",
+			gracePeriod: 30 * time.Minute,
+			kid:         "old",
+			wantSigner:  "current",
+			wantErr:     true,
+		},
+		{
+			name:        "Future Key Does Not Verify",
+			gracePeriod: 2 * time.Hour,
+			kid:         "next",
+			wantSigner:  "current",
+			wantErr:     true,
+		},
+		{
+			name:        "Unknown Key",
+			gracePeriod: 2 * time.Hour,
+			kid:         "unknown",
+			wantSigner:  "current",
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks, err := NewKeySet(tt.gracePeriod, next, old, current)
+			assert.Nil(t, err)
+			signer, err := ks.SigningKey()
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantSigner, signer.ID)
+			_, err = ks.VerificationKey(tt.kid)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("KeySet.VerificationKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeySet_DuplicateKeyID(t *testing.T) {
+	k := &SigningKey{ID: "k1", Method: jwt.SigningMethodHS256, PrivateKey: []byte("a"), PublicKey: []byte("a")}
+	_, err := NewKeySet(0, k, k)
+	assert.NotNil(t, err)
+}
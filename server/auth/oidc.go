@@ -0,0 +1,356 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-app/server/config"
+	"go-app/server/storage"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const oidcStateKeyPrefix = "oidc_state:"
+
+// OIDCStateCookieName is the cookie binding a pending login to the browser which started it
+const OIDCStateCookieName = "oidc_state"
+
+// DefaultOIDCStateExpiresAt is used when OIDCConfig.StateExpiresAt is not set
+const DefaultOIDCStateExpiresAt = 10 * time.Minute
+
+// time claims of id tokens are accepted with this much difference between provider and local clock
+const oidcClockSkew = time.Minute
+
+var (
+	// ErrInvalidOIDCState is returned when the state of a callback is unknown, expired or already used
+	ErrInvalidOIDCState = errors.New("oidc: invalid or expired state")
+	// ErrInvalidIDToken is returned when the id token returned by the provider fails validation
+	ErrInvalidIDToken = errors.New("oidc: invalid id token")
+)
+
+// OIDCDiscovery contains the provider metadata used by the relying party (OpenID Connect Discovery section 3)
+type OIDCDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Audience is the `aud` claim which providers send either as a single string or as an array
+type Audience []string
+
+// UnmarshalJSON accepts both forms of the audience claim
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = Audience{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// Contains returns true if aud is one of the audiences
+func (a Audience) Contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// IDTokenClaims contains the claims of an id token used to identify the user
+type IDTokenClaims struct {
+	Issuer          string   `json:"iss"`
+	Subject         string   `json:"sub"`
+	Audience        Audience `json:"aud"`
+	AuthorizedParty string   `json:"azp,omitempty"`
+	ExpiresAt       int64    `json:"exp"`
+	IssuedAt        int64    `json:"iat"`
+	Nonce           string   `json:"nonce,omitempty"`
+	Email           string   `json:"email,omitempty"`
+	EmailVerified   bool     `json:"email_verified,omitempty"`
+	Name            string   `json:"name,omitempty"`
+}
+
+// Valid is called by the jwt parser, claims are validated by VerifyIDToken using the client clock
+func (c *IDTokenClaims) Valid() error {
+	return nil
+}
+
+// oidcLoginState is stored for every login started at the provider until its callback is received
+type oidcLoginState struct {
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// OIDCClient signs in users with an external OpenID Connect provider using authorization code flow with PKCE.
+// State and nonce of pending logins are kept in redis or memory storage so any instance can complete the login.
+type OIDCClient struct {
+	Config         *config.OIDCConfig
+	States         storage.Redis
+	Client         *http.Client
+	StateExpiresAt time.Duration
+
+	mu        sync.Mutex
+	discovery *OIDCDiscovery
+	keys      *RemoteKeySet
+	now       func() time.Time
+}
+
+// NewOIDCClient returns a new OIDCClient instance. The discovery document is fetched on first use.
+func NewOIDCClient(s storage.Redis, c *config.OIDCConfig) *OIDCClient {
+	expiresAt := time.Duration(c.StateExpiresAt) * time.Minute
+	if expiresAt == 0 {
+		expiresAt = DefaultOIDCStateExpiresAt
+	}
+	return &OIDCClient{
+		Config:         c,
+		States:         s,
+		Client:         &http.Client{Timeout: 10 * time.Second},
+		StateExpiresAt: expiresAt,
+		now:            time.Now,
+	}
+}
+
+// Discover returns the discovery document of the provider. It is fetched once, failed fetches are retried on next call.
+func (o *OIDCClient) Discover() (*OIDCDiscovery, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.discovery != nil {
+		return o.discovery, nil
+	}
+
+	resp, err := o.Client.Get(strings.TrimSuffix(o.Config.Issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, fmt.Errorf("oidc: failed to fetch discovery document: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc: failed to fetch discovery document: unexpected status %d", resp.StatusCode)
+	}
+	d := OIDCDiscovery{}
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return nil, fmt.Errorf("oidc: failed to decode discovery document: %w", err)
+	}
+	// issuer must be exactly the configured one, see OpenID Connect Discovery section 4.3
+	if d.Issuer != o.Config.Issuer {
+		return nil, fmt.Errorf("oidc: discovery document issuer %q does not match %q", d.Issuer, o.Config.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery document is missing required endpoints")
+	}
+	o.keys = NewRemoteKeySet(d.JWKSURI, 15*time.Minute)
+	o.keys.Client = o.Client
+	o.discovery = &d
+	return o.discovery, nil
+}
+
+// AuthorizationURL starts a new login and returns the provider url the user has to be redirected to together with the state of the login.
+// The state must be bound to the browser with StateCookie so the callback can not be completed by another browser.
+func (o *OIDCClient) AuthorizationURL() (string, string, error) {
+	d, err := o.Discover()
+	if err != nil {
+		return "", "", err
+	}
+	state, nonce, verifier := randomOIDCString(), randomOIDCString(), randomOIDCString()
+	data, err := json.Marshal(&oidcLoginState{Nonce: nonce, CodeVerifier: verifier})
+	if err != nil {
+		return "", "", err
+	}
+	if err := o.States.Commit(oidcStateKeyPrefix+state, data, o.now().Add(o.StateExpiresAt)); err != nil {
+		return "", "", err
+	}
+
+	u, err := url.Parse(d.AuthorizationEndpoint)
+	if err != nil {
+		return "", "", fmt.Errorf("oidc: invalid authorization endpoint: %w", err)
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", o.Config.ClientID)
+	q.Set("redirect_uri", o.Config.RedirectURL)
+	q.Set("scope", strings.Join(o.scopes(), " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), state, nil
+}
+
+// StateCookie returns the cookie binding state to the browser starting the login. It holds a hash of state and
+// is sent only to the callback.
+func (o *OIDCClient) StateCookie(state string) *http.Cookie {
+	c := o.stateCookie(hashOIDCState(state))
+	c.Expires = o.now().Add(o.StateExpiresAt)
+	return c
+}
+
+// ClearStateCookie returns the cookie removing the state cookie from the browser
+func (o *OIDCClient) ClearStateCookie() *http.Cookie {
+	c := o.stateCookie("")
+	c.MaxAge = -1
+	c.Expires = time.Unix(0, 0)
+	return c
+}
+
+// VerifyStateCookie returns ErrInvalidOIDCState unless the request carries the state cookie of state
+func (o *OIDCClient) VerifyStateCookie(r *http.Request, state string) error {
+	c, err := r.Cookie(OIDCStateCookieName)
+	if err != nil || state == "" {
+		return ErrInvalidOIDCState
+	}
+	if subtle.ConstantTimeCompare([]byte(c.Value), []byte(hashOIDCState(state))) != 1 {
+		return ErrInvalidOIDCState
+	}
+	return nil
+}
+
+func (o *OIDCClient) stateCookie(value string) *http.Cookie {
+	path, secure := "/", false
+	if u, err := url.Parse(o.Config.RedirectURL); err == nil {
+		if u.Path != "" {
+			path = u.Path
+		}
+		secure = u.Scheme == "https"
+	}
+	// lax cookies are sent on the top level redirect back from the provider
+	return &http.Cookie{
+		Name:     OIDCStateCookieName,
+		Value:    value,
+		Path:     path,
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func hashOIDCState(state string) string {
+	h := sha256.Sum256([]byte(state))
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
+
+// Exchange completes the login started with state: it exchanges the code for tokens and returns the verified id token claims.
+// A state can be used only once.
+func (o *OIDCClient) Exchange(state, code string) (*IDTokenClaims, error) {
+	if state == "" || code == "" {
+		return nil, ErrInvalidOIDCState
+	}
+	// state is consumed in a single step so only one of concurrent callbacks carrying it can complete the login
+	data, ok, err := o.States.FindAndDelete(oidcStateKeyPrefix + state)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidOIDCState
+	}
+	st := oidcLoginState{}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, ErrInvalidOIDCState
+	}
+
+	d, err := o.Discover()
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {o.Config.RedirectURL},
+		"code_verifier": {st.CodeVerifier},
+	}
+	if o.Config.ClientSecret == "" {
+		form.Set("client_id", o.Config.ClientID)
+	}
+	req, err := http.NewRequest(http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if o.Config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(o.Config.ClientID), url.QueryEscape(string(o.Config.ClientSecret)))
+	}
+	resp, err := o.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc: token request failed: unexpected status %d", resp.StatusCode)
+	}
+	tokens := struct {
+		IDToken string `json:"id_token"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("oidc: failed to decode token response: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("%w: missing in token response", ErrInvalidIDToken)
+	}
+	return o.VerifyIDToken(tokens.IDToken, st.Nonce)
+}
+
+// VerifyIDToken verifies signature, issuer, audience, expiry and nonce of the id token (OpenID Connect Core section 3.1.3.7)
+func (o *OIDCClient) VerifyIDToken(raw, nonce string) (*IDTokenClaims, error) {
+	d, err := o.Discover()
+	if err != nil {
+		return nil, err
+	}
+	c := IDTokenClaims{}
+	if _, err := jwt.ParseWithClaims(raw, &c, keyFunc(o.keys)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	now := o.now()
+	switch {
+	case c.Issuer != d.Issuer:
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidIDToken, c.Issuer)
+	case !c.Audience.Contains(o.Config.ClientID):
+		return nil, fmt.Errorf("%w: client is not an audience", ErrInvalidIDToken)
+	case (len(c.Audience) > 1 || c.AuthorizedParty != "") && c.AuthorizedParty != o.Config.ClientID:
+		return nil, fmt.Errorf("%w: client is not the authorized party", ErrInvalidIDToken)
+	case c.ExpiresAt == 0 || now.After(time.Unix(c.ExpiresAt, 0).Add(oidcClockSkew)):
+		return nil, fmt.Errorf("%w: token is expired", ErrInvalidIDToken)
+	case now.Add(oidcClockSkew).Before(time.Unix(c.IssuedAt, 0)):
+		return nil, fmt.Errorf("%w: token is issued in the future", ErrInvalidIDToken)
+	case c.Nonce != nonce:
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	case c.Subject == "":
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+	return &c, nil
+}
+
+// scopes returns configured scopes making sure openid is always requested
+func (o *OIDCClient) scopes() []string {
+	for _, s := range o.Config.Scopes {
+		if s == "openid" {
+			return o.Config.Scopes
+		}
+	}
+	return append([]string{"openid"}, o.Config.Scopes...)
+}
+
+func randomOIDCString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,280 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"go-app/server/config"
+	memorystorage "go-app/server/storage/memory"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeOIDCProvider is a minimal openid provider. Codes are issued by the test with Authorize instead of a login page.
+type fakeOIDCProvider struct {
+	*httptest.Server
+	t     *testing.T
+	key   *SigningKey
+	mu    sync.Mutex
+	codes map[string]fakeOIDCCode
+	// claims overrides claims of the issued id tokens
+	claims func(*IDTokenClaims)
+}
+
+type fakeOIDCCode struct {
+	nonce     string
+	challenge string
+}
+
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+	p := &fakeOIDCProvider{t: t, key: newTestRSAKey(t, "provider", time.Time{}), codes: map[string]fakeOIDCCode{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&OIDCDiscovery{
+			Issuer:                p.URL,
+			AuthorizationEndpoint: p.URL + "/authorize",
+			TokenEndpoint:         p.URL + "/token",
+			JWKSURI:               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		jwk, _ := NewJWK(p.key)
+		json.NewEncoder(w).Encode(&JWKS{Keys: []*JWK{jwk}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "client" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		p.mu.Lock()
+		code, ok := p.codes[r.PostFormValue("code")]
+		delete(p.codes, r.PostFormValue("code"))
+		p.mu.Unlock()
+		h := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(h[:]) != code.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "at", "token_type": "Bearer", "id_token": p.IDToken(code.nonce)})
+	})
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+// Authorize simulates the user signing in at the provider and returns the callback query
+func (p *fakeOIDCProvider) Authorize(authURL string) url.Values {
+	u, err := url.Parse(authURL)
+	assert.Nil(p.t, err)
+	q := u.Query()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	code := randomOIDCString()
+	p.codes[code] = fakeOIDCCode{nonce: q.Get("nonce"), challenge: q.Get("code_challenge")}
+	return url.Values{"code": {code}, "state": {q.Get("state")}}
+}
+
+// IDToken returns an id token signed by the provider
+func (p *fakeOIDCProvider) IDToken(nonce string) string {
+	c := IDTokenClaims{
+		Issuer:        p.URL,
+		Subject:       "provider-user",
+		Audience:      Audience{"client"},
+		ExpiresAt:     time.Now().Add(time.Hour).Unix(),
+		IssuedAt:      time.Now().Unix(),
+		Nonce:         nonce,
+		Email:         "user@example.com",
+		EmailVerified: true,
+	}
+	if p.claims != nil {
+		p.claims(&c)
+	}
+	token := jwt.NewWithClaims(p.key.Method, &c)
+	token.Header["kid"] = p.key.ID
+	s, err := token.SignedString(p.key.PrivateKey)
+	assert.Nil(p.t, err)
+	return s
+}
+
+func newTestOIDCClient(p *fakeOIDCProvider) *OIDCClient {
+	return NewOIDCClient(memorystorage.NewMemoryStorageWithCleanupInterval(0), &config.OIDCConfig{
+		Issuer:       p.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "https://app.example.com/callback",
+		Scopes:       []string{"email"},
+	})
+}
+
+func TestOIDCClient_Login(t *testing.T) {
+	p := newFakeOIDCProvider(t)
+	defer p.Close()
+	o := newTestOIDCClient(p)
+
+	authURL, state, err := o.AuthorizationURL()
+	assert.Nil(t, err)
+	u, _ := url.Parse(authURL)
+	assert.Equal(t, p.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	assert.Equal(t, "client", u.Query().Get("client_id"))
+	assert.Equal(t, "https://app.example.com/callback", u.Query().Get("redirect_uri"))
+	assert.Equal(t, "openid email", u.Query().Get("scope"))
+	assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+	assert.NotEmpty(t, u.Query().Get("nonce"))
+	assert.Equal(t, state, u.Query().Get("state"))
+
+	callback := p.Authorize(authURL)
+	claims, err := o.Exchange(callback.Get("state"), callback.Get("code"))
+	assert.Nil(t, err)
+	assert.Equal(t, "provider-user", claims.Subject)
+	assert.Equal(t, p.URL, claims.Issuer)
+	assert.Equal(t, "user@example.com", claims.Email)
+	assert.True(t, claims.EmailVerified)
+
+	// state is single use
+	_, err = o.Exchange(callback.Get("state"), callback.Get("code"))
+	assert.Equal(t, ErrInvalidOIDCState, err)
+	_, err = o.Exchange("unknown", "code")
+	assert.Equal(t, ErrInvalidOIDCState, err)
+
+	// concurrent callbacks carrying the same state, each with a valid code, complete only one login
+	authURL, _, _ = o.AuthorizationURL()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	completed := 0
+	for i := 0; i < 10; i++ {
+		callback := p.Authorize(authURL)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := o.Exchange(callback.Get("state"), callback.Get("code")); err == nil {
+				mu.Lock()
+				completed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, completed)
+
+	// state expires
+	authURL, _, _ = o.AuthorizationURL()
+	callback = p.Authorize(authURL)
+	o.States.Delete(oidcStateKeyPrefix + callback.Get("state"))
+	_, err = o.Exchange(callback.Get("state"), callback.Get("code"))
+	assert.Equal(t, ErrInvalidOIDCState, err)
+
+	// id token issued for another login is rejected
+	first, _, _ := o.AuthorizationURL()
+	second, _, _ := o.AuthorizationURL()
+	callback = p.Authorize(first)
+	callback.Set("state", p.Authorize(second).Get("state"))
+	_, err = o.Exchange(callback.Get("state"), callback.Get("code"))
+	assert.NotNil(t, err)
+}
+
+func TestOIDCClient_StateCookie(t *testing.T) {
+	p := newFakeOIDCProvider(t)
+	defer p.Close()
+	o := newTestOIDCClient(p)
+
+	c := o.StateCookie("state")
+	assert.Equal(t, OIDCStateCookieName, c.Name)
+	assert.NotEqual(t, "state", c.Value, "cookie holds a hash of state")
+	assert.Equal(t, "/callback", c.Path)
+	assert.True(t, c.HttpOnly)
+	assert.True(t, c.Secure)
+	assert.Equal(t, http.SameSiteLaxMode, c.SameSite)
+
+	r := httptest.NewRequest(http.MethodGet, "/callback", nil)
+	assert.Equal(t, ErrInvalidOIDCState, o.VerifyStateCookie(r, "state"), "callback without cookie is rejected")
+	r.AddCookie(c)
+	assert.Nil(t, o.VerifyStateCookie(r, "state"))
+	assert.Equal(t, ErrInvalidOIDCState, o.VerifyStateCookie(r, "other"))
+	assert.Equal(t, ErrInvalidOIDCState, o.VerifyStateCookie(r, ""))
+
+	assert.True(t, o.ClearStateCookie().MaxAge < 0)
+}
+
+func TestOIDCClient_Discover(t *testing.T) {
+	p := newFakeOIDCProvider(t)
+	defer p.Close()
+
+	o := newTestOIDCClient(p)
+	o.Config.Issuer = p.URL + "/"
+	_, err := o.Discover()
+	assert.NotNil(t, err, "issuer of discovery document must match the configured one")
+
+	o = newTestOIDCClient(p)
+	d, err := o.Discover()
+	assert.Nil(t, err)
+	assert.Equal(t, p.URL+"/token", d.TokenEndpoint)
+}
+
+func TestOIDCClient_VerifyIDToken(t *testing.T) {
+	p := newFakeOIDCProvider(t)
+	defer p.Close()
+	o := newTestOIDCClient(p)
+	other := newTestRSAKey(t, "provider", time.Time{})
+
+	type TestCase struct {
+		Name    string
+		Claims  func(*IDTokenClaims)
+		Token   func() string
+		Nonce   string
+		WantErr bool
+	}
+	tests := []TestCase{
+		{Name: "valid", Nonce: "n"},
+		{Name: "valid with audience list", Nonce: "n", Claims: func(c *IDTokenClaims) {
+			c.Audience = Audience{"client", "other"}
+			c.AuthorizedParty = "client"
+		}},
+		{Name: "wrong nonce", Nonce: "other", WantErr: true},
+		{Name: "wrong issuer", Nonce: "n", WantErr: true, Claims: func(c *IDTokenClaims) { c.Issuer = "https://evil.example.com" }},
+		{Name: "wrong audience", Nonce: "n", WantErr: true, Claims: func(c *IDTokenClaims) { c.Audience = Audience{"other"} }},
+		{Name: "audience list without authorized party", Nonce: "n", WantErr: true, Claims: func(c *IDTokenClaims) {
+			c.Audience = Audience{"client", "other"}
+		}},
+		{Name: "expired", Nonce: "n", WantErr: true, Claims: func(c *IDTokenClaims) { c.ExpiresAt = time.Now().Add(-time.Hour).Unix() }},
+		{Name: "issued in future", Nonce: "n", WantErr: true, Claims: func(c *IDTokenClaims) { c.IssuedAt = time.Now().Add(time.Hour).Unix() }},
+		{Name: "missing subject", Nonce: "n", WantErr: true, Claims: func(c *IDTokenClaims) { c.Subject = "" }},
+		{Name: "signed with unknown key", Nonce: "n", WantErr: true, Token: func() string {
+			token := jwt.NewWithClaims(other.Method, &IDTokenClaims{Issuer: p.URL, Subject: "s", Audience: Audience{"client"}, Nonce: "n", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+			token.Header["kid"] = other.ID
+			s, _ := token.SignedString(other.PrivateKey)
+			return s
+		}},
+		{Name: "hmac signed with public key", Nonce: "n", WantErr: true, Token: func() string {
+			jwk, _ := NewJWK(p.key)
+			b, _ := json.Marshal(jwk)
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, &IDTokenClaims{Issuer: p.URL, Subject: "s", Audience: Audience{"client"}, Nonce: "n", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+			token.Header["kid"] = p.key.ID
+			s, _ := token.SignedString(b)
+			return s
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			p.claims = tt.Claims
+			token := p.IDToken("n")
+			if tt.Token != nil {
+				token = tt.Token()
+			}
+			c, err := o.VerifyIDToken(token, tt.Nonce)
+			if tt.WantErr {
+				assert.True(t, errors.Is(err, ErrInvalidIDToken), "unexpected error %v", err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, "provider-user", c.Subject)
+		})
+	}
+}
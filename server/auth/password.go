@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"go-app/server/config"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// password hashing algorithms supported by PasswordHasher
+const (
+	PasswordHashArgon2id = "argon2id"
+	PasswordHashBcrypt   = "bcrypt"
+)
+
+// default argon2id parameters, see RFC 9106 section 4
+const (
+	DefaultArgon2Memory  = 64 * 1024
+	DefaultArgon2Time    = 3
+	DefaultArgon2Threads = 4
+	argon2SaltLength     = 16
+	argon2KeyLength      = 32
+)
+
+// ErrUnknownPasswordHash is returned when a stored hash was not produced by any supported algorithm
+var ErrUnknownPasswordHash = errors.New("unknown password hash format")
+
+// PasswordHasher hashes passwords with argon2id (default) or bcrypt.
+// Hashes produced by either algorithm are verified so that stored hashes can be migrated on login using NeedsRehash.
+type PasswordHasher struct {
+	Algorithm     string
+	BcryptCost    int
+	Argon2Memory  uint32
+	Argon2Time    uint32
+	Argon2Threads uint8
+}
+
+// NewPasswordHasher returns a new PasswordHasher instance using defaults for the parameters which are not set in config
+func NewPasswordHasher(c *config.PasswordHashConfig) (*PasswordHasher, error) {
+	h := &PasswordHasher{
+		Algorithm:     c.Algorithm,
+		BcryptCost:    c.BcryptCost,
+		Argon2Memory:  c.Argon2Memory,
+		Argon2Time:    c.Argon2Time,
+		Argon2Threads: c.Argon2Threads,
+	}
+	if h.Algorithm == "" {
+		h.Algorithm = PasswordHashArgon2id
+	}
+	if h.Algorithm != PasswordHashArgon2id && h.Algorithm != PasswordHashBcrypt {
+		return nil, fmt.Errorf("unsupported password hash algorithm %q", h.Algorithm)
+	}
+	if h.BcryptCost == 0 {
+		h.BcryptCost = bcrypt.DefaultCost
+	}
+	if h.BcryptCost < bcrypt.MinCost || h.BcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	if h.Argon2Memory == 0 {
+		h.Argon2Memory = DefaultArgon2Memory
+	}
+	if h.Argon2Time == 0 {
+		h.Argon2Time = DefaultArgon2Time
+	}
+	if h.Argon2Threads == 0 {
+		h.Argon2Threads = DefaultArgon2Threads
+	}
+	return h, nil
+}
+
+// Hash returns hash of the password in PHC string format for argon2id or modular crypt format for bcrypt
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	if h.Algorithm == PasswordHashBcrypt {
+		b, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		return string(b), err
+	}
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Argon2Time, h.Argon2Memory, h.Argon2Threads, argon2KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.Argon2Memory, h.Argon2Time, h.Argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify returns true if password matches the hash
+func (h *PasswordHasher) Verify(hash, password string) (bool, error) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		p, err := parseArgon2Hash(hash)
+		if err != nil {
+			return false, err
+		}
+		key := argon2.IDKey([]byte(password), p.salt, p.time, p.memory, p.threads, uint32(len(p.key)))
+		return subtle.ConstantTimeCompare(key, p.key) == 1, nil
+	case strings.HasPrefix(hash, "$2"):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+		return err == nil, err
+	default:
+		return false, ErrUnknownPasswordHash
+	}
+}
+
+// NeedsRehash returns true if hash was produced by another algorithm or with other parameters than the configured ones
+func (h *PasswordHasher) NeedsRehash(hash string) bool {
+	if h.Algorithm == PasswordHashBcrypt {
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != h.BcryptCost
+	}
+	p, err := parseArgon2Hash(hash)
+	return err != nil || p.memory != h.Argon2Memory || p.time != h.Argon2Time || p.threads != h.Argon2Threads
+}
+
+type argon2Hash struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+func parseArgon2Hash(hash string) (*argon2Hash, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, ErrUnknownPasswordHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, ErrUnknownPasswordHash
+	}
+	p := argon2Hash{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads); err != nil {
+		return nil, ErrUnknownPasswordHash
+	}
+	var err error
+	if p.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, ErrUnknownPasswordHash
+	}
+	if p.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(p.key) == 0 {
+		return nil, ErrUnknownPasswordHash
+	}
+	return &p, nil
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"go-app/server/config"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestPasswordHasher(t *testing.T, algorithm string) *PasswordHasher {
+	h, err := NewPasswordHasher(&config.PasswordHashConfig{Algorithm: algorithm, BcryptCost: bcrypt.MinCost, Argon2Memory: 1024, Argon2Time: 1})
+	if err != nil {
+		t.Fatalf("failed to create password hasher: %s", err)
+	}
+	return h
+}
+
+func TestPasswordHasher(t *testing.T) {
+	argon := newTestPasswordHasher(t, PasswordHashArgon2id)
+	bc := newTestPasswordHasher(t, PasswordHashBcrypt)
+	tests := []struct {
+		name       string
+		hasher     *PasswordHasher
+		verifier   *PasswordHasher
+		prefix     string
+		needRehash bool
+	}{
+		{
+			name:     "Argon2id",
+			hasher:   argon,
+			verifier: argon,
+			prefix:   "$argon2id$v=19$m=1024,t=1,p=4$",
+		},
+		{
+			name:     "Bcrypt",
+			hasher:   bc,
+			verifier: bc,
+			prefix:   "$2a$04$",
+		},
+		{
+			name:       "Bcrypt Hash Verified By Argon2id Hasher",
+			hasher:     bc,
+			verifier:   argon,
+			prefix:     "$2a$",
+			needRehash: true,
+		},
+		{
+			name:       "Argon2id Hash Verified By Bcrypt Hasher",
+			hasher:     argon,
+			verifier:   bc,
+			prefix:     "$argon2id$",
+			needRehash: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := tt.hasher.Hash("correct horse")
+			assert.Nil(t, err)
+			assert.True(t, strings.HasPrefix(hash, tt.prefix), hash)
+
+			ok, err := tt.verifier.Verify(hash, "correct horse")
+			assert.Nil(t, err)
+			assert.True(t, ok)
+
+			ok, err = tt.verifier.Verify(hash, "wrong horse")
+			assert.Nil(t, err)
+			assert.False(t, ok)
+
+			assert.Equal(t, tt.needRehash, tt.verifier.NeedsRehash(hash))
+
+			other, _ := tt.hasher.Hash("correct horse")
+			assert.NotEqual(t, hash, other, "hash must be salted")
+		})
+	}
+}
+
+func TestPasswordHasher_NeedsRehashOnParamChange(t *testing.T) {
+	old := newTestPasswordHasher(t, PasswordHashArgon2id)
+	hash, _ := old.Hash("correct horse")
+	stronger, _ := NewPasswordHasher(&config.PasswordHashConfig{Argon2Memory: 2048, Argon2Time: 1})
+	assert.True(t, stronger.NeedsRehash(hash))
+	ok, err := stronger.Verify(hash, "correct horse")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+}
+
+func TestPasswordHasher_InvalidHash(t *testing.T) {
+	h := newTestPasswordHasher(t, PasswordHashArgon2id)
+	for _, hash := range []string{"", "plain", "$argon2id$v=19$m=1024,t=1,p=4$!!$!!", "$argon2id$v=18$m=1024,t=1,p=4$c2FsdA$a2V5"} {
+		ok, err := h.Verify(hash, "correct horse")
+		assert.False(t, ok)
+		assert.NotNil(t, err, hash)
+	}
+	_, err := NewPasswordHasher(&config.PasswordHashConfig{Algorithm: "md5"})
+	assert.NotNil(t, err)
+	_, err = NewPasswordHasher(&config.PasswordHashConfig{Algorithm: PasswordHashBcrypt, BcryptCost: 50})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"go-app/server/config"
+	"strings"
+	"sync"
+)
+
+// RoleAdmin is granted every permission by the default policy
+const RoleAdmin = "admin"
+
+// PermissionAll matches every permission
+const PermissionAll = "*"
+
+// Policy is a registry mapping roles to the permissions they grant.
+// Permissions are `resource:action` strings, `resource:*` grants every action of the resource and `*` grants everything.
+type Policy struct {
+	mu    sync.RWMutex
+	roles map[string]map[string]bool
+}
+
+// NewPolicy returns a new Policy instance in which only admin role has all the permissions
+func NewPolicy() *Policy {
+	p := &Policy{roles: make(map[string]map[string]bool)}
+	p.Grant(RoleAdmin, PermissionAll)
+	return p
+}
+
+// NewPolicyFromConfig returns the default policy extended with roles defined in config
+func NewPolicyFromConfig(c *config.RBACConfig) *Policy {
+	p := NewPolicy()
+	for role, permissions := range c.Roles {
+		p.Grant(role, permissions...)
+	}
+	return p
+}
+
+// Grant adds permissions to the role
+func (p *Policy) Grant(role string, permissions ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.roles[role] == nil {
+		p.roles[role] = make(map[string]bool)
+	}
+	for _, perm := range permissions {
+		p.roles[role][perm] = true
+	}
+}
+
+// Revoke removes permissions from the role
+func (p *Policy) Revoke(role string, permissions ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, perm := range permissions {
+		delete(p.roles[role], perm)
+	}
+}
+
+// HasRole returns true if claim carries any of the roles
+func (p *Policy) HasRole(c Claim, roles ...string) bool {
+	for _, have := range c.GetRoles() {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasPermission returns true if the permission is granted to the claim either directly or by one of its roles
+func (p *Policy) HasPermission(c Claim, permission string) bool {
+	for _, granted := range c.GetPermissions() {
+		if matchPermission(granted, permission) {
+			return true
+		}
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, role := range c.GetRoles() {
+		for granted := range p.roles[role] {
+			if matchPermission(granted, permission) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Authorize returns true if claim has any of the roles (when roles are given) and all the permissions
+func (p *Policy) Authorize(c Claim, roles []string, permissions []string) bool {
+	if len(roles) > 0 && !p.HasRole(c, roles...) {
+		return false
+	}
+	for _, perm := range permissions {
+		if !p.HasPermission(c, perm) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchPermission(granted, permission string) bool {
+	if granted == PermissionAll || granted == permission {
+		return true
+	}
+	if strings.HasSuffix(granted, ":*") {
+		return strings.HasPrefix(permission, strings.TrimSuffix(granted, "*"))
+	}
+	return false
+}
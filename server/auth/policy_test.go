@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"fmt"
+	"go-app/server/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestPolicy() *Policy {
+	return NewPolicyFromConfig(&config.RBACConfig{
+		Roles: map[string][]string{
+			"user":   {"orders:read"},
+			"editor": {"orders:*"},
+		},
+	})
+}
+
+func TestPolicy_HasPermission(t *testing.T) {
+	p := getTestPolicy()
+	permissions := []string{"orders:read", "orders:write", "users:delete"}
+	tests := []struct {
+		name  string
+		claim *UserClaim
+		// allowed lists permissions which must be granted, every other permission must be denied
+		allowed []string
+	}{
+		{
+			name:    "No Role",
+			claim:   &UserClaim{ID: "1"},
+			allowed: []string{},
+		},
+		{
+			name:    "Unknown Role",
+			claim:   &UserClaim{ID: "1", Roles: []string{"guest"}},
+			allowed: []string{},
+		},
+		{
+			name:    "User",
+			claim:   &UserClaim{ID: "1", Roles: []string{"user"}},
+			allowed: []string{"orders:read"},
+		},
+		{
+			name:    "User Type",
+			claim:   &UserClaim{ID: "1", Type: "user"},
+			allowed: []string{"orders:read"},
+		},
+		{
+			name:    "Editor",
+			claim:   &UserClaim{ID: "1", Roles: []string{"editor"}},
+			allowed: []string{"orders:read", "orders:write"},
+		},
+		{
+			name:    "User And Editor",
+			claim:   &UserClaim{ID: "1", Roles: []string{"user", "editor"}},
+			allowed: []string{"orders:read", "orders:write"},
+		},
+		{
+			name:    "Admin",
+			claim:   &UserClaim{ID: "1", Roles: []string{"admin"}},
+			allowed: permissions,
+		},
+		{
+			name:    "Admin Type",
+			claim:   &UserClaim{ID: "1", Type: "admin"},
+			allowed: permissions,
+		},
+		{
+			name:    "User With Direct Permission",
+			claim:   &UserClaim{ID: "1", Roles: []string{"user"}, Permissions: []string{"users:delete"}},
+			allowed: []string{"orders:read", "users:delete"},
+		},
+	}
+	for _, tt := range tests {
+		for _, perm := range permissions {
+			want := false
+			for _, a := range tt.allowed {
+				if a == perm {
+					want = true
+				}
+			}
+			t.Run(fmt.Sprintf("%s/%s", tt.name, perm), func(t *testing.T) {
+				assert.Equal(t, want, p.HasPermission(tt.claim, perm))
+			})
+		}
+	}
+}
+
+func TestPolicy_Authorize(t *testing.T) {
+	p := getTestPolicy()
+	tests := []struct {
+		name        string
+		claim       *UserClaim
+		roles       []string
+		permissions []string
+		want        bool
+	}{
+		{
+			name:  "Any Of Roles",
+			claim: &UserClaim{Roles: []string{"editor"}},
+			roles: []string{"admin", "editor"},
+			want:  true,
+		},
+		{
+			name:  "Missing Role",
+			claim: &UserClaim{Roles: []string{"user"}},
+			roles: []string{"admin", "editor"},
+			want:  false,
+		},
+		{
+			name:        "All Of Permissions",
+			claim:       &UserClaim{Roles: []string{"editor"}},
+			permissions: []string{"orders:read", "orders:write"},
+			want:        true,
+		},
+		{
+			name:        "Missing One Permission",
+			claim:       &UserClaim{Roles: []string{"user"}},
+			permissions: []string{"orders:read", "orders:write"},
+			want:        false,
+		},
+		{
+			name:        "Role And Permission",
+			claim:       &UserClaim{Roles: []string{"user"}},
+			roles:       []string{"user"},
+			permissions: []string{"orders:write"},
+			want:        false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, p.Authorize(tt.claim, tt.roles, tt.permissions))
+		})
+	}
+}
+
+func TestPolicy_GrantAndRevoke(t *testing.T) {
+	p := NewPolicy()
+	uc := &UserClaim{Roles: []string{"support"}}
+	assert.False(t, p.HasPermission(uc, "tickets:close"))
+	p.Grant("support", "tickets:close")
+	assert.True(t, p.HasPermission(uc, "tickets:close"))
+	p.Revoke("support", "tickets:close")
+	assert.False(t, p.HasPermission(uc, "tickets:close"))
+}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"go-app/server/config"
+	"go-app/server/storage"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	uuid "github.com/satori/go.uuid"
+)
+
+const refreshTokenKeyPrefix = "refresh_token:"
+
+// DefaultRefreshExpiresAt is used when TokenAuthConfig.RefreshExpiresAt is not set
+const DefaultRefreshExpiresAt = 7 * 24 * time.Hour
+
+var (
+	// ErrInvalidRefreshToken is returned when refresh token is malformed, expired or its family was revoked
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is presented again.
+	// The whole token family is revoked when this happens.
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+)
+
+// refreshTokenFamily is stored for every chain of rotated refresh tokens. Only the hash of the newest token is kept,
+// so any older token of the family is detected as reused.
+type refreshTokenFamily struct {
+	Current   string     `json:"current"`
+	UserClaim *UserClaim `json:"claim"`
+	IssuedAt  int64      `json:"issued_at"`
+}
+
+// RefreshTokenStore issues opaque refresh tokens and rotates them on every use.
+// Rotation is a compare-and-set on the stored family, so a token is accepted only once even when the storage is shared
+// by many instances.
+type RefreshTokenStore struct {
+	Storage   storage.Redis
+	ExpiresAt time.Duration
+}
+
+// NewRefreshTokenStore returns a new RefreshTokenStore instance backed by redis or memory storage
+func NewRefreshTokenStore(s storage.Redis, c *config.TokenAuthConfig) *RefreshTokenStore {
+	expiresAt := time.Duration(c.RefreshExpiresAt) * time.Minute
+	if expiresAt == 0 {
+		expiresAt = DefaultRefreshExpiresAt
+	}
+	return &RefreshTokenStore{Storage: s, ExpiresAt: expiresAt}
+}
+
+// Issue starts a new token family for the claim and returns its first refresh token
+func (rs *RefreshTokenStore) Issue(uc *UserClaim) (string, error) {
+	if uc.MFAPending {
+		return "", errors.New("refresh token can not be issued before mfa verification")
+	}
+	// registered claims (exp, iat...) are set again every time an access token is signed from the family
+	c := *uc
+	c.StandardClaims = jwt.StandardClaims{}
+	familyID := uuid.NewV4().String()
+	token, data, err := rs.next(familyID, &refreshTokenFamily{UserClaim: &c, IssuedAt: time.Now().Unix()})
+	if err != nil {
+		return "", err
+	}
+	if err := rs.Storage.Commit(refreshTokenKeyPrefix+familyID, data, time.Now().Add(rs.ExpiresAt)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Rotate exchanges a refresh token for a new one of the same family and returns the claim it was issued for.
+// Presenting a token which was already rotated revokes the whole family, so does a family issued before its user was revoked.
+func (rs *RefreshTokenStore) Rotate(token string) (string, *UserClaim, error) {
+	familyID, secret, err := parseRefreshToken(token)
+	if err != nil {
+		return "", nil, err
+	}
+
+	family, current, err := rs.find(familyID)
+	if err != nil {
+		return "", nil, err
+	}
+	revoked, err := isUserRevoked(rs.Storage, family.UserClaim.ID, family.IssuedAt)
+	if err != nil {
+		return "", nil, err
+	}
+	if revoked {
+		if err := rs.RevokeFamily(familyID); err != nil {
+			return "", nil, err
+		}
+		return "", nil, ErrInvalidRefreshToken
+	}
+	if subtle.ConstantTimeCompare([]byte(hashRefreshSecret(secret)), []byte(family.Current)) != 1 {
+		if err := rs.RevokeFamily(familyID); err != nil {
+			return "", nil, err
+		}
+		return "", nil, ErrRefreshTokenReused
+	}
+
+	newToken, data, err := rs.next(familyID, family)
+	if err != nil {
+		return "", nil, err
+	}
+	ok, err := rs.Storage.CompareAndCommit(refreshTokenKeyPrefix+familyID, current, data, time.Now().Add(rs.ExpiresAt))
+	if err != nil {
+		return "", nil, err
+	}
+	// the family was rotated or revoked meanwhile by a concurrent request, possibly on another instance
+	if !ok {
+		if err := rs.RevokeFamily(familyID); err != nil {
+			return "", nil, err
+		}
+		return "", nil, ErrRefreshTokenReused
+	}
+	return newToken, family.UserClaim, nil
+}
+
+// Revoke revokes the family of the refresh token
+func (rs *RefreshTokenStore) Revoke(token string) error {
+	familyID, _, err := parseRefreshToken(token)
+	if err != nil {
+		return err
+	}
+	return rs.RevokeFamily(familyID)
+}
+
+// RevokeFamily revokes all the refresh tokens of the family
+func (rs *RefreshTokenStore) RevokeFamily(familyID string) error {
+	return rs.Storage.Delete(refreshTokenKeyPrefix + familyID)
+}
+
+// find returns the family along with its stored data, which is compared when the family is rotated
+func (rs *RefreshTokenStore) find(familyID string) (*refreshTokenFamily, []byte, error) {
+	data, found, err := rs.Storage.Find(refreshTokenKeyPrefix + familyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	family := refreshTokenFamily{}
+	if err := json.Unmarshal(data, &family); err != nil {
+		return nil, nil, err
+	}
+	return &family, data, nil
+}
+
+// next generates a new secret for the family and returns the new refresh token along with the family data to store
+func (rs *RefreshTokenStore) next(familyID string, family *refreshTokenFamily) (string, []byte, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(b)
+	family.Current = hashRefreshSecret(secret)
+	data, err := json.Marshal(family)
+	if err != nil {
+		return "", nil, err
+	}
+	return familyID + "." + secret, data, nil
+}
+
+func parseRefreshToken(token string) (string, string, error) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", ErrInvalidRefreshToken
+	}
+	return parts[0], parts[1], nil
+}
+
+func hashRefreshSecret(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(h[:])
+}
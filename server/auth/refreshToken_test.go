@@ -0,0 +1,107 @@
+package auth
+
+import (
+	memorystorage "go-app/server/storage/memory"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestRefreshTokenStore() *RefreshTokenStore {
+	return NewRefreshTokenStore(memorystorage.NewMemoryStorageWithCleanupInterval(0), getTestConfig())
+}
+
+func TestRefreshTokenStore_Rotate(t *testing.T) {
+	rs := getTestRefreshTokenStore()
+	uc := getTestUserClaim()
+	uc.ExpiresAt = time.Now().Unix()
+
+	first, err := rs.Issue(uc)
+	assert.Nil(t, err)
+
+	second, claim, err := rs.Rotate(first)
+	assert.Nil(t, err)
+	assert.NotEqual(t, first, second)
+	assert.Equal(t, uc.ID, claim.ID)
+	assert.Zero(t, claim.ExpiresAt)
+
+	third, _, err := rs.Rotate(second)
+	assert.Nil(t, err)
+
+	// presenting an already rotated token revokes the whole family
+	_, _, err = rs.Rotate(first)
+	assert.Equal(t, ErrRefreshTokenReused, err)
+	_, _, err = rs.Rotate(third)
+	assert.Equal(t, ErrInvalidRefreshToken, err)
+}
+
+func TestRefreshTokenStore_Invalid(t *testing.T) {
+	rs := getTestRefreshTokenStore()
+	other, _ := rs.Issue(getTestUserClaim())
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{
+			name:    "Empty Token",
+			token:   "",
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name:    "Malformed Token",
+			token:   "abc",
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name:    "Unknown Family",
+			token:   "unknown.secret",
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name:    "Wrong Secret",
+			token:   other[:len(other)-2] + "xx",
+			wantErr: ErrRefreshTokenReused,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := rs.Rotate(tt.token)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestRefreshTokenStore_Revoke(t *testing.T) {
+	rs := getTestRefreshTokenStore()
+	token, _ := rs.Issue(getTestUserClaim())
+	assert.Nil(t, rs.Revoke(token))
+	_, _, err := rs.Rotate(token)
+	assert.Equal(t, ErrInvalidRefreshToken, err)
+}
+
+func TestRefreshTokenStore_ConcurrentRotate(t *testing.T) {
+	// stores sharing the storage stand for instances of the service
+	ms := memorystorage.NewMemoryStorageWithCleanupInterval(0)
+	stores := []*RefreshTokenStore{NewRefreshTokenStore(ms, getTestConfig()), NewRefreshTokenStore(ms, getTestConfig())}
+	token, _ := stores[0].Issue(getTestUserClaim())
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	rotated := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(rs *RefreshTokenStore) {
+			defer wg.Done()
+			if _, _, err := rs.Rotate(token); err == nil {
+				mu.Lock()
+				rotated++
+				mu.Unlock()
+			}
+		}(stores[i%2])
+	}
+	wg.Wait()
+	assert.Equal(t, 1, rotated, "a refresh token is accepted only once")
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RemoteKeySet verifies tokens using the public keys published by another service as a JWKS document.
+// The document is cached for TTL and fetched again whenever a token carries an unknown `kid`,
+// but not more often than MinRefreshInterval.
+type RemoteKeySet struct {
+	URL                string
+	Client             *http.Client
+	TTL                time.Duration
+	MinRefreshInterval time.Duration
+
+	mu          sync.RWMutex
+	refreshMu   sync.Mutex
+	keys        map[string]*SigningKey
+	jwks        *JWKS
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	now         func() time.Time
+}
+
+// NewRemoteKeySet returns a new RemoteKeySet instance fetching keys from url
+func NewRemoteKeySet(url string, ttl time.Duration) *RemoteKeySet {
+	return &RemoteKeySet{
+		URL:                url,
+		Client:             &http.Client{Timeout: 10 * time.Second},
+		TTL:                ttl,
+		MinRefreshInterval: 10 * time.Second,
+		keys:               make(map[string]*SigningKey),
+		jwks:               &JWKS{Keys: []*JWK{}},
+		now:                time.Now,
+	}
+}
+
+// Refresh fetches the JWKS document and replaces the cached keys
+func (rk *RemoteKeySet) Refresh() error {
+	rk.mu.Lock()
+	rk.attemptedAt = rk.now()
+	rk.mu.Unlock()
+
+	resp, err := rk.Client.Get(rk.URL)
+	if err != nil {
+		return fmt.Errorf("token auth: failed to fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("token auth: failed to fetch jwks: unexpected status %d", resp.StatusCode)
+	}
+
+	jwks := JWKS{}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return fmt.Errorf("token auth: failed to decode jwks: %w", err)
+	}
+	keys := make(map[string]*SigningKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// keys which can not be parsed are skipped so that one bad key does not break verification of the others
+		k, err := jwk.SigningKey()
+		if err != nil {
+			continue
+		}
+		keys[k.ID] = k
+	}
+
+	rk.mu.Lock()
+	rk.keys = keys
+	rk.jwks = &jwks
+	rk.fetchedAt = rk.now()
+	rk.mu.Unlock()
+	return nil
+}
+
+// refreshIfStale fetches the document again if it has expired, or if force is set and the last attempt is older than
+// MinRefreshInterval. Concurrent callers wait for a single fetch.
+func (rk *RemoteKeySet) refreshIfStale(force bool) error {
+	rk.refreshMu.Lock()
+	defer rk.refreshMu.Unlock()
+
+	rk.mu.RLock()
+	now := rk.now()
+	expired := rk.fetchedAt.IsZero() || now.After(rk.fetchedAt.Add(rk.TTL))
+	throttled := now.Before(rk.attemptedAt.Add(rk.MinRefreshInterval))
+	rk.mu.RUnlock()
+
+	if throttled || (!expired && !force) {
+		return nil
+	}
+	return rk.Refresh()
+}
+
+func (rk *RemoteKeySet) lookup(kid string) (*SigningKey, bool) {
+	rk.mu.RLock()
+	defer rk.mu.RUnlock()
+	if kid == "" && len(rk.keys) == 1 {
+		for _, k := range rk.keys {
+			return k, true
+		}
+	}
+	k, ok := rk.keys[kid]
+	return k, ok
+}
+
+// SigningKey always returns an error since a remote key set can only verify tokens
+func (rk *RemoteKeySet) SigningKey() (*SigningKey, error) {
+	return nil, errors.New("token auth: remote key set can not sign tokens")
+}
+
+// VerificationKey returns key identified by kid from the cached JWKS document, fetching it again when kid is unknown
+func (rk *RemoteKeySet) VerificationKey(kid string) (*SigningKey, error) {
+	err := rk.refreshIfStale(false)
+	if k, ok := rk.lookup(kid); ok {
+		return k, nil
+	}
+	if err == nil {
+		err = rk.refreshIfStale(true)
+	}
+	if k, ok := rk.lookup(kid); ok {
+		return k, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("token auth: unknown key %q", kid)
+}
+
+// JWKS returns the cached remote JWKS document
+func (rk *RemoteKeySet) JWKS() *JWKS {
+	rk.mu.RLock()
+	defer rk.mu.RUnlock()
+	return rk.jwks
+}
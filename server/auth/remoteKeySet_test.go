@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"go-app/server/config"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRSAKey(t *testing.T, id string, activeFrom time.Time) *SigningKey {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate rsa key: %s", err)
+	}
+	return &SigningKey{ID: id, Method: jwt.SigningMethodRS256, PrivateKey: priv, PublicKey: &priv.PublicKey, ActiveFrom: activeFrom}
+}
+
+// newTestJWKSServer serves JWKS of issuer and counts the number of times the document is fetched
+func newTestJWKSServer(issuer *TokenAuthentication, fetches *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(fetches, 1)
+		json.NewEncoder(w).Encode(issuer.JWKS())
+	}))
+}
+
+func TestJWK_RoundTrip(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	edPub, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	tests := []struct {
+		name string
+		key  *SigningKey
+	}{
+		{
+			name: "RSA",
+			key:  newTestRSAKey(t, "rs", time.Time{}),
+		},
+		{
+			name: "EC",
+			key:  &SigningKey{ID: "es", Method: jwt.SigningMethodES384, PrivateKey: ecKey, PublicKey: &ecKey.PublicKey},
+		},
+		{
+			name: "OKP",
+			key:  &SigningKey{ID: "ed", Method: SigningMethodEdDSA, PrivateKey: edPriv, PublicKey: edPub},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jwk, err := NewJWK(tt.key)
+			assert.Nil(t, err)
+			data, _ := json.Marshal(jwk)
+			parsed := JWK{}
+			assert.Nil(t, json.Unmarshal(data, &parsed))
+			got, err := parsed.SigningKey()
+			assert.Nil(t, err)
+			assert.Equal(t, tt.key.ID, got.ID)
+			assert.Equal(t, tt.key.Method.Alg(), got.Method.Alg())
+			assert.Equal(t, tt.key.PublicKey, got.PublicKey)
+			assert.False(t, got.CanSign())
+		})
+	}
+}
+
+func TestKeySet_JWKS(t *testing.T) {
+	now := time.Now()
+	retired := newTestRSAKey(t, "retired", now.Add(-72*time.Hour))
+	old := newTestRSAKey(t, "old", now.Add(-48*time.Hour))
+	current := newTestRSAKey(t, "current", now.Add(-time.Hour))
+	next := newTestRSAKey(t, "next", now.Add(time.Hour))
+	hmac := &SigningKey{ID: "hmac", Method: jwt.SigningMethodHS256, PrivateKey: []byte("a"), PublicKey: []byte("a"), ActiveFrom: now.Add(-30 * time.Hour)}
+
+	ks, err := NewKeySet(2*time.Hour, retired, old, hmac, current, next)
+	assert.Nil(t, err)
+	var ids []string
+	for _, k := range ks.JWKS().Keys {
+		ids = append(ids, k.Kid)
+	}
+	assert.Equal(t, []string{"current", "next"}, ids)
+}
+
+func TestRemoteKeySet_VerifyToken(t *testing.T) {
+	issuerKeys, _ := NewKeySet(time.Hour, newTestRSAKey(t, "k1", time.Now().Add(-time.Hour)))
+	issuer := &TokenAuthentication{Config: &config.TokenAuthConfig{}, Keys: issuerKeys}
+
+	var fetches int32
+	ts := newTestJWKSServer(issuer, &fetches)
+	defer ts.Close()
+
+	verifier, err := NewTokenAuthentication(&config.TokenAuthConfig{JWKSURL: ts.URL})
+	assert.Nil(t, err)
+	remote := verifier.Keys.(*RemoteKeySet)
+	remote.MinRefreshInterval = 0
+
+	uc := getTestUserClaim()
+	token, err := issuer.SignToken(uc)
+	assert.Nil(t, err)
+	got, err := verifier.VerifyToken(token)
+	assert.Nil(t, err)
+	assert.Equal(t, uc, got)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+
+	// cached document is used for known keys
+	_, err = verifier.VerifyToken(token)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+
+	// issuer rotates the key, unknown kid triggers a refresh
+	issuerKeys.SetKeys(append(issuerKeys.Keys(), newTestRSAKey(t, "k2", time.Now().Add(-time.Minute)))...)
+	token, err = issuer.SignToken(uc)
+	assert.Nil(t, err)
+	_, err = verifier.VerifyToken(token)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches))
+
+	// refresh on unknown kid is throttled
+	remote.MinRefreshInterval = time.Hour
+	other, _ := NewKeySet(0, newTestRSAKey(t, "k3", time.Time{}))
+	token, _ = (&TokenAuthentication{Config: &config.TokenAuthConfig{}, Keys: other}).SignToken(uc)
+	_, err = verifier.VerifyToken(token)
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches))
+
+	// remote key set can not sign tokens
+	_, err = verifier.SignToken(uc)
+	assert.NotNil(t, err)
+}
+
+func TestRemoteKeySet_FetchError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	rk := NewRemoteKeySet(ts.URL, time.Minute)
+	_, err := rk.VerificationKey("k1")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unexpected status 500")
+}
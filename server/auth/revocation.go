@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"go-app/server/storage"
+	"strconv"
+	"time"
+)
+
+const (
+	revokedTokenKeyPrefix = "revoked_token:"
+	revokedUserKeyPrefix  = "revoked_user:"
+)
+
+// revokedTokenTTL is used for tokens without `exp`, which otherwise would stay valid forever
+const revokedTokenTTL = 365 * 24 * time.Hour
+
+// RevocationList checks if a verified token was revoked before it expired
+type RevocationList interface {
+	IsRevoked(Claim) (bool, error)
+}
+
+// revocable is implemented by claims which carry a unique token id (`jti`)
+type revocable interface {
+	TokenID() string
+}
+
+// RevocationStore is a deny-list of token ids backed by redis or memory storage.
+// Entries expire together with the token so the list only holds tokens which would otherwise still be valid.
+type RevocationStore struct {
+	Storage storage.Redis
+}
+
+// NewRevocationStore returns a new RevocationStore instance
+func NewRevocationStore(s storage.Redis) *RevocationStore {
+	return &RevocationStore{Storage: s}
+}
+
+// Revoke adds the token of the claim into the deny-list
+func (rs *RevocationStore) Revoke(uc *UserClaim) error {
+	if uc.TokenID() == "" {
+		return nil
+	}
+	expiry := time.Now().Add(revokedTokenTTL)
+	if uc.ExpiresAt != 0 {
+		expiry = time.Unix(uc.ExpiresAt, 0)
+	}
+	return rs.Storage.Commit(revokedTokenKeyPrefix+uc.TokenID(), []byte{1}, expiry)
+}
+
+// RevokeUser revokes every access token, refresh token family and session issued to the user before the given time,
+// e.g. after the password of the user was changed
+func (rs *RevocationStore) RevokeUser(id string, before time.Time) error {
+	return rs.Storage.Commit(revokedUserKeyPrefix+id, []byte(strconv.FormatInt(before.Unix(), 10)), time.Now().Add(revokedTokenTTL))
+}
+
+// IsRevoked returns true if the token of the claim is in the deny-list or was issued before its user was revoked.
+// Claims without token id can not be revoked.
+func (rs *RevocationStore) IsRevoked(c Claim) (bool, error) {
+	r, ok := c.(revocable)
+	if !ok || r.TokenID() == "" {
+		return false, nil
+	}
+	_, found, err := rs.Storage.Find(revokedTokenKeyPrefix + r.TokenID())
+	if err != nil || found {
+		return found, err
+	}
+	uc, ok := c.(*UserClaim)
+	if !ok {
+		return false, nil
+	}
+	return isUserRevoked(rs.Storage, uc.ID, uc.IssuedAt)
+}
+
+// isUserRevoked returns true if the credential of the user issued at the unix time was revoked with RevokeUser
+func isUserRevoked(s storage.Redis, id string, issuedAt int64) (bool, error) {
+	if id == "" {
+		return false, nil
+	}
+	data, found, err := s.Find(revokedUserKeyPrefix + id)
+	if err != nil || !found {
+		return false, err
+	}
+	before, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return false, err
+	}
+	return issuedAt < before, nil
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"go-app/server/config"
+	memorystorage "go-app/server/storage/memory"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevocationStore_IsRevoked(t *testing.T) {
+	rs := NewRevocationStore(memorystorage.NewMemoryStorageWithCleanupInterval(0))
+
+	revoked := getTestUserClaim()
+	testTokenAuth.SignToken(revoked)
+	assert.Nil(t, rs.Revoke(revoked))
+
+	expired := getTestUserClaim()
+	testTokenAuth.SignToken(expired)
+	expired.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	assert.Nil(t, rs.Revoke(expired))
+
+	active := getTestUserClaim()
+	testTokenAuth.SignToken(active)
+
+	tests := []struct {
+		name  string
+		claim Claim
+		want  bool
+	}{
+		{
+			name:  "Revoked Token",
+			claim: revoked,
+			want:  true,
+		},
+		{
+			name:  "Active Token",
+			claim: active,
+			want:  false,
+		},
+		{
+			name:  "Revoked Entry Expires With Token",
+			claim: expired,
+			want:  false,
+		},
+		{
+			name:  "Claim Without Token ID",
+			claim: getTestUserClaim(),
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rs.IsRevoked(tt.claim)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRevocationStore_RevokeUser(t *testing.T) {
+	ms := memorystorage.NewMemoryStorageWithCleanupInterval(0)
+	rs := NewRevocationStore(ms)
+	refreshTokens := NewRefreshTokenStore(ms, getTestConfig())
+	sessions := NewCookieSession(ms, &config.SessionConfig{CookieName: "sid"})
+
+	uc := getTestUserClaim()
+	other := getTestUserClaim()
+	testTokenAuth.SignToken(uc)
+	testTokenAuth.SignToken(other)
+	refreshToken, err := refreshTokens.Issue(uc)
+	assert.Nil(t, err)
+	recorder := httptest.NewRecorder()
+	assert.Nil(t, sessions.Create(recorder, httptest.NewRequest(http.MethodPost, "/", nil), uc))
+
+	changedAt := time.Now().Add(time.Second)
+	assert.Nil(t, rs.RevokeUser(uc.ID, changedAt))
+
+	revoked, err := rs.IsRevoked(uc)
+	assert.Nil(t, err)
+	assert.True(t, revoked, "access token issued before is revoked")
+	revoked, _ = rs.IsRevoked(other)
+	assert.False(t, revoked, "tokens of other users are not affected")
+	_, _, err = refreshTokens.Rotate(refreshToken)
+	assert.Equal(t, ErrInvalidRefreshToken, err)
+	_, err = sessions.Get(requestWithCookies(recorder))
+	assert.Equal(t, ErrSessionNotFound, err)
+
+	// credentials issued after the user was revoked are accepted
+	uc.IssuedAt = changedAt.Unix()
+	revoked, _ = rs.IsRevoked(uc)
+	assert.False(t, revoked)
+}
+
+func TestTokenAuthentication_UniqueTokenID(t *testing.T) {
+	uc := getTestUserClaim()
+	first, _ := testTokenAuth.SignToken(uc)
+	firstID := uc.TokenID()
+	second, _ := testTokenAuth.SignToken(uc)
+	assert.NotEqual(t, first, second)
+	assert.NotEmpty(t, firstID)
+	assert.NotEqual(t, firstID, uc.TokenID())
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"encoding/json"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// RoleService is the role carried by every claim of an internal service authenticated with a signed request
+const RoleService = "service"
+
+// ServiceClaim is the claim of a request signed by an internal service. Scopes configured for the service are its permissions.
+type ServiceClaim struct {
+	ID     string   `json:"id"`
+	Scopes []string `json:"scopes"`
+}
+
+// ToJSON converts claim to json
+func (c *ServiceClaim) ToJSON() string {
+	b, _ := json.Marshal(c)
+	return string(b)
+}
+
+// GetJWTToken returns nil as signed requests do not carry jwt tokens
+func (c *ServiceClaim) GetJWTToken() *jwt.Token {
+	return nil
+}
+
+// IsAdmin returns false, services are only granted their scopes
+func (c *ServiceClaim) IsAdmin() bool {
+	return false
+}
+
+// GetRoles returns RoleService
+func (c *ServiceClaim) GetRoles() []string {
+	return []string{RoleService}
+}
+
+// GetPermissions returns scopes of the service
+func (c *ServiceClaim) GetPermissions() []string {
+	return c.Scopes
+}
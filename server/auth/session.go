@@ -4,11 +4,12 @@ import (
 	"net/http"
 )
 
-// Session defines session storage methods
+// Session defines session storage methods.
+// Create always starts a new session id, removing the session the request was carrying, to prevent session fixation.
 type Session interface {
 	NewSessionID() string
-	Create(string, http.ResponseWriter) error
-	Get(*http.Request) (*TokenAuth, error)
-	Update(http.ResponseWriter, *http.Request, *TokenAuth) error
-	Delete(*http.Request) error
+	Create(http.ResponseWriter, *http.Request, Claim) error
+	Get(*http.Request) (Claim, error)
+	Update(http.ResponseWriter, *http.Request, Claim) error
+	Delete(http.ResponseWriter, *http.Request) error
 }
@@ -3,6 +3,7 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
 
 	"github.com/dgrijalva/jwt-go"
@@ -11,12 +12,23 @@ import (
 // HeaderRequestID header name to look for request id for request tracking
 const HeaderRequestID = "X-Request-ID"
 
-// TokenAuth defines method for implementing token authentication
+type key int
+
+const claimKey key = 0
+
+// TokenAuth defines method for implementing token authentication.
+// Implementations must be safe for concurrent use: claims are passed in and returned per call and never stored
+// on the implementation itself.
 type TokenAuth interface {
-	SignToken() (string, error)
-	VerifyToken(string) error
-	GetClaim() Claim
-	SetClaim(Claim)
+	SignToken(Claim) (string, error)
+	VerifyToken(string) (Claim, error)
+}
+
+// KeyStore provides keys used by TokenAuthentication to sign and verify tokens
+type KeyStore interface {
+	SigningKey() (*SigningKey, error)
+	VerificationKey(string) (*SigningKey, error)
+	JWKS() *JWKS
 }
 
 // Claim defines custom token claim type methods.
@@ -25,6 +37,8 @@ type Claim interface {
 	ToJSON() string
 	GetJWTToken() *jwt.Token
 	IsAdmin() bool
+	GetRoles() []string
+	GetPermissions() []string
 }
 
 // JWTToken represents jwt encoded token string for json format
@@ -37,3 +51,16 @@ func (jwt *JWTToken) ToJSON() string {
 	json, _ := json.Marshal(jwt.Token)
 	return string(json)
 }
+
+// NewContextWithClaim returns a copy of ctx carrying the verified claim of the current request
+func NewContextWithClaim(ctx context.Context, c Claim) context.Context {
+	return context.WithValue(ctx, claimKey, c)
+}
+
+// ClaimFromContext returns the verified claim stored in the request context, or nil if the request is anonymous
+func ClaimFromContext(ctx context.Context) Claim {
+	if c, ok := ctx.Value(claimKey).(Claim); ok {
+		return c
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rfc6238Secret is the SHA1 test secret "12345678901234567890" of RFC 6238 appendix B
+var rfc6238Secret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCode(t *testing.T) {
+	// last 6 digits of the 8 digit RFC 6238 test vectors
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got, err := TOTPCode(rfc6238Secret, time.Unix(tt.unix, 0))
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	assert.Nil(t, err)
+	now := time.Now()
+	code, _ := TOTPCode(secret, now)
+	previous, _ := TOTPCode(secret, now.Add(-TOTPPeriod))
+	old, _ := TOTPCode(secret, now.Add(-3*TOTPPeriod))
+
+	counter, ok := ValidateTOTP(secret, code, now, 0)
+	assert.True(t, ok)
+	assert.Equal(t, now.Unix()/30, counter)
+
+	_, ok = ValidateTOTP(secret, previous, now, 0)
+	assert.True(t, ok, "previous period is accepted for clock skew")
+	_, ok = ValidateTOTP(secret, old, now, 0)
+	assert.False(t, ok)
+	_, ok = ValidateTOTP(secret, code, now, counter)
+	assert.False(t, ok, "code can be used only once")
+	_, ok = ValidateTOTP(secret, "12345", now, 0)
+	assert.False(t, ok)
+}
+
+func TestTOTPURI(t *testing.T) {
+	u, err := url.Parse(TOTPURI("go-app", "alice@example.com", "ABC"))
+	assert.Nil(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/go-app:alice@example.com", u.Path)
+	assert.Equal(t, "ABC", u.Query().Get("secret"))
+	assert.Equal(t, "go-app", u.Query().Get("issuer"))
+}
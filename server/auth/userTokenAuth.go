@@ -3,33 +3,54 @@ package auth
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"go-app/server/config"
+	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	uuid "github.com/satori/go.uuid"
 )
 
-// TokenAuthentication contains authentication related attributes and methods
+// TokenAuthentication contains authentication related attributes and methods.
+// It holds no per-request state, therefore a single instance is shared by all the request handlers.
 type TokenAuthentication struct {
 	Config *config.TokenAuthConfig
-	User   *UserAuth
+	Keys   KeyStore
 }
 
-// NewTokenAuthentication returns new instance of TokenAuthentication
-func NewTokenAuthentication(c *config.TokenAuthConfig) *TokenAuthentication {
-	return &TokenAuthentication{Config: c}
+// NewTokenAuthentication returns new instance of TokenAuthentication with the key set loaded from config.
+// If JWKSURL is configured the instance can only verify tokens using the remote key set.
+func NewTokenAuthentication(c *config.TokenAuthConfig) (*TokenAuthentication, error) {
+	if c.JWKSURL != "" {
+		ttl := time.Duration(c.JWKSCacheTTL) * time.Minute
+		if ttl == 0 {
+			ttl = 15 * time.Minute
+		}
+		return &TokenAuthentication{Config: c, Keys: NewRemoteKeySet(c.JWKSURL, ttl)}, nil
+	}
+	ks, err := NewKeySetFromConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenAuthentication{Config: c, Keys: ks}, nil
 }
 
-// UserAuth contains encoded token info and user info
-type UserAuth struct {
-	UserClaim *UserClaim
-	JWTToken  JWTToken
-}
+// DefaultMFAPendingExpiresAt is used when TokenAuthConfig.MFAPendingExpiresAt is not set
+const DefaultMFAPendingExpiresAt = 5 * time.Minute
 
-// UserClaim contains user related info for jwt token
+// UserClaim contains user related info for jwt token.
+// A claim with MFAPending set is only issued after the first login step and grants no roles or permissions.
+// A claim with ClientID set is an oauth2 access token, it grants no roles and only the permissions listed in Scope.
 type UserClaim struct {
-	ID   string `json:"id"`
-	Type string `json:"type"`
+	ID          string   `json:"id"`
+	Type        string   `json:"type"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	MFAPending  bool     `json:"mfa_pending,omitempty"`
+	ClientID    string   `json:"client_id,omitempty"`
+	Scope       string   `json:"scope,omitempty"`
 	jwt.StandardClaims
 }
 
@@ -47,57 +68,115 @@ func (uc *UserClaim) ToJSON() string {
 
 // IsAdmin if user is an admin user
 func (uc *UserClaim) IsAdmin() bool {
-	if uc.Type == "admin" {
-		return true
+	for _, role := range uc.GetRoles() {
+		if role == RoleAdmin {
+			return true
+		}
 	}
 	return false
 }
 
-// SignToken sign and encodes jwt.Token as a string
-func (t *TokenAuthentication) SignToken() (string, error) {
-	if t.Config.JWTExpiresAt != 0 {
+// GetRoles returns roles of the user. User type is always one of the roles.
+func (uc *UserClaim) GetRoles() []string {
+	if uc.MFAPending || uc.ClientID != "" {
+		return nil
+	}
+	if uc.Type == "" {
+		return uc.Roles
+	}
+	for _, role := range uc.Roles {
+		if role == uc.Type {
+			return uc.Roles
+		}
+	}
+	return append([]string{uc.Type}, uc.Roles...)
+}
+
+// GetPermissions returns permissions granted directly to the user or the scopes granted to the oauth client
+func (uc *UserClaim) GetPermissions() []string {
+	if uc.MFAPending {
+		return nil
+	}
+	if uc.ClientID != "" {
+		return strings.Fields(uc.Scope)
+	}
+	return uc.Permissions
+}
+
+// TokenID returns the unique id (`jti`) of the token the claim was signed into
+func (uc *UserClaim) TokenID() string {
+	return uc.StandardClaims.Id
+}
+
+// SignToken sign and encodes claim as a jwt token string. Every signed token gets a new unique `jti`.
+func (t *TokenAuthentication) SignToken(c Claim) (string, error) {
+	uc, ok := c.(*UserClaim)
+	if !ok || uc == nil {
+		return "", errors.New("invalid claim: expected *UserClaim")
+	}
+	uc.StandardClaims.Id = uuid.NewV4().String()
+	uc.StandardClaims.IssuedAt = time.Now().Unix()
+	if uc.MFAPending {
+		expiresAt := time.Duration(t.Config.MFAPendingExpiresAt) * time.Minute
+		if expiresAt == 0 {
+			expiresAt = DefaultMFAPendingExpiresAt
+		}
+		uc.StandardClaims.ExpiresAt = time.Now().Add(expiresAt).Unix()
+	} else if t.Config.JWTExpiresAt != 0 {
 		expirationTime := time.Now().Add(time.Duration(t.Config.JWTExpiresAt) * time.Minute)
-		t.User.UserClaim.StandardClaims.ExpiresAt = expirationTime.Unix()
+		uc.StandardClaims.ExpiresAt = expirationTime.Unix()
+	}
+	key, err := t.Keys.SigningKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.Method, uc)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	tokenString, err := token.SignedString(key.PrivateKey)
+	if err != nil {
+		return "", err
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, t.User.UserClaim)
-	tokenString, _ := token.SignedString([]byte(t.Config.JWTSignKey))
 	return base64.StdEncoding.EncodeToString([]byte(tokenString)), nil
 }
 
-// VerifyToken first verifies the authenticity of the jwt token string and then parse the token string into struct
-func (t *TokenAuthentication) VerifyToken(tokenString string) error {
+// VerifyToken first verifies the authenticity of the jwt token string and then parse the token string into claim
+func (t *TokenAuthentication) VerifyToken(tokenString string) (Claim, error) {
 	uc := UserClaim{}
 	data, err := base64.StdEncoding.DecodeString(tokenString)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	token, err := jwt.ParseWithClaims(string(data), &uc, func(token *jwt.Token) (interface{}, error) {
-		return []byte(t.Config.JWTSignKey), nil
-	})
+	token, err := jwt.ParseWithClaims(string(data), &uc, keyFunc(t.Keys))
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if !token.Valid {
-		return err
+		return nil, errors.New("token is invalid")
 	}
 
-	t.SetClaim(&uc)
-	return nil
+	return &uc, nil
 }
 
-// GetClaim returns token claim
-func (t *TokenAuthentication) GetClaim() Claim {
-	return t.User.UserClaim
+// JWKS returns public keys which can be used by other services to verify tokens
+func (t *TokenAuthentication) JWKS() *JWKS {
+	return t.Keys.JWKS()
 }
 
-// SetClaim sets token claim
-func (t *TokenAuthentication) SetClaim(uc Claim) {
-	if uc == nil {
-		return
-	}
-	t.User = &UserAuth{
-		UserClaim: uc.(interface{}).(*UserClaim),
+// keyFunc looks up the verification key by `kid` header and makes sure token is signed with the key's algorithm
+func keyFunc(ks KeyStore) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := ks.VerificationKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return key.PublicKey, nil
 	}
 }
@@ -1,9 +1,10 @@
 package auth
 
 import (
+	"context"
 	"go-app/server/config"
-	"reflect"
 	"testing"
+	"time"
 
 	uuid "github.com/satori/go.uuid"
 	"github.com/stretchr/testify/assert"
@@ -14,7 +15,7 @@ func getTestConfig() *config.TokenAuthConfig {
 	return &c.TokenAuthConfig
 }
 
-var testTokenAuth = NewTokenAuthentication(getTestConfig())
+var testTokenAuth, _ = NewTokenAuthentication(getTestConfig())
 
 func getTestUserClaim() *UserClaim {
 	uc := UserClaim{
@@ -27,11 +28,14 @@ func getTestUserClaim() *UserClaim {
 func TestTokenAuthentication_SignToken(t *testing.T) {
 	type fields struct {
 		Config *config.TokenAuthConfig
-		User   *UserAuth
+	}
+	type args struct {
+		claim Claim
 	}
 	tests := []struct {
 		name    string
 		fields  fields
+		args    args
 		wantErr bool
 	}{
 		{
@@ -39,29 +43,41 @@ func TestTokenAuthentication_SignToken(t *testing.T) {
 			fields: fields{
 				Config: getTestConfig(),
 			},
+			args: args{
+				claim: getTestUserClaim(),
+			},
 			wantErr: false,
 		},
+		{
+			name: "Nil Claim",
+			fields: fields{
+				Config: getTestConfig(),
+			},
+			args: args{
+				claim: nil,
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tAuth := NewTokenAuthentication(tt.fields.Config)
-			tAuth.SetClaim(getTestUserClaim())
-			got, err := tAuth.SignToken()
+			tAuth, err := NewTokenAuthentication(tt.fields.Config)
+			assert.Nil(t, err)
+			got, err := tAuth.SignToken(tt.args.claim)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("TokenAuthentication.SignToken() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			assert.NotEmpty(t, got)
-			assert.NotNil(t, tAuth.User.JWTToken)
+			if !tt.wantErr {
+				assert.NotEmpty(t, got)
+			}
 		})
 	}
 }
 
 func TestTokenAuthentication_VerifyToken(t *testing.T) {
 	uc := getTestUserClaim()
-
-	testTokenAuth.SetClaim(uc)
-	tokenString, _ := testTokenAuth.SignToken()
+	tokenString, _ := testTokenAuth.SignToken(uc)
 
 	testConfigInvalidSignature := getTestConfig()
 	testConfigInvalidSignature.JWTSignKey = "abccadnced"
@@ -71,7 +87,6 @@ func TestTokenAuthentication_VerifyToken(t *testing.T) {
 	}
 	type fields struct {
 		Config *config.TokenAuthConfig
-		User   *UserAuth
 	}
 	tests := []struct {
 		name          string
@@ -89,7 +104,6 @@ func TestTokenAuthentication_VerifyToken(t *testing.T) {
 			},
 			fields: fields{
 				Config: getTestConfig(),
-				User:   &UserAuth{},
 			},
 			wantClaim: uc,
 		},
@@ -101,10 +115,8 @@ func TestTokenAuthentication_VerifyToken(t *testing.T) {
 			},
 			fields: fields{
 				Config: getTestConfig(),
-				User:   &UserAuth{UserClaim: &UserClaim{}},
 			},
 			wantErrString: "illegal base64 data at input byte 208",
-			wantClaim:     &UserClaim{},
 		},
 		{
 			name:    "Invalid Token Signature",
@@ -114,10 +126,8 @@ func TestTokenAuthentication_VerifyToken(t *testing.T) {
 			},
 			fields: fields{
 				Config: testConfigInvalidSignature,
-				User:   &UserAuth{UserClaim: &UserClaim{}},
 			},
 			wantErrString: "signature is invalid",
-			wantClaim:     &UserClaim{},
 		},
 		{
 			name:    "Token Expired",
@@ -127,152 +137,84 @@ func TestTokenAuthentication_VerifyToken(t *testing.T) {
 			},
 			fields: fields{
 				Config: getTestConfig(),
-				User:   &UserAuth{UserClaim: &UserClaim{}},
 			},
 			wantErrString: "token is expired by",
-			wantClaim:     &UserClaim{},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tAuth := &TokenAuthentication{
-				Config: tt.fields.Config,
-				User:   tt.fields.User,
-			}
-			if err := tAuth.VerifyToken(tt.args.tokenString); (err != nil) != tt.wantErr {
+			tAuth, err := NewTokenAuthentication(tt.fields.Config)
+			assert.Nil(t, err)
+			got, err := tAuth.VerifyToken(tt.args.tokenString)
+			if (err != nil) != tt.wantErr {
 				t.Errorf("TokenAuthentication.VerifyToken() error = %v, wantErr %v", err, tt.wantErr)
-				assert.Contains(t, tt.wantErrString, err.Error())
+				return
 			}
-			assert.Equal(t, tt.wantClaim, tAuth.GetClaim())
-
+			if err != nil {
+				assert.Contains(t, err.Error(), tt.wantErrString)
+				assert.Nil(t, got)
+				return
+			}
+			assert.Equal(t, tt.wantClaim, got)
 		})
 	}
 }
 
-func TestTokenAuthentication_GetClaimWithTokenString(t *testing.T) {
+func TestClaimFromContext(t *testing.T) {
 	uc := getTestUserClaim()
-	testTokenAuth.SetClaim(uc)
-	tokenString, _ := testTokenAuth.SignToken()
-	type fields struct {
-		Config *config.TokenAuthConfig
-		User   *UserAuth
-	}
-	type args struct {
-		tokenString string
-	}
 	tests := []struct {
-		name   string
-		args   args
-		fields fields
-		want   Claim
+		name string
+		ctx  context.Context
+		want Claim
 	}{
 		{
-			name: "Basic Get Claim",
-			fields: fields{
-				Config: getTestConfig(),
-				User:   &UserAuth{},
-			},
+			name: "With Claim",
+			ctx:  NewContextWithClaim(context.Background(), uc),
 			want: uc,
-			args: args{
-				tokenString: tokenString,
-			},
 		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			tAuth := &TokenAuthentication{
-				Config: tt.fields.Config,
-				User:   tt.fields.User,
-			}
-			tAuth.VerifyToken(tt.args.tokenString)
-			if got := tAuth.GetClaim(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("TokenAuthentication.GetClaim() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
-func TestTokenAuthentication_GetClaim(t *testing.T) {
-	uc := getTestUserClaim()
-	type fields struct {
-		Config *config.TokenAuthConfig
-		User   *UserAuth
-	}
-	tests := []struct {
-		name   string
-		fields fields
-		want   Claim
-	}{
 		{
-			name: "Basic Get Claim",
-			fields: fields{
-				Config: getTestConfig(),
-				User: &UserAuth{
-					UserClaim: uc,
-				},
-			},
-			want: uc,
+			name: "Without Claim",
+			ctx:  context.Background(),
+			want: nil,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tAuth := &TokenAuthentication{
-				Config: tt.fields.Config,
-				User:   tt.fields.User,
-			}
-			if got := tAuth.GetClaim(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("TokenAuthentication.GetClaim() = %v, want %v", got, tt.want)
-			}
+			assert.Equal(t, tt.want, ClaimFromContext(tt.ctx))
 		})
 	}
 }
 
-func TestTokenAuthentication_SetClaim(t *testing.T) {
-	uc := getTestUserClaim()
-	type fields struct {
-		Config *config.TokenAuthConfig
-		User   *UserAuth
-	}
-	type args struct {
-		uc Claim
-	}
-	tests := []struct {
-		name   string
-		fields fields
-		args   args
-		want   Claim
-	}{
-		{
-			name: "Basic Set Claim",
-			fields: fields{
-				Config: getTestConfig(),
-				User:   &UserAuth{},
-			},
-			args: args{
-				uc: uc,
-			},
-			want: uc,
-		},
-		{
-			name: "Basic Set Claim With Nil Claim",
-			fields: fields{
-				Config: getTestConfig(),
-				User:   &UserAuth{UserClaim: &UserClaim{}},
-			},
-			args: args{
-				uc: nil,
-			},
-			want: &UserClaim{},
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			tAuth := &TokenAuthentication{
-				Config: tt.fields.Config,
-				User:   tt.fields.User,
-			}
-			tAuth.SetClaim(tt.args.uc)
-			assert.Equal(t, tt.want, tAuth.GetClaim())
-		})
-	}
+func TestTokenAuthentication_SignTokenMFAPending(t *testing.T) {
+	ta, _ := NewTokenAuthentication(&config.TokenAuthConfig{JWTSignKey: "abc", JWTExpiresAt: 150})
+	uc := &UserClaim{ID: "1", Type: "admin", Permissions: []string{"orders:read"}, MFAPending: true}
+	token, err := ta.SignToken(uc)
+	assert.Nil(t, err)
+	claim, err := ta.VerifyToken(token)
+	assert.Nil(t, err)
+	got := claim.(*UserClaim)
+	assert.True(t, got.MFAPending)
+	assert.False(t, got.IsAdmin())
+	assert.Empty(t, got.GetRoles())
+	assert.Empty(t, got.GetPermissions())
+	assert.InDelta(t, time.Now().Add(DefaultMFAPendingExpiresAt).Unix(), got.ExpiresAt, 2)
+
+	_, err = NewRefreshTokenStore(nil, &config.TokenAuthConfig{}).Issue(uc)
+	assert.NotNil(t, err)
+}
+
+func TestUserClaim_OAuthScopes(t *testing.T) {
+	ta, _ := NewTokenAuthentication(&config.TokenAuthConfig{JWTSignKey: "abc", JWTExpiresAt: 150})
+	uc := &UserClaim{ID: "1", Type: "admin", Permissions: []string{"*"}, ClientID: "client", Scope: "orders:read orders:write"}
+	token, err := ta.SignToken(uc)
+	assert.Nil(t, err)
+	claim, err := ta.VerifyToken(token)
+	assert.Nil(t, err)
+	got := claim.(*UserClaim)
+	assert.Equal(t, "client", got.ClientID)
+	assert.False(t, got.IsAdmin())
+	assert.Empty(t, got.GetRoles())
+	assert.Equal(t, []string{"orders:read", "orders:write"}, got.GetPermissions())
+	assert.True(t, NewPolicy().HasPermission(got, "orders:read"))
+	assert.False(t, NewPolicy().HasPermission(got, "users:read"))
 }
@@ -2,11 +2,8 @@ package config
 
 import (
 	"fmt"
-	"log"
-	"os"
+	"net"
 	"time"
-
-	"github.com/spf13/viper"
 )
 
 // Config struct stores entire project configurations
@@ -20,17 +17,43 @@ type Config struct {
 	RedisConfig      RedisConfig      `mapstructure:"redis"`
 	MiddlewareConfig MiddlewareConfig `mapstructure:"middleware"`
 	TokenAuthConfig  TokenAuthConfig  `mapstructure:"token"`
+	SessionConfig    SessionConfig    `mapstructure:"session"`
+	RBACConfig       RBACConfig       `mapstructure:"rbac"`
+	OIDCConfig       OIDCConfig       `mapstructure:"oidc"`
 }
 
 // ServerConfig has only server specific configuration
 type ServerConfig struct {
 	ListenAddr     string        `mapstructure:"listenAddr"`
-	Port           string        `mapstructure:"port"`
-	ReadTimeout    time.Duration `mapstructure:"readTimeout"`
-	WriteTimeout   time.Duration `mapstructure:"writeTimeout"`
-	CloseTimeout   time.Duration `mapstructure:"closeTimeout"`
+	Port           string        `mapstructure:"port" validate:"required,numeric"`
+	ReadTimeout    time.Duration `mapstructure:"readTimeout" validate:"min=0"`
+	WriteTimeout   time.Duration `mapstructure:"writeTimeout" validate:"min=0"`
+	CloseTimeout   time.Duration `mapstructure:"closeTimeout" validate:"min=0"`
 	Env            string        `mapstructure:"env"`
 	UseMemoryStore bool          `mapstructure:"useMemoryStore"`
+	TLSConfig      TLSConfig     `mapstructure:"tls"`
+}
+
+// TLSConfig contains https listener configuration. Client certificates are verified when ClientCAFile is set.
+type TLSConfig struct {
+	EnableTLS bool   `mapstructure:"enableTLS"`
+	CertFile  string `mapstructure:"certFile"`
+	KeyFile   string `mapstructure:"keyFile"`
+	// MinVersion is one of 1.2 (default) or 1.3
+	MinVersion string `mapstructure:"minVersion" validate:"omitempty,oneof=1.2 1.3"`
+	// CipherSuites are names of tls 1.2 cipher suites, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Go defaults are used when empty.
+	CipherSuites []string `mapstructure:"cipherSuites"`
+	// ClientCAFile is a pem bundle of CAs client certificates are verified against
+	ClientCAFile string `mapstructure:"clientCAFile"`
+	// ClientAuth is one of require (default) or verify_if_given
+	ClientAuth string            `mapstructure:"clientAuth" validate:"omitempty,oneof=require verify_if_given"`
+	Clients    []TLSClientConfig `mapstructure:"clients" validate:"dive"`
+}
+
+// TLSClientConfig contains scopes granted to the service presenting a client certificate with the common name
+type TLSClientConfig struct {
+	CommonName string   `mapstructure:"commonName" validate:"required"`
+	Scopes     []string `mapstructure:"scopes"`
 }
 
 // APIConfig contains api package related configurations
@@ -39,13 +62,17 @@ type APIConfig struct {
 	EnableTestRoute    bool   `mapstructure:"enableTestRoute"`
 	EnableMediaRoute   bool   `mapstructure:"enableMediaRoute"`
 	EnableStaticRoute  bool   `mapstructure:"enableStaticRoute"`
-	MaxRequestDataSize int    `mapstructure:"maxRequestDataSize"`
+	MaxRequestDataSize int    `mapstructure:"maxRequestDataSize" validate:"min=0"`
 }
 
 // APPConfig contains api package related configurations
 type APPConfig struct {
-	DatabaseConfig DatabaseConfig
-	ExampleConfig  ServiceConfig `mapstructure:"example"`
+	DatabaseConfig DatabaseConfig `validate:"-"`
+	ExampleConfig  ServiceConfig  `mapstructure:"example"`
+	APIKeyConfig   ServiceConfig  `mapstructure:"apiKey"`
+	UserConfig     UserConfig     `mapstructure:"user"`
+	MFAConfig      MFAConfig      `mapstructure:"mfa"`
+	OAuthConfig    OAuthConfig    `mapstructure:"oauth"`
 }
 
 // ServiceConfig contains app service related config
@@ -53,10 +80,99 @@ type ServiceConfig struct {
 	DBName string `mapstructure:"dbName"`
 }
 
+// UserConfig contains user accounts service related config
+type UserConfig struct {
+	DBName string `mapstructure:"dbName"`
+	// ResetTokenExpiresAt is the number of minutes a password reset token stays valid
+	ResetTokenExpiresAt int64              `mapstructure:"resetTokenExpiresAt"`
+	PasswordHashConfig  PasswordHashConfig `mapstructure:"passwordHash"`
+}
+
+// MFAConfig contains multi-factor authentication service related config
+type MFAConfig struct {
+	DBName string `mapstructure:"dbName"`
+	// Issuer is the account issuer shown by authenticator apps
+	Issuer string `mapstructure:"issuer"`
+}
+
+// OAuthConfig contains oauth2 authorization server related config
+type OAuthConfig struct {
+	DBName string `mapstructure:"dbName"`
+	// CodeExpiresAt is the number of seconds an authorization code stays valid
+	CodeExpiresAt int64 `mapstructure:"codeExpiresAt"`
+}
+
+// PasswordHashConfig contains password hashing algorithm and its parameters
+type PasswordHashConfig struct {
+	// Algorithm is one of argon2id (default) or bcrypt
+	Algorithm  string `mapstructure:"algorithm" validate:"omitempty,oneof=argon2id bcrypt"`
+	BcryptCost int    `mapstructure:"bcryptCost" validate:"omitempty,min=4,max=31"`
+	// Argon2Memory is the amount of memory in KiB used by argon2id
+	Argon2Memory  uint32 `mapstructure:"argon2Memory"`
+	Argon2Time    uint32 `mapstructure:"argon2Time"`
+	Argon2Threads uint8  `mapstructure:"argon2Threads"`
+}
+
 // TokenAuthConfig contains token authentication related configuration
 type TokenAuthConfig struct {
-	JWTSignKey   string `mapstructure:"jwtSignKey"`
+	JWTSignKey   Secret `mapstructure:"jwtSignKey"`
 	JWTExpiresAt int64  `mapstructure:"expiresAt"`
+	// RefreshExpiresAt is the number of minutes a refresh token stays valid after it was issued or rotated
+	RefreshExpiresAt int64 `mapstructure:"refreshExpiresAt"`
+	// GracePeriod is the number of minutes a key keeps verifying tokens after a newer key has replaced it
+	GracePeriod int64            `mapstructure:"gracePeriod"`
+	Keys        []TokenKeyConfig `mapstructure:"keys" validate:"dive"`
+	// JWKSURL switches token auth into verify only mode using public keys published by another service
+	JWKSURL string `mapstructure:"jwksUrl" validate:"omitempty,url"`
+	// JWKSCacheTTL is the number of minutes the remote JWKS document is cached
+	JWKSCacheTTL int64 `mapstructure:"jwksCacheTTL"`
+	// MFAPendingExpiresAt is the number of minutes a partial token issued before the second login step stays valid
+	MFAPendingExpiresAt int64 `mapstructure:"mfaPendingExpiresAt"`
+}
+
+// TokenKeyConfig contains a single jwt signing key. The key with the latest ActiveFrom (not in future) signs new tokens.
+type TokenKeyConfig struct {
+	ID             string    `mapstructure:"kid"`
+	Algorithm      string    `mapstructure:"alg"`
+	Secret         Secret    `mapstructure:"secret"`
+	PrivateKeyFile string    `mapstructure:"privateKeyFile"`
+	PublicKeyFile  string    `mapstructure:"publicKeyFile"`
+	ActiveFrom     time.Time `mapstructure:"activeFrom"`
+}
+
+// SessionConfig contains cookie session related configuration
+type SessionConfig struct {
+	EnableSession bool   `mapstructure:"enableSession"`
+	CookieName    string `mapstructure:"cookieName"`
+	Domain        string `mapstructure:"domain"`
+	Path          string `mapstructure:"path"`
+	Secure        bool   `mapstructure:"secure"`
+	HTTPOnly      bool   `mapstructure:"httpOnly"`
+	// SameSite is one of lax, strict or none
+	SameSite string `mapstructure:"sameSite" validate:"omitempty,oneof=lax strict none"`
+	// IdleTimeout is the number of minutes a session stays valid without any request
+	IdleTimeout int64 `mapstructure:"idleTimeout"`
+	// AbsoluteTimeout is the number of minutes after which a session expires regardless of activity
+	AbsoluteTimeout int64 `mapstructure:"absoluteTimeout"`
+}
+
+// RBACConfig contains permissions granted to each role in addition to the ones registered by the app
+type RBACConfig struct {
+	Roles map[string][]string `mapstructure:"roles"`
+}
+
+// OIDCConfig contains openid connect relying party configuration used to sign in users with an external provider
+type OIDCConfig struct {
+	EnableOIDC   bool     `mapstructure:"enableOIDC"`
+	Issuer       string   `mapstructure:"issuer"`
+	ClientID     string   `mapstructure:"clientId"`
+	ClientSecret Secret   `mapstructure:"clientSecret"`
+	RedirectURL  string   `mapstructure:"redirectUrl"`
+	Scopes       []string `mapstructure:"scopes"`
+	// StateExpiresAt is the number of minutes a login started at the provider can be completed
+	StateExpiresAt int64 `mapstructure:"stateExpiresAt"`
+	// LinkByEmail signs in an existing local account with the same verified email instead of rejecting the login
+	LinkByEmail bool `mapstructure:"linkByEmail"`
 }
 
 // KafkaConfig has kafka cluster specific configuration
@@ -65,9 +181,9 @@ type KafkaConfig struct {
 	BrokerDial  string   `mapstructure:"brokerDial"`
 	BrokerURL   string   `mapstructure:"brokerUrl"`
 	BrokerPort  string   `mapstructure:"brokerPort"`
-	Brokers     []string `mapstructure:"brokers"`
+	Brokers     []string `mapstructure:"brokers" validate:"dive,hostname_port"`
 	Username    string   `mapstructure:"username"`
-	Password    string   `mapstructure:"password"`
+	Password    Secret   `mapstructure:"password"`
 }
 
 // ListenerConfig contains app kafka topic listener related config
@@ -92,6 +208,8 @@ type ProducerConfig struct {
 
 // LoggerConfig contains different logger configurations
 type LoggerConfig struct {
+	// Level is one of trace, debug (default), info, warn or error
+	Level               string `mapstructure:"level" validate:"omitempty,oneof=trace debug info warn error" reload:"true"`
 	KafkaLoggerConfig   `mapstructure:"kafkaLog"`
 	FileLoggerConfig    `mapstructure:"fileLog"`
 	ConsoleLoggerConfig `mapstructure:"consoleLog"`
@@ -101,7 +219,7 @@ type LoggerConfig struct {
 type KafkaLoggerConfig struct {
 	EnableKafkaLogger bool   `mapstructure:"enableKafkaLog"`
 	KafkaTopic        string `mapstructure:"kafkaTopic"`
-	KafkaPartition    string `mapstructure:"kafkaPartition"`
+	KafkaPartition    string `mapstructure:"kafkaPartition" validate:"omitempty,numeric"`
 }
 
 // ConsoleLoggerConfig contains file console logging specific configuration
@@ -114,19 +232,19 @@ type FileLoggerConfig struct {
 	FileName         string `mapstructure:"fileName"`
 	Path             string `mapstructure:"path"`
 	EnableFileLogger bool   `mapstructure:"enableFileLog"`
-	MaxBackupsFile   int    `mapstructure:"maxBackupFile"`
-	MaxSize          int    `mapstructure:"maxFileSize"`
-	MaxAge           int    `mapstructure:"maxAge"`
+	MaxBackupsFile   int    `mapstructure:"maxBackupFile" validate:"min=0"`
+	MaxSize          int    `mapstructure:"maxFileSize" validate:"min=0"`
+	MaxAge           int    `mapstructure:"maxAge" validate:"min=0"`
 	Compress         bool   `mapstructure:"compress"`
 }
 
 // DatabaseConfig contains mongodb related configuration
 type DatabaseConfig struct {
-	Scheme string `mapstructure:"scheme"`
-	Host   string `mapstructure:"host"`
+	Scheme string `mapstructure:"scheme" validate:"required,oneof=mongodb mongodb+srv"`
+	Host   string `mapstructure:"host" validate:"required"`
 	// Name     string `mapstructure:"name"`
 	Username   string `mapstructure:"username"`
-	Password   string `mapstructure:"password"`
+	Password   Secret `mapstructure:"password"`
 	ReplicaSet string `mapstructure:"replicaSet"`
 }
 
@@ -134,7 +252,7 @@ type DatabaseConfig struct {
 func (d *DatabaseConfig) ConnectionURL() string {
 	url := fmt.Sprintf("%s://", d.Scheme)
 	if d.Username != "" && d.Password != "" {
-		url += fmt.Sprintf("%s:%s@", d.Username, d.Password)
+		url += fmt.Sprintf("%s:%s@", d.Username, string(d.Password))
 	}
 	url += fmt.Sprintf("%s", d.Host)
 	if d.ReplicaSet != "" {
@@ -145,63 +263,63 @@ func (d *DatabaseConfig) ConnectionURL() string {
 
 // RedisConfig has cache related configuration.
 type RedisConfig struct {
-	Network  string `mapstructure:"network"`
+	Network  string `mapstructure:"network" validate:"omitempty,oneof=tcp unix"`
 	Host     string `mapstructure:"host"`
 	Port     string `mapstructure:"port"`
 	Username string `mapstructure:"username"`
-	Password string `mapstructure:"password"`
+	Password Secret `mapstructure:"password"`
 }
 
-// ConnectionURL returns connection string to of mongodb storage
-func (r *RedisConfig) ConnectionURL() string {
-	var url string
-	if r.Username != "" {
-		url += fmt.Sprintf("%s", r.Username)
+// Addr returns the address redis is dialed at, credentials are sent separately after connecting
+func (r *RedisConfig) Addr() string {
+	if r.Port == "" || r.Network == "unix" {
+		return r.Host
 	}
-	if r.Password != "" {
-		url += fmt.Sprintf(":%s@", r.Password)
-	}
-	url += fmt.Sprintf("%s", r.Host)
-	if r.Port != "" {
-		url += fmt.Sprintf(":%s", r.Port)
+	return net.JoinHostPort(r.Host, r.Port)
+}
+
+// DialNetwork returns the network redis is dialed on, tcp when it is not set
+func (r *RedisConfig) DialNetwork() string {
+	if r.Network == "" {
+		return "tcp"
 	}
-	return url
+	return r.Network
 }
 
 // MiddlewareConfig has middlewares related configuration
 type MiddlewareConfig struct {
-	EnableRequestLog bool `mapstructure:"enableRequestLog"`
+	EnableRequestLog     bool                 `mapstructure:"enableRequestLog" reload:"true"`
+	RequestSigningConfig RequestSigningConfig `mapstructure:"requestSigning"`
 }
 
-// GetConfig returns entire project configuration
-func GetConfig() *Config {
-	return GetConfigFromFile("default")
+// RequestSigningConfig contains shared secrets of internal services allowed to call the api with hmac signed requests
+type RequestSigningConfig struct {
+	EnableRequestSigning bool `mapstructure:"enableRequestSigning"`
+	// MaxClockSkew is the number of seconds timestamp of a signed request may differ from server time
+	MaxClockSkew int64                        `mapstructure:"maxClockSkew" validate:"min=0"`
+	Clients      []RequestSigningClientConfig `mapstructure:"clients" validate:"dive"`
 }
 
-// GetConfigFromFile returns configuration from specific file object
-func GetConfigFromFile(fileName string) *Config {
-	if fileName == "" {
-		fileName = "default"
-	}
+// RequestSigningClientConfig contains shared secret of a single service and the permissions granted to its requests
+type RequestSigningClientConfig struct {
+	ID     string   `mapstructure:"id" validate:"required"`
+	Secret Secret   `mapstructure:"secret" validate:"required"`
+	Scopes []string `mapstructure:"scopes"`
+}
 
-	// looking for filename `default` inside `src/server` dir with `.toml` extension
-	viper.SetConfigName(fileName)
-	viper.AddConfigPath("../conf/")
-	viper.AddConfigPath("../../conf/")
-	viper.AddConfigPath(".")
-	viper.AddConfigPath("./conf/")
-	viper.SetConfigType("toml")
+// GetConfig returns entire project configuration overridden by environment variables and command line arguments,
+// see Load
+func GetConfig(args []string) (*Config, error) {
+	return Load("", args)
+}
 
-	err := viper.ReadInConfig()
-	if err != nil {
-		log.Fatalf("couldn't load config: %s", err)
-		os.Exit(1)
-	}
-	config := &Config{}
-	err = viper.Unmarshal(&config)
+// GetConfigFromFile returns configuration of the environment named after the file, e.g. test for conf/test.toml,
+// overridden by environment variables. local.toml is not merged so that developer settings do not affect tests.
+// It is meant for tests and panics if the configuration can not be loaded.
+func GetConfigFromFile(fileName string) *Config {
+	config, err := load(fileName, nil, false)
 	if err != nil {
-		fmt.Printf("couldn't read config: %s", err)
-		os.Exit(1)
+		panic(err)
 	}
 	return config
 }
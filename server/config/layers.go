@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Config file layers, see Load
+const (
+	DefaultLayer = "default"
+	LocalLayer   = "local"
+)
+
+// findConfigDir returns the first of configPaths containing the default layer
+func findConfigDir() (string, error) {
+	for _, p := range configPaths {
+		if _, err := os.Stat(filepath.Join(p, DefaultLayer+".toml")); err == nil {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("couldn't load config: %s.toml not found in %s", DefaultLayer, strings.Join(configPaths, ", "))
+}
+
+// readLayer returns settings of the toml file with the name in dir, nil is returned when the file does not exist
+func readLayer(dir, name string) (map[string]interface{}, error) {
+	path := filepath.Join(dir, name+".toml")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("toml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("couldn't load config %s: %w", path, err)
+	}
+	return normalize(v.AllSettings()).(map[string]interface{}), nil
+}
+
+// normalize lower cases keys of tables, including the ones in arrays of tables, so that layers can be merged
+// regardless of the case keys are written in
+func normalize(value interface{}) interface{} {
+	switch val := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, v := range val {
+			m[strings.ToLower(k)] = normalize(v)
+		}
+		return m
+	case []map[string]interface{}:
+		s := make([]interface{}, len(val))
+		for i, v := range val {
+			s[i] = normalize(v)
+		}
+		return s
+	case []interface{}:
+		s := make([]interface{}, len(val))
+		for i, v := range val {
+			s[i] = normalize(v)
+		}
+		return s
+	}
+	return value
+}
+
+// merge returns settings of dst overridden by src. Tables are merged key by key, other values including arrays and
+// arrays of tables are replaced. Items of arrays of tables, e.g. tls clients or token keys, are never merged with the
+// item at the same position of dst as that could hand over scopes or key material to another item, a layer redefines
+// the whole array instead. This also lets a layer remove items.
+func merge(dst, src map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(dst)+len(src))
+	for k, v := range dst {
+		out[k] = v
+	}
+	for k, sv := range src {
+		out[k] = mergeValue(out[k], sv)
+	}
+	return out
+}
+
+func mergeValue(dst, src interface{}) interface{} {
+	s, ok := src.(map[string]interface{})
+	if !ok {
+		return src
+	}
+	if d, ok := dst.(map[string]interface{}); ok {
+		return merge(d, s)
+	}
+	return src
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+	type TestCase struct {
+		Name string
+		Dst  map[string]interface{}
+		Src  map[string]interface{}
+		Want map[string]interface{}
+	}
+	tests := []TestCase{
+		{
+			Name: "nested tables",
+			Dst:  map[string]interface{}{"server": map[string]interface{}{"port": "8000", "tls": map[string]interface{}{"enabletls": false, "minversion": "1.2"}}},
+			Src:  map[string]interface{}{"server": map[string]interface{}{"tls": map[string]interface{}{"enabletls": true}}},
+			Want: map[string]interface{}{"server": map[string]interface{}{"port": "8000", "tls": map[string]interface{}{"enabletls": true, "minversion": "1.2"}}},
+		},
+		{
+			Name: "arrays are replaced",
+			Dst:  map[string]interface{}{"brokers": []interface{}{"kafka-1:9092", "kafka-2:9092"}},
+			Src:  map[string]interface{}{"brokers": []interface{}{"kafka:9092"}},
+			Want: map[string]interface{}{"brokers": []interface{}{"kafka:9092"}},
+		},
+		{
+			Name: "arrays of tables are replaced",
+			Dst: map[string]interface{}{"clients": []interface{}{
+				map[string]interface{}{"commonname": "billing", "scopes": []interface{}{"orders:write"}},
+				map[string]interface{}{"commonname": "orders", "scopes": []interface{}{"orders:read"}},
+			}},
+			Src: map[string]interface{}{"clients": []interface{}{
+				map[string]interface{}{"commonname": "reports"},
+			}},
+			Want: map[string]interface{}{"clients": []interface{}{
+				map[string]interface{}{"commonname": "reports"},
+			}},
+		},
+		{
+			Name: "different types are replaced",
+			Dst:  map[string]interface{}{"rbac": map[string]interface{}{"roles": map[string]interface{}{"user": []interface{}{"orders:read"}}}},
+			Src:  map[string]interface{}{"rbac": "none"},
+			Want: map[string]interface{}{"rbac": "none"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Want, merge(tt.Dst, tt.Src))
+		})
+	}
+}
+
+func TestLoad_Layers(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "config")
+	defer os.RemoveAll(dir)
+	defer func(paths []string) { configPaths = paths }(configPaths)
+	configPaths = []string{filepath.Join(dir, "missing"), dir}
+
+	files := map[string]string{
+		"default": `
+[server]
+port="8000"
+env="dev"
+useMemoryStore=true
+
+[token]
+jwtSignKey="abc123"
+expiresAt=15
+
+[database]
+scheme="mongodb"
+host="localhost:27017"
+
+[middleware.requestSigning]
+maxClockSkew=300
+
+    [[middleware.requestSigning.clients]]
+    id="billing"
+    secret="dev-secret"
+    scopes=["orders:read"]
+`,
+		"dev": `
+[server]
+listenAddr="localhost"
+
+[database]
+host="mongo-dev:27017"
+`,
+		"prod": `
+[server]
+listenAddr="0.0.0.0"
+
+[middleware.requestSigning]
+enableRequestSigning=true
+
+    [[middleware.requestSigning.clients]]
+    id="reports"
+    Secret="prod-secret"
+`,
+		"local": `
+[database]
+host="127.0.0.1:27017"
+`,
+	}
+	for name, content := range files {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name+".toml"), []byte(content), 0600))
+	}
+
+	// environment of default.toml and local.toml
+	c, err := Load("", nil)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "dev", c.ServerConfig.Env)
+		assert.Equal(t, "localhost", c.ServerConfig.ListenAddr)
+		assert.Equal(t, "127.0.0.1:27017", c.DatabaseConfig.Host)
+	}
+
+	// environment selected by environment variable, without local.toml.
+	// Clients of prod.toml replace the ones of default.toml, reports does not get the scopes of billing.
+	os.Setenv("GOAPP_SERVER_ENV", "prod")
+	c, err = load("", nil, false)
+	os.Unsetenv("GOAPP_SERVER_ENV")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "prod", c.ServerConfig.Env)
+		assert.Equal(t, "0.0.0.0", c.ServerConfig.ListenAddr)
+		assert.Equal(t, "localhost:27017", c.DatabaseConfig.Host)
+		assert.Equal(t, RequestSigningConfig{
+			EnableRequestSigning: true,
+			MaxClockSkew:         300,
+			Clients:              []RequestSigningClientConfig{{ID: "reports", Secret: "prod-secret"}},
+		}, c.MiddlewareConfig.RequestSigningConfig)
+	}
+
+	// environment selected by flag, file of the environment is optional
+	c, err = load("", []string{"--server.env=staging"}, false)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "staging", c.ServerConfig.Env)
+		assert.Equal(t, "", c.ServerConfig.ListenAddr)
+	}
+
+	// file of the environment given by the caller is required
+	c, err = load("staging", nil, false)
+	assert.Nil(t, c)
+	assert.NotNil(t, err)
+}
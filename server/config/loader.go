@@ -0,0 +1,211 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix prefixes environment variables overriding config fields
+const EnvPrefix = "GOAPP"
+
+// configPaths are directories searched for the config files
+var configPaths = []string{"../conf/", "../../conf/", ".", "./conf/"}
+
+// Load returns configuration of the environment overridden by environment variables and command line flags.
+// The configuration is merged from the following toml files of the conf directory:
+//
+//  1. default.toml, settings shared by all environments
+//  2. <env>.toml, settings of the environment if the file exists
+//  3. local.toml, settings of the developer machine if the file exists, the file is not committed
+//
+// A file overrides the ones listed before it, see merge. The environment is server.env of default.toml unless env is
+// given, server.env can also be set by an environment variable or a flag so that the file of the environment is
+// selected at deployment. Each config field can be set by:
+//
+//  1. flag named after the field key, e.g. --server.port=8080
+//  2. environment variable, e.g. GOAPP_SERVER_PORT=8080
+//  3. config files, e.g. port="8080" in [server] table
+//  4. zero value of the field
+//
+// A source takes precedence over the ones listed after it. Keys are case insensitive, nested tables are separated by
+// dot in flags and by underscore in environment variables. Lists are given comma separated or as json, lists of
+// tables and maps are given as json, e.g. GOAPP_RBAC_ROLES='{"admin":["user:read"]}'.
+// Durations are given in the same unit as in the config file. pflag.ErrHelp is returned when args contain -h or --help.
+// The configuration is validated, Errors listing every problem is returned when it is not valid including unknown keys
+// in the config files.
+func Load(env string, args []string) (*Config, error) {
+	return load(env, args, true)
+}
+
+// load is Load with local.toml merged only if local is true
+func load(env string, args []string, local bool) (*Config, error) {
+	dir, err := findConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	settings, err := readLayer(dir, DefaultLayer)
+	if err != nil {
+		return nil, err
+	}
+	v := viper.New()
+
+	keys := Keys()
+	fs := pflag.NewFlagSet("go-app", pflag.ContinueOnError)
+	fs.SetNormalizeFunc(func(_ *pflag.FlagSet, name string) pflag.NormalizedName {
+		return pflag.NormalizedName(strings.ToLower(name))
+	})
+	for _, key := range keys {
+		if err := v.BindEnv(key, EnvName(key)); err != nil {
+			return nil, fmt.Errorf("couldn't bind %s: %w", EnvName(key), err)
+		}
+		fs.String(key, "", fmt.Sprintf("overrides %s, same as %s", key, EnvName(key)))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	// flags which are not set must not hide values of lower precedence sources behind their empty default
+	var bindErr error
+	fs.Visit(func(f *pflag.Flag) {
+		if err := v.BindPFlag(f.Name, f); err != nil && bindErr == nil {
+			bindErr = fmt.Errorf("couldn't bind flag %s: %w", f.Name, err)
+		}
+	})
+	if bindErr != nil {
+		return nil, bindErr
+	}
+
+	// the file of an environment given by the caller must exist
+	required := env
+	if env == "" {
+		// set by environment variable or flag, otherwise by the default layer
+		env = v.GetString("server.env")
+	}
+	if server, ok := settings["server"].(map[string]interface{}); ok && env == "" {
+		env, _ = server["env"].(string)
+	}
+	layers := []string{env}
+	if local {
+		layers = append(layers, LocalLayer)
+	}
+	for _, name := range layers {
+		if name == "" || name == DefaultLayer {
+			continue
+		}
+		layer, err := readLayer(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		if layer == nil && name == required {
+			return nil, fmt.Errorf("couldn't load config: %s.toml not found in %s", name, dir)
+		}
+		settings = merge(settings, layer)
+	}
+	if err := v.MergeConfigMap(settings); err != nil {
+		return nil, fmt.Errorf("couldn't load config: %w", err)
+	}
+
+	config := &Config{}
+	md := &mapstructure.Metadata{}
+	err = v.Unmarshal(config, viper.DecodeHook(decodeHook()), func(dc *mapstructure.DecoderConfig) {
+		dc.Metadata = md
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read config: %w", err)
+	}
+	errs := unknownKeys(md.Unused)
+	errs = append(errs, config.resolveSecrets()...)
+	if err := config.Validate(); err != nil {
+		verrs, ok := err.(Errors)
+		if !ok {
+			return nil, err
+		}
+		errs = append(errs, verrs...)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return config, nil
+}
+
+// EnvName returns the environment variable overriding the config key, e.g. GOAPP_SERVER_PORT for server.port
+func EnvName(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Keys returns keys of all config fields which can be overridden, e.g. server.tls.certFile.
+// Lists and maps are single fields, their items can not be overridden one by one.
+func Keys() []string {
+	return keys("", reflect.TypeOf(Config{}))
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func keys(prefix string, t reflect.Type) []string {
+	var out []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Tag.Get("mapstructure")
+		if name == "" {
+			name = f.Name
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		if f.Type.Kind() == reflect.Struct && f.Type != timeType {
+			out = append(out, keys(name, f.Type)...)
+			continue
+		}
+		out = append(out, name)
+	}
+	return out
+}
+
+// decodeHook converts override values, which are always strings, into the types of config fields
+func decodeHook() mapstructure.DecodeHookFunc {
+	return mapstructure.ComposeDecodeHookFunc(
+		stringToJSONHook,
+		stringToSecondsHook,
+		mapstructure.StringToTimeHookFunc(time.RFC3339),
+		mapstructure.StringToSliceHookFunc(","),
+	)
+}
+
+// stringToJSONHook decodes json lists and objects given for list or map fields
+func stringToJSONHook(f, t reflect.Type, data interface{}) (interface{}, error) {
+	if f.Kind() != reflect.String || (t.Kind() != reflect.Slice && t.Kind() != reflect.Map) {
+		return data, nil
+	}
+	s := strings.TrimSpace(data.(string))
+	if !strings.HasPrefix(s, "[") && !strings.HasPrefix(s, "{") {
+		return data, nil
+	}
+	var out interface{}
+	if err := json.Unmarshal([]byte(s), &out); err != nil {
+		return nil, fmt.Errorf("invalid json %q: %w", s, err)
+	}
+	return out, nil
+}
+
+// stringToSecondsHook keeps durations given as plain numbers in the unit used by the config file
+func stringToSecondsHook(f, t reflect.Type, data interface{}) (interface{}, error) {
+	if f.Kind() != reflect.String || t != reflect.TypeOf(time.Duration(0)) {
+		return data, nil
+	}
+	s := strings.TrimSpace(data.(string))
+	if s == "" {
+		return time.Duration(0), nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid duration %q, a number is expected", s)
+	}
+	return time.Duration(n), nil
+}
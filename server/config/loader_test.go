@@ -0,0 +1,259 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+// overrides returns values of all fields of the section keyed by config key, in the format they are given in
+// environment variables and flags
+func overrides(prefix string, v reflect.Value) map[string]string {
+	out := map[string]string{}
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		name := f.Tag.Get("mapstructure")
+		if name == "" {
+			name = f.Name
+		}
+		key := prefix + "." + name
+		fv := v.Field(i)
+		switch {
+		case fv.Kind() == reflect.Struct && fv.Type() != timeType:
+			for k, val := range overrides(key, fv) {
+				out[k] = val
+			}
+		case fv.Type() == reflect.TypeOf(time.Duration(0)):
+			out[key] = strconv.FormatInt(fv.Int(), 10)
+		case fv.Kind() == reflect.String:
+			out[key] = fv.String()
+		case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.String:
+			out[key] = strings.Join(fv.Interface().([]string), ",")
+		case fv.Kind() == reflect.Slice || fv.Kind() == reflect.Map:
+			b, _ := json.Marshal(generic(fv))
+			out[key] = string(b)
+		default:
+			out[key] = fmt.Sprint(fv.Interface())
+		}
+	}
+	return out
+}
+
+// generic converts v into maps keyed by mapstructure tags which are marshaled as json overrides
+func generic(v reflect.Value) interface{} {
+	switch {
+	case v.Type() == timeType:
+		return v.Interface().(time.Time).Format(time.RFC3339)
+	case v.Kind() == reflect.String:
+		// secrets are masked when marshaled
+		return v.String()
+	case v.Kind() == reflect.Struct:
+		m := map[string]interface{}{}
+		for i := 0; i < v.NumField(); i++ {
+			m[v.Type().Field(i).Tag.Get("mapstructure")] = generic(v.Field(i))
+		}
+		return m
+	case v.Kind() == reflect.Slice:
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			s[i] = generic(v.Index(i))
+		}
+		return s
+	case v.Kind() == reflect.Map:
+		m := map[string]interface{}{}
+		for _, k := range v.MapKeys() {
+			m[k.String()] = generic(v.MapIndex(k))
+		}
+		return m
+	}
+	return v.Interface()
+}
+
+// section returns the field of config with the mapstructure tag
+func section(c *Config, name string) interface{} {
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Tag.Get("mapstructure") == name {
+			return v.Field(i).Addr().Interface()
+		}
+	}
+	return nil
+}
+
+func TestLoad_Overrides(t *testing.T) {
+	type TestCase struct {
+		Name string
+		Want interface{}
+		// Env sets fields of other sections required by the overridden ones
+		Env map[string]string
+	}
+	tests := []TestCase{
+		{
+			Name: "server",
+			Want: &ServerConfig{
+				ListenAddr:     "0.0.0.0",
+				Port:           "9000",
+				ReadTimeout:    10,
+				WriteTimeout:   20,
+				CloseTimeout:   30,
+				Env:            "prod",
+				UseMemoryStore: true,
+				TLSConfig: TLSConfig{
+					EnableTLS:    true,
+					CertFile:     "/etc/go-app/server.crt",
+					KeyFile:      "/etc/go-app/server.key",
+					MinVersion:   "1.3",
+					CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"},
+					ClientCAFile: "/etc/go-app/ca.crt",
+					ClientAuth:   "verify_if_given",
+					Clients:      []TLSClientConfig{{CommonName: "billing", Scopes: []string{"user:read", "user:write"}}},
+				},
+			},
+		},
+		{
+			Name: "api",
+			Want: &APIConfig{Mode: "release", EnableTestRoute: true, EnableMediaRoute: true, EnableStaticRoute: true, MaxRequestDataSize: 2048},
+		},
+		{
+			Name: "token",
+			Want: &TokenAuthConfig{
+				JWTSignKey:       "secret",
+				JWTExpiresAt:     30,
+				RefreshExpiresAt: 1440,
+				GracePeriod:      60,
+				Keys: []TokenKeyConfig{
+					{ID: "2020-01", Algorithm: "HS256", Secret: "key1", PrivateKeyFile: "a.pem", PublicKeyFile: "a.pub", ActiveFrom: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+					{ID: "2020-06", Algorithm: "RS256", Secret: "key2", PrivateKeyFile: "b.pem", PublicKeyFile: "b.pub", ActiveFrom: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)},
+				},
+				JWKSURL:             "https://auth.example.com/.well-known/jwks.json",
+				JWKSCacheTTL:        15,
+				MFAPendingExpiresAt: 5,
+			},
+		},
+		{
+			Name: "kafka",
+			Want: &KafkaConfig{
+				EnableKafka: true,
+				BrokerDial:  "tcp",
+				BrokerURL:   "kafka",
+				BrokerPort:  "9092",
+				Brokers:     []string{"kafka-1:9092", "kafka-2:9092"},
+				Username:    "go-app",
+				Password:    "kafka-secret",
+			},
+		},
+		{
+			Name: "logger",
+			Want: &LoggerConfig{
+				Level:               "warn",
+				KafkaLoggerConfig:   KafkaLoggerConfig{EnableKafkaLogger: true, KafkaTopic: "app-log", KafkaPartition: "2"},
+				FileLoggerConfig:    FileLoggerConfig{FileName: "app.log", Path: "/var/log/go-app", EnableFileLogger: true, MaxBackupsFile: 3, MaxSize: 10, MaxAge: 7, Compress: true},
+				ConsoleLoggerConfig: ConsoleLoggerConfig{EnableConsoleLogger: true},
+			},
+			Env: map[string]string{"GOAPP_KAFKA_BROKERS": "kafka:9092"},
+		},
+		{
+			Name: "database",
+			Want: &DatabaseConfig{Scheme: "mongodb+srv", Host: "mongo:27017", Username: "go-app", Password: "mongo-secret", ReplicaSet: "rs0"},
+		},
+		{
+			Name: "redis",
+			Want: &RedisConfig{Network: "unix", Host: "redis", Port: "6380", Username: "go-app", Password: "redis-secret"},
+		},
+		{
+			Name: "middleware",
+			Want: &MiddlewareConfig{
+				EnableRequestLog: true,
+				RequestSigningConfig: RequestSigningConfig{
+					EnableRequestSigning: true,
+					MaxClockSkew:         120,
+					Clients:              []RequestSigningClientConfig{{ID: "billing", Secret: "hmac-secret", Scopes: []string{"user:read"}}},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		values := overrides(tt.Name, reflect.ValueOf(tt.Want).Elem())
+		for k, v := range tt.Env {
+			os.Setenv(k, v)
+		}
+		t.Run(tt.Name+" env", func(t *testing.T) {
+			for k, v := range values {
+				os.Setenv(EnvName(k), v)
+				defer os.Unsetenv(EnvName(k))
+			}
+			c, err := load("test", nil, false)
+			if assert.Nil(t, err) {
+				assert.Equal(t, tt.Want, section(c, tt.Name))
+			}
+		})
+		t.Run(tt.Name+" flags", func(t *testing.T) {
+			var args []string
+			for k, v := range values {
+				args = append(args, "--"+k+"="+v)
+			}
+			c, err := load("test", args, false)
+			if assert.Nil(t, err) {
+				assert.Equal(t, tt.Want, section(c, tt.Name))
+			}
+		})
+		for k := range tt.Env {
+			os.Unsetenv(k)
+		}
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	defer os.Unsetenv("GOAPP_SERVER_PORT")
+	defer os.Unsetenv("GOAPP_API_MODE")
+	os.Setenv("GOAPP_SERVER_PORT", "8001")
+	os.Setenv("GOAPP_API_MODE", "env")
+
+	c, err := load("test", []string{"--api.mode", "flag"}, false)
+	assert.Nil(t, err)
+	// flag over env
+	assert.Equal(t, "flag", c.APIConfig.Mode)
+	// env over file
+	assert.Equal(t, "8001", c.ServerConfig.Port)
+	// file is used when neither is set
+	assert.Equal(t, "localhost", c.ServerConfig.ListenAddr)
+	// keys are case insensitive
+	c, err = load("test", []string{"--server.listenaddr=0.0.0.0"}, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "0.0.0.0", c.ServerConfig.ListenAddr)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	type TestCase struct {
+		Name     string
+		FileName string
+		Args     []string
+		Err      error
+	}
+	tests := []TestCase{
+		{Name: "help", FileName: "test", Args: []string{"--help"}, Err: pflag.ErrHelp},
+		{Name: "unknown flag", FileName: "test", Args: []string{"--server.prot=80"}},
+		{Name: "invalid value", FileName: "test", Args: []string{"--api.maxRequestDataSize=1MB"}},
+		{Name: "invalid duration", FileName: "test", Args: []string{"--server.readTimeout=5s"}},
+		{Name: "invalid json", FileName: "test", Args: []string{"--rbac.roles={admin"}},
+		{Name: "missing file", FileName: "missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			c, err := load(tt.FileName, tt.Args, false)
+			assert.Nil(t, c)
+			assert.NotNil(t, err)
+			if tt.Err != nil {
+				assert.Equal(t, tt.Err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
+)
+
+// reloadDelay groups the events of a single save, editors write files in several steps
+const reloadDelay = 100 * time.Millisecond
+
+// Change is a config field which differs between two configurations
+type Change struct {
+	Key string
+	// Reloadable fields are marked with reload:"true" tag, fields of a marked struct are reloadable as well
+	Reloadable bool
+}
+
+// Diff returns the fields which differ between old and new, lists and maps are compared as a whole
+func Diff(old, new *Config) []Change {
+	return diff("", reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem(), false)
+}
+
+func diff(key string, old, new reflect.Value, reloadable bool) []Change {
+	if old.Kind() != reflect.Struct || old.Type() == timeType {
+		if reflect.DeepEqual(old.Interface(), new.Interface()) {
+			return nil
+		}
+		return []Change{{Key: key, Reloadable: reloadable}}
+	}
+	var changes []Change
+	for i := 0; i < old.NumField(); i++ {
+		f := old.Type().Field(i)
+		name := f.Tag.Get("mapstructure")
+		if name == "" {
+			name = f.Name
+		}
+		if key != "" {
+			name = key + "." + name
+		}
+		changes = append(changes, diff(name, old.Field(i), new.Field(i), reloadable || f.Tag.Get("reload") == "true")...)
+	}
+	return changes
+}
+
+// set replaces the field of c with the key by the one of src
+func set(c, src *Config, key string) {
+	dst, from := reflect.ValueOf(c).Elem(), reflect.ValueOf(src).Elem()
+	for _, name := range strings.Split(key, ".") {
+		for i := 0; i < dst.NumField(); i++ {
+			f := dst.Type().Field(i)
+			if f.Tag.Get("mapstructure") == name || f.Name == name {
+				dst, from = dst.Field(i), from.Field(i)
+				break
+			}
+		}
+	}
+	dst.Set(from)
+}
+
+type subscription struct {
+	key string
+	fn  func(c *Config)
+}
+
+// Reloader loads the configuration again when the config files change and applies the changes of reloadable fields
+// to the running server through subscriptions, e.g. the log level. Changes of the other fields, e.g. server.listenAddr,
+// are logged and ignored as they require a restart. A configuration which fails to load is logged and the current one
+// is kept.
+type Reloader struct {
+	Logger *zerolog.Logger
+	// Load returns the configuration from the config files, e.g. Load with the arguments of the process
+	Load func() (*Config, error)
+
+	mu            sync.Mutex
+	current       *Config
+	subscriptions []subscription
+
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+// NewReloader returns a new Reloader instance with c as the running configuration
+func NewReloader(c *Config, load func() (*Config, error), l *zerolog.Logger) *Reloader {
+	return &Reloader{Logger: l, Load: load, current: c}
+}
+
+// Current returns the running configuration including the reloaded fields, it must not be modified
+func (r *Reloader) Current() *Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Subscribe calls fn with the new configuration whenever the reloadable field with the key, or any field nested in it,
+// is changed, e.g. logger.level
+func (r *Reloader) Subscribe(key string, fn func(c *Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscriptions = append(r.subscriptions, subscription{key: strings.ToLower(key), fn: fn})
+}
+
+// Reload loads the configuration and applies the changes of reloadable fields.
+// An error is returned for changes of fields which are not reloadable, the reloadable ones are applied regardless.
+func (r *Reloader) Reload() error {
+	c, err := r.Load()
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	next := *r.current
+	var applied, rejected []string
+	for _, change := range Diff(r.current, c) {
+		if !change.Reloadable {
+			rejected = append(rejected, change.Key)
+			continue
+		}
+		set(&next, c, change.Key)
+		applied = append(applied, change.Key)
+	}
+	r.current = &next
+	var notify []func(c *Config)
+	for _, s := range r.subscriptions {
+		for _, key := range applied {
+			key = strings.ToLower(key)
+			if key == s.key || strings.HasPrefix(key, s.key+".") {
+				notify = append(notify, s.fn)
+				break
+			}
+		}
+	}
+	r.mu.Unlock()
+
+	if len(applied) > 0 {
+		r.Logger.Info().Strs("keys", applied).Msg("config reloaded")
+	}
+	for _, fn := range notify {
+		fn(&next)
+	}
+	if len(rejected) > 0 {
+		return fmt.Errorf("config: %s can not be changed without restart", strings.Join(rejected, ", "))
+	}
+	return nil
+}
+
+// Watch reloads the configuration whenever a file in the config directory changes until Close is called
+func (r *Reloader) Watch() error {
+	dir, err := findConfigDir()
+	if err != nil {
+		return err
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("config: failed to watch config files: %w", err)
+	}
+	if err := w.Add(dir); err != nil {
+		w.Close()
+		return fmt.Errorf("config: failed to watch config files: %w", err)
+	}
+	r.watcher = w
+	r.done = make(chan struct{})
+	go r.watch()
+	return nil
+}
+
+// Close stops watching the config files
+func (r *Reloader) Close() error {
+	if r.watcher == nil {
+		return nil
+	}
+	err := r.watcher.Close()
+	<-r.done
+	r.watcher = nil
+	return err
+}
+
+func (r *Reloader) watch() {
+	defer close(r.done)
+	timer := time.NewTimer(reloadDelay)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case e, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Ext(e.Name) == ".toml" {
+				timer.Reset(reloadDelay)
+			}
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			r.Logger.Error().Err(err).Msg("config watcher failed")
+		case <-timer.C:
+			if err := r.Reload(); err != nil {
+				r.Logger.Error().Err(err).Msg("failed to reload config")
+			}
+		}
+	}
+}
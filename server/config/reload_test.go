@@ -0,0 +1,152 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	type TestCase struct {
+		Name   string
+		Modify func(c *Config)
+		Want   []Change
+	}
+	tests := []TestCase{
+		{Name: "unchanged", Modify: func(c *Config) {}},
+		{
+			Name: "reloadable",
+			Modify: func(c *Config) {
+				c.LoggerConfig.Level = "warn"
+				c.MiddlewareConfig.EnableRequestLog = true
+			},
+			Want: []Change{{Key: "logger.level", Reloadable: true}, {Key: "middleware.enableRequestLog", Reloadable: true}},
+		},
+		{
+			Name: "not reloadable",
+			Modify: func(c *Config) {
+				c.ServerConfig.ListenAddr = "0.0.0.0"
+				c.DatabaseConfig.Host = "mongo:27017"
+				c.LoggerConfig.EnableFileLogger = true
+				c.RBACConfig.Roles = map[string][]string{"user": {"orders:read"}}
+			},
+			Want: []Change{
+				{Key: "server.listenAddr"},
+				{Key: "logger.fileLog.enableFileLog"},
+				{Key: "database.host"},
+				{Key: "rbac.roles"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			c := validTestConfig()
+			tt.Modify(c)
+			assert.Equal(t, tt.Want, Diff(validTestConfig(), c))
+		})
+	}
+}
+
+func TestReloader_Reload(t *testing.T) {
+	l := zerolog.Nop()
+	running := validTestConfig()
+	var next *Config
+	var loadErr error
+	r := NewReloader(running, func() (*Config, error) { return next, loadErr }, &l)
+
+	var levels []string
+	r.Subscribe("logger.level", func(c *Config) { levels = append(levels, c.LoggerConfig.Level) })
+	var middlewareCalls int
+	r.Subscribe("middleware", func(c *Config) { middlewareCalls++ })
+
+	// reloadable fields are applied, the other ones are rejected
+	next = validTestConfig()
+	next.LoggerConfig.Level = "warn"
+	next.ServerConfig.ListenAddr = "0.0.0.0"
+	next.DatabaseConfig.Host = "mongo:27017"
+	err := r.Reload()
+	assert.Equal(t, "config: server.listenAddr, database.host can not be changed without restart", err.Error())
+	assert.Equal(t, []string{"warn"}, levels)
+	assert.Equal(t, 0, middlewareCalls)
+	assert.Equal(t, "warn", r.Current().LoggerConfig.Level)
+	assert.Equal(t, "", r.Current().ServerConfig.ListenAddr)
+	assert.Equal(t, "localhost:27017", r.Current().DatabaseConfig.Host)
+	// running configuration is not modified
+	assert.Equal(t, "", running.LoggerConfig.Level)
+
+	// nested fields notify subscriptions of the parent key
+	next = validTestConfig()
+	next.LoggerConfig.Level = "warn"
+	next.MiddlewareConfig.EnableRequestLog = true
+	assert.Nil(t, r.Reload())
+	assert.Equal(t, []string{"warn"}, levels)
+	assert.Equal(t, 1, middlewareCalls)
+
+	// configuration which fails to load is not applied
+	loadErr = errors.New("invalid configuration")
+	next = nil
+	assert.Equal(t, loadErr, r.Reload())
+	assert.True(t, r.Current().MiddlewareConfig.EnableRequestLog)
+}
+
+func TestReloader_Watch(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "config")
+	defer os.RemoveAll(dir)
+	defer func(paths []string) { configPaths = paths }(configPaths)
+	configPaths = []string{dir}
+
+	write := func(level string) {
+		toml := `
+[server]
+port="8000"
+useMemoryStore=true
+
+[token]
+jwtSignKey="abc123"
+expiresAt=15
+
+[logger]
+level="` + level + `"
+
+[database]
+scheme="mongodb"
+host="localhost:27017"
+`
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "default.toml"), []byte(toml), 0600))
+	}
+	write("info")
+	c, err := Load("", nil)
+	assert.Nil(t, err)
+
+	l := zerolog.Nop()
+	r := NewReloader(c, func() (*Config, error) { return Load("", nil) }, &l)
+	var mu sync.Mutex
+	var levels []string
+	r.Subscribe("logger.level", func(c *Config) {
+		mu.Lock()
+		defer mu.Unlock()
+		levels = append(levels, c.LoggerConfig.Level)
+	})
+	assert.Nil(t, r.Watch())
+	defer r.Close()
+
+	write("error")
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(levels) == 1 && levels[0] == "error"
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// invalid level is rejected by validation and the current one is kept
+	write("verbose")
+	time.Sleep(3 * reloadDelay)
+	assert.Equal(t, "error", r.Current().LoggerConfig.Level)
+	assert.Nil(t, r.Close())
+}
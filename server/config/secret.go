@@ -0,0 +1,179 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// MasterKeyEnv is the environment variable containing base64 encoded 32 bytes key which decrypts enc: secrets
+const MasterKeyEnv = EnvPrefix + "_MASTER_KEY"
+
+// Prefixes of secret references
+const (
+	SecretFilePrefix = "file:"
+	SecretEnvPrefix  = "env:"
+	SecretEncPrefix  = "enc:"
+)
+
+const maskedSecret = "******"
+
+// Secret is a config value which must not be revealed. It is masked when printed by fmt or marshaled as json or text,
+// so that secrets do not leak when the config is logged or dumped. Use string(s) to get the value.
+//
+// The value in config files may be a reference resolved when the config is loaded, see ResolveSecret.
+type Secret string
+
+// String returns the masked secret, an empty secret is returned as it is to show it is not set
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return maskedSecret
+}
+
+// GoString masks the secret printed with %#v
+func (s Secret) GoString() string {
+	return fmt.Sprintf("%q", s.String())
+}
+
+// MarshalText masks the secret in text encodings, e.g. toml
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// MarshalJSON masks the secret in json, e.g. config logged with zerolog
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("%q", s.String())), nil
+}
+
+// ResolveSecret returns value of the secret reference:
+//
+//   - file:/run/secrets/db_pass, content of the file without trailing newline
+//   - env:DB_PASS, value of the environment variable which must be set
+//   - enc:<base64>, value encrypted by EncryptSecret, decrypted with the master key in GOAPP_MASTER_KEY
+//
+// Values without any of the prefixes are returned as they are.
+func ResolveSecret(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, SecretFilePrefix):
+		path := strings.TrimPrefix(ref, SecretFilePrefix)
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("couldn't read secret file: %w", err)
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	case strings.HasPrefix(ref, SecretEnvPrefix):
+		name := strings.TrimPrefix(ref, SecretEnvPrefix)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("secret environment variable %s is not set", name)
+		}
+		return value, nil
+	case strings.HasPrefix(ref, SecretEncPrefix):
+		key, err := masterKey()
+		if err != nil {
+			return "", err
+		}
+		return DecryptSecret(key, strings.TrimPrefix(ref, SecretEncPrefix))
+	}
+	return ref, nil
+}
+
+// masterKey returns the key in GOAPP_MASTER_KEY
+func masterKey() ([]byte, error) {
+	encoded, ok := os.LookupEnv(MasterKeyEnv)
+	if !ok {
+		return nil, fmt.Errorf("%s is required to decrypt the secret", MasterKeyEnv)
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s must be a base64 encoded 32 bytes key", MasterKeyEnv)
+	}
+	return key, nil
+}
+
+// EncryptSecret encrypts the secret with AES-256-GCM and returns the reference to be stored in config files,
+// e.g. enc:3q2+7w...
+func EncryptSecret(key []byte, secret string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(secret), nil)
+	return SecretEncPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptSecret decrypts base64 encoded nonce and ciphertext returned by EncryptSecret without the enc: prefix
+func DecryptSecret(key []byte, encoded string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return "", errors.New("encrypted secret is not valid base64")
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("couldn't decrypt secret, master key does not match")
+	}
+	return string(plain), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+var secretType = reflect.TypeOf(Secret(""))
+
+// resolveSecrets replaces secret references of all Secret fields with their values, an error is returned for every
+// reference which can not be resolved
+func (c *Config) resolveSecrets() Errors {
+	return resolveSecrets("", reflect.ValueOf(c).Elem())
+}
+
+func resolveSecrets(key string, v reflect.Value) Errors {
+	var errs Errors
+	switch {
+	case v.Type() == secretType:
+		value, err := ResolveSecret(v.String())
+		if err != nil {
+			return Errors{fmt.Errorf("%s: %s", key, err)}
+		}
+		v.SetString(value)
+	case v.Kind() == reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			name := f.Tag.Get("mapstructure")
+			if name == "" {
+				name = f.Name
+			}
+			if key != "" {
+				name = key + "." + name
+			}
+			errs = append(errs, resolveSecrets(name, v.Field(i))...)
+		}
+	case v.Kind() == reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			errs = append(errs, resolveSecrets(fmt.Sprintf("%s[%d]", key, i), v.Index(i))...)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"go-app/server/validator"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	ut "github.com/go-playground/universal-translator"
+	govalidator "github.com/go-playground/validator/v10"
+)
+
+// Errors contains every problem found in the configuration
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = "  - " + err.Error()
+	}
+	return "invalid configuration:\n" + strings.Join(msgs, "\n")
+}
+
+var (
+	configValidator     *validator.Validator
+	configValidatorOnce sync.Once
+)
+
+// getValidator returns the request validator reporting fields by their config keys instead of json names
+func getValidator() *validator.Validator {
+	configValidatorOnce.Do(func() {
+		v := validator.NewValidation()
+		v.V.RegisterTagNameFunc(func(fld reflect.StructField) string {
+			if name := fld.Tag.Get("mapstructure"); name != "" {
+				return name
+			}
+			return fld.Name
+		})
+		v.V.RegisterTranslation("hostname_port", *v.T, func(trans ut.Translator) error {
+			return trans.Add("hostname_port", "{0} must be in host:port format", false)
+		}, func(trans ut.Translator, fe govalidator.FieldError) string {
+			msg, _ := trans.T("hostname_port", fe.Field())
+			return msg
+		})
+		configValidator = v
+	})
+	return configValidator
+}
+
+// Validate checks the field formats and the fields required by the enabled features.
+// All the problems are returned at once as Errors, nil is returned when the configuration is valid.
+func (c *Config) Validate() error {
+	var errs Errors
+	v := getValidator()
+	if err := v.V.Struct(c); err != nil {
+		fieldErrs, ok := err.(govalidator.ValidationErrors)
+		if !ok {
+			return err
+		}
+		for _, fe := range fieldErrs {
+			// translated messages begin with the field name which is replaced with the full key, e.g. server.port
+			key := strings.TrimPrefix(fe.Namespace(), "Config.")
+			errs = append(errs, fmt.Errorf("%s%s", key, strings.TrimPrefix(fe.Translate(*v.T), fe.Field())))
+		}
+	}
+	errs = append(errs, c.validateFeatures()...)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// validateFeatures checks fields which are required only when a feature using them is enabled
+func (c *Config) validateFeatures() Errors {
+	var errs Errors
+	require := func(enabled bool, feature, key string, value interface{}) {
+		if enabled && reflect.ValueOf(value).Len() == 0 {
+			errs = append(errs, fmt.Errorf("%s is required when %s is enabled", key, feature))
+		}
+	}
+
+	tls := c.ServerConfig.TLSConfig
+	require(tls.EnableTLS, "server.tls.enableTLS", "server.tls.certFile", tls.CertFile)
+	require(tls.EnableTLS, "server.tls.enableTLS", "server.tls.keyFile", tls.KeyFile)
+
+	if !c.ServerConfig.UseMemoryStore && c.RedisConfig.Host == "" {
+		errs = append(errs, fmt.Errorf("redis.host is required when server.useMemoryStore is disabled"))
+	}
+
+	token := c.TokenAuthConfig
+	if token.JWTSignKey == "" && len(token.Keys) == 0 && token.JWKSURL == "" {
+		errs = append(errs, fmt.Errorf("token.jwtSignKey is required when neither token.keys nor token.jwksUrl is set"))
+	}
+	// refresh tokens are issued with every signed access token, which must expire for rotation to have any effect
+	if token.JWKSURL == "" && token.JWTExpiresAt <= 0 {
+		errs = append(errs, fmt.Errorf("token.expiresAt must be set when refresh tokens are issued, i.e. token.jwksUrl is not set"))
+	}
+
+	kafka := c.KafkaConfig
+	require(kafka.EnableKafka, "kafka.enableKafka", "kafka.brokers", kafka.Brokers)
+	kafkaLog := c.LoggerConfig.KafkaLoggerConfig
+	require(kafkaLog.EnableKafkaLogger, "logger.kafkaLog.enableKafkaLog", "kafka.brokers", kafka.Brokers)
+	require(kafkaLog.EnableKafkaLogger, "logger.kafkaLog.enableKafkaLog", "logger.kafkaLog.kafkaTopic", kafkaLog.KafkaTopic)
+
+	fileLog := c.LoggerConfig.FileLoggerConfig
+	require(fileLog.EnableFileLogger, "logger.fileLog.enableFileLog", "logger.fileLog.fileName", fileLog.FileName)
+	require(fileLog.EnableFileLogger, "logger.fileLog.enableFileLog", "logger.fileLog.path", fileLog.Path)
+
+	oidc := c.OIDCConfig
+	require(oidc.EnableOIDC, "oidc.enableOIDC", "oidc.issuer", oidc.Issuer)
+	require(oidc.EnableOIDC, "oidc.enableOIDC", "oidc.clientId", oidc.ClientID)
+	require(oidc.EnableOIDC, "oidc.enableOIDC", "oidc.redirectUrl", oidc.RedirectURL)
+
+	signing := c.MiddlewareConfig.RequestSigningConfig
+	require(signing.EnableRequestSigning, "middleware.requestSigning.enableRequestSigning", "middleware.requestSigning.clients", signing.Clients)
+	return errs
+}
+
+// unknownKeys returns an error for each key of the config file which does not match any config field,
+// the keys are given as reported by the decoder, e.g. logger.kafkaLog.kafkaparition
+func unknownKeys(keys []string) Errors {
+	sort.Strings(keys)
+	var errs Errors
+	for _, key := range keys {
+		errs = append(errs, fmt.Errorf("%s is not a known key", key))
+	}
+	return errs
+}
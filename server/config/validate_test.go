@@ -0,0 +1,162 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func validTestConfig() *Config {
+	return &Config{
+		ServerConfig:    ServerConfig{Port: "8000", UseMemoryStore: true},
+		TokenAuthConfig: TokenAuthConfig{JWTSignKey: "abc123", JWTExpiresAt: 15},
+		DatabaseConfig:  DatabaseConfig{Scheme: "mongodb", Host: "localhost:27017"},
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	type TestCase struct {
+		Name   string
+		Modify func(c *Config)
+		Errs   []string
+	}
+	tests := []TestCase{
+		{Name: "valid", Modify: func(c *Config) {}},
+		{
+			Name: "invalid formats",
+			Modify: func(c *Config) {
+				c.ServerConfig.Port = "http"
+				c.ServerConfig.TLSConfig.MinVersion = "1.1"
+				c.DatabaseConfig.Host = ""
+			},
+			Errs: []string{
+				"server.port must be a valid numeric value",
+				"server.tls.minVersion must be one of [1.2 1.3]",
+				"database.host is a required field",
+			},
+		},
+		{
+			Name: "list items",
+			Modify: func(c *Config) {
+				c.KafkaConfig.Brokers = []string{"kafka"}
+				c.MiddlewareConfig.RequestSigningConfig.Clients = []RequestSigningClientConfig{{ID: "billing"}}
+			},
+			Errs: []string{
+				"kafka.brokers[0] must be in host:port format",
+				"middleware.requestSigning.clients[0].secret is a required field",
+			},
+		},
+		{
+			Name: "kafka logger without brokers",
+			Modify: func(c *Config) {
+				c.LoggerConfig.EnableKafkaLogger = true
+			},
+			Errs: []string{
+				"kafka.brokers is required when logger.kafkaLog.enableKafkaLog is enabled",
+				"logger.kafkaLog.kafkaTopic is required when logger.kafkaLog.enableKafkaLog is enabled",
+			},
+		},
+		{
+			Name: "missing redis and token key",
+			Modify: func(c *Config) {
+				c.ServerConfig.UseMemoryStore = false
+				c.TokenAuthConfig.JWTSignKey = ""
+			},
+			Errs: []string{
+				"redis.host is required when server.useMemoryStore is disabled",
+				"token.jwtSignKey is required when neither token.keys nor token.jwksUrl is set",
+			},
+		},
+		{
+			Name: "access tokens without expiry",
+			Modify: func(c *Config) {
+				c.TokenAuthConfig.JWTExpiresAt = 0
+			},
+			Errs: []string{
+				"token.expiresAt must be set when refresh tokens are issued, i.e. token.jwksUrl is not set",
+			},
+		},
+		{
+			Name: "verify only token auth",
+			Modify: func(c *Config) {
+				c.TokenAuthConfig.JWTSignKey = ""
+				c.TokenAuthConfig.JWTExpiresAt = 0
+				c.TokenAuthConfig.JWKSURL = "https://auth.example.com/.well-known/jwks.json"
+			},
+		},
+		{
+			Name: "enabled features",
+			Modify: func(c *Config) {
+				c.ServerConfig.TLSConfig.EnableTLS = true
+				c.LoggerConfig.EnableFileLogger = true
+				c.OIDCConfig.EnableOIDC = true
+				c.OIDCConfig.ClientID = "go-app"
+			},
+			Errs: []string{
+				"server.tls.certFile is required when server.tls.enableTLS is enabled",
+				"server.tls.keyFile is required when server.tls.enableTLS is enabled",
+				"logger.fileLog.fileName is required when logger.fileLog.enableFileLog is enabled",
+				"logger.fileLog.path is required when logger.fileLog.enableFileLog is enabled",
+				"oidc.issuer is required when oidc.enableOIDC is enabled",
+				"oidc.redirectUrl is required when oidc.enableOIDC is enabled",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			c := validTestConfig()
+			tt.Modify(c)
+			err := c.Validate()
+			if len(tt.Errs) == 0 {
+				assert.Nil(t, err)
+				return
+			}
+			var msgs []string
+			for _, e := range err.(Errors) {
+				msgs = append(msgs, e.Error())
+			}
+			assert.Equal(t, tt.Errs, msgs)
+		})
+	}
+}
+
+func TestLoad_UnknownKeys(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "config")
+	defer os.RemoveAll(dir)
+	defer func(paths []string) { configPaths = paths }(configPaths)
+	configPaths = []string{dir}
+
+	base := `
+[server]
+port="8000"
+useMemoryStore=true
+
+[token]
+jwtSignKey="abc123"
+expiresAt=15
+
+[database]
+scheme="mongodb"
+host="localhost:27017"
+`
+	typo := `
+[server]
+port="http"
+
+[logger]
+    [logger.kafkaLog]
+    kafkaParition=1
+`
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "default.toml"), []byte(base), 0600))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "typo.toml"), []byte(typo), 0600))
+	c, err := load("typo", nil, false)
+	assert.Nil(t, c)
+	assert.Equal(t, Errors{
+		errors.New("logger.kafkaLog.kafkaparition is not a known key"),
+		errors.New("server.port must be a valid numeric value"),
+	}.Error(), err.Error())
+}
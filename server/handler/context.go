@@ -36,6 +36,15 @@ func (requestCTX *RequestContext) SetAppResponse(message interface{}, statusCode
 	}
 }
 
+// SetRawJSONResponse := setting json response in request context which is encoded as it is without success and payload keys
+func (requestCTX *RequestContext) SetRawJSONResponse(message interface{}, statusCode int) {
+	requestCTX.ResponseType = RawJSONResp
+	requestCTX.ResponseCode = statusCode
+	requestCTX.Response = &AppResponse{
+		Payload: message,
+	}
+}
+
 // SetCustomResponse := setting app response in request context
 func (requestCTX *RequestContext) SetCustomResponse(success bool, message interface{}, err interface{}, statusCode int) {
 	requestCTX.ResponseType = JSONResp
@@ -5,16 +5,30 @@ import (
 	"go-app/server/auth"
 	"go-app/server/middleware"
 	"net/http"
+	"strings"
 
 	errors "github.com/vasupal1996/goerror"
 )
 
-// Request represents a request from client
+// Request represents a request from client.
+// When Roles or Permissions are set the caller must be logged in, have any of the Roles and all of the Permissions
+// according to Policy.
+// Callers authenticate with a bearer token in Authorization header, an api key in X-API-Key header, a claim set in
+// request context by a middleware, a client certificate verified by the tls listener or a session cookie. Claims
+// waiting for mfa verification are rejected unless AllowMFAPending is set.
 type Request struct {
 	HandlerFunc func(*RequestContext, http.ResponseWriter, *http.Request)
 	AuthFunc    auth.TokenAuth
+	APIKeys     auth.APIKeyAuth
+	ClientCerts auth.ClientCertAuth
+	Revocations auth.RevocationList
+	Session     auth.Session
+	Policy      *auth.Policy
 	IsLoggedIn  bool
-	IsSudoUser  bool
+	// AllowMFAPending accepts partial claims issued before the second login step
+	AllowMFAPending bool
+	Roles           []string
+	Permissions     []string
 }
 
 // HandleRequest := handles incoming requests from client
@@ -23,27 +37,69 @@ func (rh *Request) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	requestCTX.RequestID = middleware.RequestIDFromContext(r.Context())
 	requestCTX.Path = r.URL.Path
 
-	authToken := r.Header.Get("Authorization")
+	// bearer scheme is optional, oauth clients always send it.
+	// Basic credentials are left to the handler, e.g. oauth client authentication.
+	authToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if strings.HasPrefix(authToken, "Basic ") {
+		authToken = ""
+	}
 	if authToken != "" {
-		err := rh.AuthFunc.VerifyToken(authToken)
+		claim, err := rh.AuthFunc.VerifyToken(authToken)
 		if err != nil {
 			requestCTX.SetErr(errors.New("failed to verify token", &errors.PermissionDenied), http.StatusUnauthorized)
 			goto SKIP_REQUEST
-		} else {
-			requestCTX.UserClaim = rh.AuthFunc.GetClaim().(*auth.UserClaim)
+		}
+		if rh.Revocations != nil {
+			revoked, err := rh.Revocations.IsRevoked(claim)
+			if err != nil || revoked {
+				requestCTX.SetErr(errors.New("token is revoked", &errors.PermissionDenied), http.StatusUnauthorized)
+				goto SKIP_REQUEST
+			}
+		}
+		requestCTX.UserClaim = claim
+		r = r.WithContext(auth.NewContextWithClaim(r.Context(), claim))
+	} else if apiKey := r.Header.Get(auth.HeaderAPIKey); apiKey != "" && rh.APIKeys != nil {
+		claim, err := rh.APIKeys.VerifyAPIKey(apiKey)
+		if err != nil {
+			requestCTX.SetErr(errors.New("invalid api key", &errors.PermissionDenied), http.StatusUnauthorized)
+			goto SKIP_REQUEST
+		}
+		requestCTX.UserClaim = claim
+		r = r.WithContext(auth.NewContextWithClaim(r.Context(), claim))
+	} else if claim := auth.ClaimFromContext(r.Context()); claim != nil {
+		// claim set by a middleware, e.g. of a signed request
+		requestCTX.UserClaim = claim
+	} else if rh.ClientCerts != nil && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		// only certificates verified against the client CA are accepted, not any certificate the client presented
+		claim, err := rh.ClientCerts.VerifyClientCertificate(r.TLS.VerifiedChains[0][0])
+		if err != nil {
+			requestCTX.SetErr(errors.New("invalid client certificate", &errors.PermissionDenied), http.StatusUnauthorized)
+			goto SKIP_REQUEST
+		}
+		requestCTX.UserClaim = claim
+		r = r.WithContext(auth.NewContextWithClaim(r.Context(), claim))
+	} else if rh.Session != nil {
+		// missing or expired session is treated as an anonymous request
+		if claim, err := rh.Session.Get(r); err == nil {
+			requestCTX.UserClaim = claim
+			r = r.WithContext(auth.NewContextWithClaim(r.Context(), claim))
 		}
 	}
 
-	if rh.IsLoggedIn {
+	if uc, ok := requestCTX.UserClaim.(*auth.UserClaim); ok && uc.MFAPending && !rh.AllowMFAPending {
+		requestCTX.SetErr(errors.New("mfa verification required", &errors.PermissionDenied), http.StatusUnauthorized)
+		goto SKIP_REQUEST
+	}
+
+	if rh.IsLoggedIn || len(rh.Roles) > 0 || len(rh.Permissions) > 0 {
 		if requestCTX.UserClaim == nil {
 			requestCTX.SetErr(errors.New("auth token required", &errors.PermissionDenied), http.StatusUnauthorized)
 			goto SKIP_REQUEST
-		} else {
-			if rh.IsSudoUser {
-				if requestCTX.UserClaim.IsAdmin() {
-					requestCTX.SetErr(errors.New("permission denied: required admin user role", &errors.PermissionDenied), http.StatusForbidden)
-					goto SKIP_REQUEST
-				}
+		}
+		if len(rh.Roles) > 0 || len(rh.Permissions) > 0 {
+			if rh.Policy == nil || !rh.Policy.Authorize(requestCTX.UserClaim, rh.Roles, rh.Permissions) {
+				requestCTX.SetErr(errors.New("permission denied", &errors.PermissionDenied), http.StatusForbidden)
+				goto SKIP_REQUEST
 			}
 		}
 	}
@@ -55,25 +111,31 @@ SKIP_REQUEST:
 		rh.HandlerFunc(requestCTX, w, r)
 	}
 
+	// headers must be set before writing the status code
+	switch requestCTX.ResponseType {
+	case HTMLResp:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	case JSONResp, RawJSONResp, ErrorResp:
+		w.Header().Set("Content-Type", "application/json")
+	}
+
 	if requestCTX.ResponseCode != 0 && requestCTX.ResponseType != RedirectResp {
 		w.WriteHeader(requestCTX.ResponseCode)
 	}
 
 	switch t := requestCTX.ResponseType; t {
 	case HTMLResp:
-		w.Header().Set("Content-Type", "text/html")
 		res := requestCTX.Response.GetRaw()
 		w.Write(res.([]byte))
 	case JSONResp:
-		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(requestCTX.Response)
+	case RawJSONResp:
+		json.NewEncoder(w).Encode(requestCTX.Response.GetRaw())
 	case ErrorResp:
-		w.Header().Set("Content-Type", "application/json")
 		requestCTX.Err.RequestID = &requestCTX.RequestID
 		json.NewEncoder(w).Encode(&requestCTX.Err)
 	case RedirectResp:
-		res, _ := requestCTX.Response.MarshalJSON()
-		http.Redirect(w, r, string(res), requestCTX.ResponseCode)
+		http.Redirect(w, r, requestCTX.Response.GetRaw().(string), requestCTX.ResponseCode)
 	}
 
 }
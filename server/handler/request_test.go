@@ -0,0 +1,348 @@
+package handler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"go-app/server/auth"
+	"go-app/server/config"
+	memorystorage "go-app/server/storage/memory"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestConfig() *config.Config {
+	return config.GetConfigFromFile("test")
+}
+
+// TestRequest_ServeHTTPConcurrentTokens hammers a single Request handler, sharing one TokenAuth, with many distinct
+// tokens in parallel. Run with `go test -race` to detect shared claim state between requests.
+func TestRequest_ServeHTTPConcurrentTokens(t *testing.T) {
+	const workers = 50
+	const requestsPerWorker = 20
+
+	ta, _ := auth.NewTokenAuthentication(&getTestConfig().TokenAuthConfig)
+	rh := &Request{
+		AuthFunc:   ta,
+		IsLoggedIn: true,
+		HandlerFunc: func(requestCTX *RequestContext, w http.ResponseWriter, r *http.Request) {
+			uc := requestCTX.UserClaim.(*auth.UserClaim)
+			ctxClaim := auth.ClaimFromContext(r.Context())
+			if ctxClaim != requestCTX.UserClaim {
+				requestCTX.SetAppResponse("context claim mismatch", http.StatusInternalServerError)
+				return
+			}
+			requestCTX.SetAppResponse(uc.ID, http.StatusOK)
+		},
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < requestsPerWorker; j++ {
+				id := fmt.Sprintf("user-%d-%d", i, j)
+				token, err := ta.SignToken(&auth.UserClaim{ID: id, Type: "user"})
+				if !assert.Nil(t, err) {
+					return
+				}
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Authorization", token)
+				recorder := httptest.NewRecorder()
+				rh.ServeHTTP(recorder, req)
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.Equal(t, fmt.Sprintf("{\"success\":true,\"payload\":%q}\n", id), recorder.Body.String())
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestRequest_ServeHTTP(t *testing.T) {
+	ta, _ := auth.NewTokenAuthentication(&getTestConfig().TokenAuthConfig)
+	token, _ := ta.SignToken(&auth.UserClaim{ID: "1", Type: "user"})
+	h := func(requestCTX *RequestContext, w http.ResponseWriter, r *http.Request) {
+		requestCTX.SetAppResponse("ok", http.StatusOK)
+	}
+	tests := []struct {
+		name       string
+		isLoggedIn bool
+		token      string
+		wantCode   int
+	}{
+		{
+			name:       "Anonymous Request",
+			isLoggedIn: false,
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:       "Login Required Without Token",
+			isLoggedIn: true,
+			wantCode:   http.StatusUnauthorized,
+		},
+		{
+			name:       "Login Required With Token",
+			isLoggedIn: true,
+			token:      token,
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:       "Invalid Token",
+			isLoggedIn: false,
+			token:      "invalid",
+			wantCode:   http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rh := &Request{HandlerFunc: h, AuthFunc: ta, IsLoggedIn: tt.isLoggedIn}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", tt.token)
+			}
+			recorder := httptest.NewRecorder()
+			rh.ServeHTTP(recorder, req)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+		})
+	}
+}
+
+func TestRequest_ServeHTTPSession(t *testing.T) {
+	ta, _ := auth.NewTokenAuthentication(&getTestConfig().TokenAuthConfig)
+	cs := auth.NewCookieSession(memorystorage.NewMemoryStorageWithCleanupInterval(0), &config.SessionConfig{})
+	login := httptest.NewRecorder()
+	cs.Create(login, httptest.NewRequest(http.MethodPost, "/", nil), &auth.UserClaim{ID: "1", Type: "user"})
+
+	rh := &Request{
+		AuthFunc:   ta,
+		Session:    cs,
+		IsLoggedIn: true,
+		HandlerFunc: func(requestCTX *RequestContext, w http.ResponseWriter, r *http.Request) {
+			requestCTX.SetAppResponse(requestCTX.UserClaim.(*auth.UserClaim).ID, http.StatusOK)
+		},
+	}
+	tests := []struct {
+		name     string
+		cookies  []*http.Cookie
+		wantCode int
+	}{
+		{
+			name:     "Valid Session Cookie",
+			cookies:  login.Result().Cookies(),
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Unknown Session Cookie",
+			cookies:  []*http.Cookie{{Name: auth.DefaultSessionCookieName, Value: "unknown"}},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "Without Session Cookie",
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for _, c := range tt.cookies {
+				req.AddCookie(c)
+			}
+			recorder := httptest.NewRecorder()
+			rh.ServeHTTP(recorder, req)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+		})
+	}
+}
+
+func TestRequest_ServeHTTPAuthorization(t *testing.T) {
+	ta, _ := auth.NewTokenAuthentication(&getTestConfig().TokenAuthConfig)
+	policy := auth.NewPolicy()
+	policy.Grant("user", "orders:read")
+	h := func(requestCTX *RequestContext, w http.ResponseWriter, r *http.Request) {
+		requestCTX.SetAppResponse("ok", http.StatusOK)
+	}
+	token := func(uc *auth.UserClaim) string {
+		s, _ := ta.SignToken(uc)
+		return s
+	}
+	tests := []struct {
+		name        string
+		roles       []string
+		permissions []string
+		policy      *auth.Policy
+		token       string
+		wantCode    int
+	}{
+		{
+			name:     "Admin Role Allows Admin",
+			roles:    []string{auth.RoleAdmin},
+			policy:   policy,
+			token:    token(&auth.UserClaim{ID: "1", Type: "admin"}),
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Admin Role Denies User",
+			roles:    []string{auth.RoleAdmin},
+			policy:   policy,
+			token:    token(&auth.UserClaim{ID: "1", Type: "user"}),
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:        "Permission Granted",
+			permissions: []string{"orders:read"},
+			policy:      policy,
+			token:       token(&auth.UserClaim{ID: "1", Type: "user"}),
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:        "Permission Denied",
+			permissions: []string{"orders:write"},
+			policy:      policy,
+			token:       token(&auth.UserClaim{ID: "1", Type: "user"}),
+			wantCode:    http.StatusForbidden,
+		},
+		{
+			name:        "Permission Without Token",
+			permissions: []string{"orders:read"},
+			policy:      policy,
+			wantCode:    http.StatusUnauthorized,
+		},
+		{
+			name:        "Permission Without Policy",
+			permissions: []string{"orders:read"},
+			token:       token(&auth.UserClaim{ID: "1", Type: "admin"}),
+			wantCode:    http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rh := &Request{HandlerFunc: h, AuthFunc: ta, Policy: tt.policy, Roles: tt.roles, Permissions: tt.permissions}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", tt.token)
+			}
+			recorder := httptest.NewRecorder()
+			rh.ServeHTTP(recorder, req)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+		})
+	}
+}
+
+func TestRequest_ServeHTTPContextClaim(t *testing.T) {
+	policy := auth.NewPolicy()
+	h := func(requestCTX *RequestContext, w http.ResponseWriter, r *http.Request) {
+		requestCTX.SetAppResponse("ok", http.StatusOK)
+	}
+	tests := []struct {
+		name     string
+		claim    auth.Claim
+		wantCode int
+	}{
+		{
+			name:     "Service With Scope",
+			claim:    &auth.ServiceClaim{ID: "billing", Scopes: []string{"orders:read"}},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Service Without Scope",
+			claim:    &auth.ServiceClaim{ID: "billing", Scopes: []string{"users:read"}},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Without Claim",
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rh := &Request{HandlerFunc: h, Policy: policy, Permissions: []string{"orders:read"}}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.claim != nil {
+				req = req.WithContext(auth.NewContextWithClaim(req.Context(), tt.claim))
+			}
+			recorder := httptest.NewRecorder()
+			rh.ServeHTTP(recorder, req)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+		})
+	}
+}
+
+func TestRequest_ServeHTTPRedirect(t *testing.T) {
+	rh := &Request{
+		HandlerFunc: func(requestCTX *RequestContext, w http.ResponseWriter, r *http.Request) {
+			requestCTX.SetRedirectResponse("https://client.example.com/cb?code=abc&state=xyz", http.StatusFound)
+		},
+	}
+	recorder := httptest.NewRecorder()
+	rh.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusFound, recorder.Code)
+	assert.Equal(t, "https://client.example.com/cb?code=abc&state=xyz", recorder.Header().Get("Location"))
+}
+
+func TestRequest_ServeHTTPClientCertificate(t *testing.T) {
+	ta, _ := auth.NewTokenAuthentication(&getTestConfig().TokenAuthConfig)
+	rh := &Request{
+		AuthFunc:    ta,
+		ClientCerts: auth.NewClientCertificateAuth(&config.TLSConfig{Clients: []config.TLSClientConfig{{CommonName: "billing", Scopes: []string{"orders:read"}}}}),
+		IsLoggedIn:  true,
+		HandlerFunc: func(requestCTX *RequestContext, w http.ResponseWriter, r *http.Request) {
+			requestCTX.SetAppResponse(requestCTX.UserClaim.GetPermissions(), http.StatusOK)
+		},
+	}
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "billing"}}
+	token, _ := ta.SignToken(&auth.UserClaim{ID: "1", Type: "user", Permissions: []string{"users:read"}})
+
+	type TestCase struct {
+		Name     string
+		TLS      *tls.ConnectionState
+		Token    string
+		WantCode int
+		WantBody string
+	}
+	tests := []TestCase{
+		{
+			Name:     "verified certificate",
+			TLS:      &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+			WantCode: http.StatusOK,
+			WantBody: "{\"success\":true,\"payload\":[\"orders:read\"]}\n",
+		},
+		{
+			Name:     "unverified certificate is ignored",
+			TLS:      &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}},
+			WantCode: http.StatusUnauthorized,
+		},
+		{
+			Name:     "certificate without common name",
+			TLS:      &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}},
+			WantCode: http.StatusUnauthorized,
+		},
+		{
+			Name:     "token takes precedence",
+			TLS:      &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+			Token:    token,
+			WantCode: http.StatusOK,
+			WantBody: "{\"success\":true,\"payload\":[\"users:read\"]}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.TLS = tt.TLS
+			if tt.Token != "" {
+				req.Header.Set("Authorization", tt.Token)
+			}
+			recorder := httptest.NewRecorder()
+			rh.ServeHTTP(recorder, req)
+			assert.Equal(t, tt.WantCode, recorder.Code)
+			if tt.WantBody != "" {
+				assert.Equal(t, tt.WantBody, recorder.Body.String())
+			}
+		})
+	}
+}
@@ -14,6 +14,7 @@ type ResponseType string
 const (
 	HTMLResp     ResponseType = "html"
 	JSONResp     ResponseType = "json"
+	RawJSONResp  ResponseType = "raw_json"
 	RedirectResp ResponseType = "redirect"
 	FileResp     ResponseType = "file"
 	ErrorResp    ResponseType = "error"
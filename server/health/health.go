@@ -0,0 +1,195 @@
+/*
+	Package health reports liveness and readiness of the server.
+
+	Dependencies of the server such as storages and kafka, as well as app services, register checks in Registry.
+	Readiness runs all the checks concurrently and fails if any of them fails or when the server is shutting down,
+	so that load balancers stop sending new requests before the listener is closed.
+*/
+
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTimeout is the time a check is given to complete
+const DefaultTimeout = 2 * time.Second
+
+// Status of the server or of a single check
+const (
+	StatusOK           = "ok"
+	StatusFailing      = "failing"
+	StatusShuttingDown = "shutting_down"
+)
+
+// ErrShuttingDown is reported by readiness once the server started graceful shutdown
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// Checker checks a dependency, nil is returned when the dependency is usable
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckFunc is an adapter to use functions as Checker, e.g. Ping method of a storage
+type CheckFunc func(ctx context.Context) error
+
+// Check calls f(ctx)
+func (f CheckFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// CheckResult contains outcome of a single check. Error may reveal internals of the dependency, so it is left out of
+// the json and only meant to be logged.
+type CheckResult struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Error     string  `json:"-"`
+	LatencyMS float64 `json:"latency_ms"`
+}
+
+// Report contains overall status along with result of every check
+type Report struct {
+	Status string         `json:"status"`
+	Checks []*CheckResult `json:"checks"`
+}
+
+// OK returns true if the server is ready to serve requests
+func (r *Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+// Err returns nil if the server is ready, otherwise an error listing the failing checks
+func (r *Report) Err() error {
+	if r.OK() {
+		return nil
+	}
+	var msgs []string
+	for _, res := range r.Checks {
+		if res.Status != StatusOK {
+			msgs = append(msgs, res.Name+": "+res.Error)
+		}
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+type namedChecker struct {
+	name    string
+	checker Checker
+}
+
+// Registry contains checks of the server dependencies
+type Registry struct {
+	// Timeout of each check, DefaultTimeout is used when not set
+	Timeout time.Duration
+
+	mu           sync.RWMutex
+	checks       []namedChecker
+	shuttingDown int32
+}
+
+// NewRegistry returns a new Registry instance
+func NewRegistry() *Registry {
+	return &Registry{Timeout: DefaultTimeout}
+}
+
+// Register adds a named check, names must be unique
+func (r *Registry) Register(name string, c Checker) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, nc := range r.checks {
+		if nc.name == name {
+			return fmt.Errorf("health: check %s is already registered", name)
+		}
+	}
+	r.checks = append(r.checks, namedChecker{name: name, checker: c})
+	return nil
+}
+
+// SetShuttingDown makes readiness fail from now on
+func (r *Registry) SetShuttingDown() {
+	atomic.StoreInt32(&r.shuttingDown, 1)
+}
+
+// ShuttingDown returns true once SetShuttingDown is called
+func (r *Registry) ShuttingDown() bool {
+	return atomic.LoadInt32(&r.shuttingDown) == 1
+}
+
+// Liveness reports the process is able to serve requests, dependencies are not checked
+// so that an unavailable database does not get the process restarted
+func (r *Registry) Liveness() *Report {
+	return &Report{Status: StatusOK, Checks: []*CheckResult{}}
+}
+
+// Readiness runs the checks concurrently and reports their results in registration order.
+// Checks are skipped once the server is shutting down as the dependencies are being closed.
+func (r *Registry) Readiness(ctx context.Context) *Report {
+	if r.ShuttingDown() {
+		return &Report{
+			Status: StatusShuttingDown,
+			Checks: []*CheckResult{{Name: "shutdown", Status: StatusFailing, Error: ErrShuttingDown.Error()}},
+		}
+	}
+
+	r.mu.RLock()
+	checks := make([]namedChecker, len(r.checks))
+	copy(checks, r.checks)
+	r.mu.RUnlock()
+
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	report := &Report{Status: StatusOK, Checks: make([]*CheckResult, len(checks))}
+	var wg sync.WaitGroup
+	for i, nc := range checks {
+		wg.Add(1)
+		go func(i int, nc namedChecker) {
+			defer wg.Done()
+			report.Checks[i] = run(ctx, nc, timeout)
+		}(i, nc)
+	}
+	wg.Wait()
+	for _, res := range report.Checks {
+		if res.Status != StatusOK {
+			report.Status = StatusFailing
+		}
+	}
+	return report
+}
+
+// run calls the check with timeout. A check which ignores its context is reported as failed once the timeout is exceeded.
+func run(ctx context.Context, nc namedChecker, timeout time.Duration) *CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	begin := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- nc.checker.Check(ctx) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// request of the check was cancelled
+		err = ctx.Err()
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	res := &CheckResult{
+		Name:      nc.name,
+		Status:    StatusOK,
+		LatencyMS: float64(time.Since(begin).Microseconds()) / 1000,
+	}
+	if err != nil {
+		res.Status = StatusFailing
+		res.Error = err.Error()
+	}
+	return res
+}
@@ -0,0 +1,97 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_Readiness(t *testing.T) {
+	ok := CheckFunc(func(ctx context.Context) error { return nil })
+	failing := CheckFunc(func(ctx context.Context) error { return errors.New("connection refused") })
+	slow := CheckFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	// ignores the context
+	hanging := CheckFunc(func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	type TestCase struct {
+		Name   string
+		Checks map[string]Checker
+		Order  []string
+		Status string
+		Errors map[string]string
+	}
+	tests := []TestCase{
+		{Name: "no checks", Status: StatusOK},
+		{
+			Name:   "all ok",
+			Checks: map[string]Checker{"mongodb": ok, "redis": ok},
+			Order:  []string{"mongodb", "redis"},
+			Status: StatusOK,
+		},
+		{
+			Name:   "failing check",
+			Checks: map[string]Checker{"mongodb": ok, "redis": failing},
+			Order:  []string{"mongodb", "redis"},
+			Status: StatusFailing,
+			Errors: map[string]string{"redis": "connection refused"},
+		},
+		{
+			Name:   "timeouts",
+			Checks: map[string]Checker{"kafka": slow, "custom": hanging, "mongodb": ok},
+			Order:  []string{"kafka", "custom", "mongodb"},
+			Status: StatusFailing,
+			Errors: map[string]string{"kafka": "timed out after 50ms", "custom": "timed out after 50ms"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			r := NewRegistry()
+			r.Timeout = 50 * time.Millisecond
+			for _, name := range tt.Order {
+				assert.Nil(t, r.Register(name, tt.Checks[name]))
+			}
+			begin := time.Now()
+			report := r.Readiness(context.Background())
+			// checks run concurrently
+			assert.True(t, time.Since(begin) < 500*time.Millisecond)
+			assert.Equal(t, tt.Status, report.Status)
+			assert.Equal(t, tt.Status == StatusOK, report.OK())
+			assert.Equal(t, tt.Status == StatusOK, report.Err() == nil)
+			assert.Len(t, report.Checks, len(tt.Order))
+			for i, res := range report.Checks {
+				assert.Equal(t, tt.Order[i], res.Name)
+				assert.Equal(t, tt.Errors[res.Name], res.Error)
+				if tt.Errors[res.Name] == "" {
+					assert.Equal(t, StatusOK, res.Status)
+				} else {
+					assert.Equal(t, StatusFailing, res.Status)
+				}
+				assert.True(t, res.LatencyMS >= 0)
+			}
+		})
+	}
+}
+
+func TestRegistry_ShuttingDown(t *testing.T) {
+	r := NewRegistry()
+	assert.Nil(t, r.Register("mongodb", CheckFunc(func(ctx context.Context) error { return nil })))
+	assert.NotNil(t, r.Register("mongodb", CheckFunc(func(ctx context.Context) error { return nil })))
+	assert.True(t, r.Readiness(context.Background()).OK())
+
+	r.SetShuttingDown()
+	report := r.Readiness(context.Background())
+	assert.False(t, report.OK())
+	assert.Equal(t, StatusShuttingDown, report.Status)
+	assert.Equal(t, "shutdown: server is shutting down", report.Err().Error())
+	// liveness does not depend on shutdown
+	assert.True(t, r.Liveness().OK())
+}
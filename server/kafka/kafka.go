@@ -5,7 +5,9 @@ import (
 )
 
 // Kafka used by server to implement Kafka.
+// Ping checks connectivity with the brokers and is used by readiness check.
 type Kafka interface {
+	Ping(context.Context) error
 	Close()
 }
 
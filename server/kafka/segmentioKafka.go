@@ -3,6 +3,8 @@ package kafka
 import (
 	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
 	"go-app/server/config"
 	"time"
 
@@ -15,7 +17,7 @@ import (
 func NewSegmentioKafkaDialer(c *config.KafkaConfig) *kafka.Dialer {
 	mechanism := plain.Mechanism{
 		Username: c.Username,
-		Password: c.Password,
+		Password: string(c.Password),
 	}
 	dialer := &kafka.Dialer{
 		Timeout:       10 * time.Second,
@@ -108,8 +110,10 @@ func (cl *SegmentioConsumer) Close() {
 
 // SegmentioProducerImpl implements `Producer` methods
 type SegmentioProducer struct {
-	Writer *kafka.Writer
-	Logger *zerolog.Logger
+	Writer  *kafka.Writer
+	Logger  *zerolog.Logger
+	Dialer  *kafka.Dialer
+	Brokers []string
 }
 
 // SegmentioProducerOpts contains args required to create a new instance of SegmentioProducerImpl
@@ -138,6 +142,8 @@ func (pl *SegmentioProducer) Init(c *config.ProducerConfig) {
 		TLS:           &tls.Config{},
 		ClientID:      c.ClientID,
 	}
+	pl.Dialer = dialer
+	pl.Brokers = c.Brokers
 	pl.Writer = kafka.NewWriter(kafka.WriterConfig{
 		Brokers: c.Brokers,
 		Topic:   c.Topic,
@@ -153,8 +159,28 @@ func (pl *SegmentioProducer) Publish(m Message) {
 	}
 }
 
+// Ping checks that the producer can connect to one of its brokers
+func (pl *SegmentioProducer) Ping(ctx context.Context) error {
+	return PingBrokers(ctx, pl.Dialer, pl.Brokers)
+}
+
 func (pl *SegmentioProducer) Close() {
 	if err := pl.Writer.Close(); err != nil {
 		pl.Logger.Err(err).Msg("failed to close producer")
 	}
 }
+
+// PingBrokers dials the brokers one by one and returns nil once a connection succeeds
+func PingBrokers(ctx context.Context, dialer *kafka.Dialer, brokers []string) error {
+	if len(brokers) == 0 {
+		return errors.New("kafka: no broker configured")
+	}
+	var err error
+	for _, broker := range brokers {
+		var conn *kafka.Conn
+		if conn, err = dialer.DialContext(ctx, "tcp", broker); err == nil {
+			return conn.Close()
+		}
+	}
+	return fmt.Errorf("kafka: failed to connect to brokers: %w", err)
+}
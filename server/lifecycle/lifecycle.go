@@ -0,0 +1,196 @@
+/*
+	Package lifecycle starts and stops the components of the server in dependency order.
+
+	A component, e.g. storage, kafka consumer, scheduler or app service, registers Start and Stop hooks along with names of
+	the components it depends on. Manager starts dependencies before the components depending on them and stops
+	components in the reverse order, so a component can use its dependencies until it is stopped.
+	Every hook runs with its own timeout and errors of all the components are returned combined.
+*/
+
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultTimeout is the time a hook is given to complete when the component does not set its own timeout
+const DefaultTimeout = 10 * time.Second
+
+// Hook starts or stops a component. The context is cancelled when the component timeout is exceeded.
+type Hook func(ctx context.Context) error
+
+// Component is a part of the server with a lifecycle. Start and Stop are optional.
+type Component struct {
+	Name      string
+	DependsOn []string
+	Start     Hook
+	Stop      Hook
+	// Timeout of each of Start and Stop hooks, DefaultTimeout is used when not set
+	Timeout time.Duration
+}
+
+// Errors combines errors of multiple components
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Manager starts and stops the registered components in dependency order
+type Manager struct {
+	Logger *zerolog.Logger
+
+	mu         sync.Mutex
+	components []*Component
+	names      map[string]bool
+	started    []*Component
+}
+
+// NewManager returns a new Manager instance
+func NewManager(l *zerolog.Logger) *Manager {
+	return &Manager{Logger: l, names: make(map[string]bool)}
+}
+
+// Register adds the component to the manager. Names must be unique, dependencies may be registered later.
+func (m *Manager) Register(c *Component) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c.Name == "" {
+		return fmt.Errorf("lifecycle: component name is required")
+	}
+	if m.names[c.Name] {
+		return fmt.Errorf("lifecycle: component %s is already registered", c.Name)
+	}
+	m.names[c.Name] = true
+	m.components = append(m.components, c)
+	return nil
+}
+
+// Start runs Start hooks of the components, dependencies first. Components with independent dependencies start in
+// registration order. If a component fails to start the already started ones are stopped and errors are returned.
+func (m *Manager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	order, err := m.order()
+	if err != nil {
+		return err
+	}
+	for _, c := range order {
+		if err := m.run(ctx, c, "start", c.Start); err != nil {
+			errs := Errors{err}
+			if stopErr := m.stop(ctx); stopErr != nil {
+				errs = append(errs, stopErr.(Errors)...)
+			}
+			return errs
+		}
+		m.started = append(m.started, c)
+	}
+	return nil
+}
+
+// Stop runs Stop hooks of the started components in the reverse order of start.
+// Every component is stopped even if stopping another one failed, the errors are returned combined.
+func (m *Manager) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stop(ctx)
+}
+
+func (m *Manager) stop(ctx context.Context) error {
+	var errs Errors
+	for i := len(m.started) - 1; i >= 0; i-- {
+		c := m.started[i]
+		if err := m.run(ctx, c, "stop", c.Stop); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	m.started = nil
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// run calls the hook with the component timeout. A hook which ignores its context is abandoned once the timeout is exceeded.
+func (m *Manager) run(ctx context.Context, c *Component, action string, h Hook) error {
+	if h == nil {
+		return nil
+	}
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	begin := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- h(ctx) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	if err != nil {
+		err = fmt.Errorf("lifecycle: failed to %s %s: %w", action, c.Name, err)
+		m.Logger.Error().Err(err).Str("component", c.Name).Msg("")
+		return err
+	}
+	m.Logger.Debug().Str("component", c.Name).Dur("took", time.Since(begin)).Msgf("component %s", actionDone[action])
+	return nil
+}
+
+var actionDone = map[string]string{"start": "started", "stop": "stopped"}
+
+// order returns the components sorted so that every component comes after its dependencies
+func (m *Manager) order() ([]*Component, error) {
+	byName := make(map[string]*Component, len(m.components))
+	for _, c := range m.components {
+		byName[c.Name] = c
+	}
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(m.components))
+	order := make([]*Component, 0, len(m.components))
+	var visit func(c *Component, path []string) error
+	visit = func(c *Component, path []string) error {
+		switch state[c.Name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("lifecycle: dependency cycle %s", strings.Join(append(path, c.Name), " -> "))
+		}
+		state[c.Name] = visiting
+		for _, name := range c.DependsOn {
+			dep, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("lifecycle: component %s depends on unknown component %s", c.Name, name)
+			}
+			if err := visit(dep, append(path, c.Name)); err != nil {
+				return err
+			}
+		}
+		state[c.Name] = visited
+		order = append(order, c)
+		return nil
+	}
+	for _, c := range m.components {
+		if err := visit(c, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,146 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder records the order of the hook calls
+type recorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *recorder) hook(call string, err error) Hook {
+	return func(ctx context.Context) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.calls = append(r.calls, call)
+		return err
+	}
+}
+
+func (r *recorder) component(name string, deps ...string) *Component {
+	return &Component{Name: name, DependsOn: deps, Start: r.hook("start "+name, nil), Stop: r.hook("stop "+name, nil)}
+}
+
+func newTestManager() *Manager {
+	l := zerolog.Nop()
+	return NewManager(&l)
+}
+
+func TestManager_StartStop(t *testing.T) {
+	r := &recorder{}
+	m := newTestManager()
+	// registered before its dependencies
+	assert.Nil(t, m.Register(r.component("http", "app", "redis")))
+	assert.Nil(t, m.Register(r.component("app", "mongodb")))
+	assert.Nil(t, m.Register(r.component("mongodb", "logger")))
+	assert.Nil(t, m.Register(r.component("redis", "logger")))
+	assert.Nil(t, m.Register(r.component("logger")))
+	assert.Nil(t, m.Register(&Component{Name: "no hooks"}))
+
+	assert.Nil(t, m.Start(context.Background()))
+	assert.Equal(t, []string{"start logger", "start mongodb", "start app", "start redis", "start http"}, r.calls)
+
+	r.calls = nil
+	assert.Nil(t, m.Stop(context.Background()))
+	assert.Equal(t, []string{"stop http", "stop redis", "stop app", "stop mongodb", "stop logger"}, r.calls)
+
+	// stopped components are not stopped again
+	r.calls = nil
+	assert.Nil(t, m.Stop(context.Background()))
+	assert.Nil(t, r.calls)
+}
+
+func TestManager_Register(t *testing.T) {
+	m := newTestManager()
+	assert.Nil(t, m.Register(&Component{Name: "redis"}))
+	assert.NotNil(t, m.Register(&Component{Name: "redis"}))
+	assert.NotNil(t, m.Register(&Component{}))
+}
+
+func TestManager_StartInvalidDependencies(t *testing.T) {
+	type TestCase struct {
+		Name       string
+		Components []*Component
+		Err        string
+	}
+	tests := []TestCase{
+		{
+			Name:       "unknown dependency",
+			Components: []*Component{{Name: "app", DependsOn: []string{"mongo"}}},
+			Err:        "lifecycle: component app depends on unknown component mongo",
+		},
+		{
+			Name: "cycle",
+			Components: []*Component{
+				{Name: "a", DependsOn: []string{"b"}},
+				{Name: "b", DependsOn: []string{"c"}},
+				{Name: "c", DependsOn: []string{"a"}},
+			},
+			Err: "lifecycle: dependency cycle a -> b -> c -> a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			m := newTestManager()
+			for _, c := range tt.Components {
+				assert.Nil(t, m.Register(c))
+			}
+			err := m.Start(context.Background())
+			assert.NotNil(t, err)
+			assert.Equal(t, tt.Err, err.Error())
+		})
+	}
+}
+
+func TestManager_StartFailure(t *testing.T) {
+	r := &recorder{}
+	m := newTestManager()
+	assert.Nil(t, m.Register(r.component("logger")))
+	assert.Nil(t, m.Register(r.component("mongodb", "logger")))
+	assert.Nil(t, m.Register(&Component{Name: "app", DependsOn: []string{"mongodb"}, Start: r.hook("start app", errors.New("index failed"))}))
+	assert.Nil(t, m.Register(r.component("http", "app")))
+
+	err := m.Start(context.Background())
+	assert.NotNil(t, err)
+	assert.Equal(t, "lifecycle: failed to start app: index failed", err.Error())
+	// already started components are stopped, the rest are not started
+	assert.Equal(t, []string{"start logger", "start mongodb", "start app", "stop mongodb", "stop logger"}, r.calls)
+}
+
+func TestManager_StopErrors(t *testing.T) {
+	r := &recorder{}
+	m := newTestManager()
+	assert.Nil(t, m.Register(r.component("logger")))
+	assert.Nil(t, m.Register(&Component{Name: "kafka", DependsOn: []string{"logger"}, Stop: r.hook("stop kafka", errors.New("flush failed"))}))
+	assert.Nil(t, m.Register(&Component{
+		Name:      "http",
+		DependsOn: []string{"kafka"},
+		Timeout:   50 * time.Millisecond,
+		// ignores the context and hangs
+		Stop: func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		},
+	}))
+	assert.Nil(t, m.Start(context.Background()))
+
+	r.calls = nil
+	begin := time.Now()
+	err := m.Stop(context.Background())
+	assert.True(t, time.Since(begin) < time.Second)
+	errs, ok := err.(Errors)
+	assert.True(t, ok)
+	assert.Len(t, errs, 2)
+	assert.Equal(t, "lifecycle: failed to stop http: timed out after 50ms; lifecycle: failed to stop kafka: flush failed", err.Error())
+	// components after the failed ones are still stopped
+	assert.Equal(t, []string{"stop kafka", "stop logger"}, r.calls)
+}
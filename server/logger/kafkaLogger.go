@@ -3,6 +3,7 @@ package logger
 import (
 	"context"
 	"go-app/server/config"
+	goKafka "go-app/server/kafka"
 	"io"
 	"log"
 
@@ -14,6 +15,8 @@ import (
 // KafkaLogWriter extends the existing kafka.Writer functionality by implementing io.Writer`s Write method.
 type KafkaLogWriter struct {
 	*kafka.Writer
+	dialer  *kafka.Dialer
+	brokers []string
 }
 
 // NewKafkaLogWriter returns new instance of KafkaLogWriter
@@ -26,10 +29,17 @@ func NewKafkaLogWriter(topic string, dialer *kafka.Dialer, c *config.KafkaConfig
 		Dialer:   dialer,
 	})
 	return &KafkaLogWriter{
-		kw,
+		Writer:  kw,
+		dialer:  dialer,
+		brokers: c.Brokers,
 	}
 }
 
+// Ping checks that logs can be delivered to one of the brokers
+func (kw *KafkaLogWriter) Ping(ctx context.Context) error {
+	return goKafka.PingBrokers(ctx, kw.dialer, kw.brokers)
+}
+
 // Write method implements io.Writer`s Write method.
 func (kw *KafkaLogWriter) Write(p []byte) (n int, err error) {
 	m := kafka.Message{
@@ -3,6 +3,7 @@ package logger
 import (
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -12,7 +13,15 @@ import (
 
 // NewLogger returns logger based on server config
 func NewLogger(kw *KafkaLogWriter, cw, fw io.Writer) *zerolog.Logger {
+	l, _ := NewBufferedLogger(kw, cw, fw)
+	return l
+}
+
+// NewBufferedLogger returns logger based on server config along with the closer of its buffered writers.
+// Kafka and file logs are written asynchronously, closing flushes the pending messages and closes the underlying writers.
+func NewBufferedLogger(kw *KafkaLogWriter, cw, fw io.Writer) (*zerolog.Logger, io.Closer) {
 	var writers []io.Writer
+	var closers multiCloser
 
 	// Setting up kafka writer if True.
 	if kw != nil {
@@ -20,6 +29,7 @@ func NewLogger(kw *KafkaLogWriter, cw, fw io.Writer) *zerolog.Logger {
 			fmt.Printf("Logger Dropped %d messages", missed)
 		})
 		writers = append(writers, wr)
+		closers = append(closers, wr)
 	}
 
 	// Setting up console writer if True.
@@ -33,11 +43,41 @@ func NewLogger(kw *KafkaLogWriter, cw, fw io.Writer) *zerolog.Logger {
 			fmt.Printf("Logger Dropped %d messages", missed)
 		})
 		writers = append(writers, wr)
+		closers = append(closers, wr)
 	}
 
 	mw := io.MultiWriter(writers...)
 	zlog := zerolog.New(mw).With().Timestamp().Stack().Caller().Logger()
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 	zerolog.ErrorStackMarshaler = pkgerrors.MarshalStack
-	return &zlog
+	return &zlog, closers
+}
+
+// SetLevel sets the minimum level of messages written by all the loggers, debug is used when level is empty
+func SetLevel(level string) error {
+	if level == "" {
+		level = zerolog.DebugLevel.String()
+	}
+	l, err := zerolog.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	zerolog.SetGlobalLevel(l)
+	return nil
+}
+
+// multiCloser closes all the writers and returns their errors combined
+type multiCloser []io.Closer
+
+func (mc multiCloser) Close() error {
+	var msgs []string
+	for _, c := range mc {
+		if err := c.Close(); err != nil {
+			msgs = append(msgs, err.Error())
+		}
+	}
+	if len(msgs) > 0 {
+		return fmt.Errorf("failed to close log writers: %s", strings.Join(msgs, "; "))
+	}
+	return nil
 }
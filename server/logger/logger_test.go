@@ -26,3 +26,31 @@ func TestGenerateMultipleConsoleLog(t *testing.T) {
 		})
 	}
 }
+
+// closeBuffer records writes and whether it was closed
+type closeBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *closeBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestNewBufferedLogger_Close(t *testing.T) {
+	fw := &closeBuffer{}
+	log, closer := NewBufferedLogger(nil, nil, fw)
+	for i := 0; i < 100; i++ {
+		log.Log().Msg(fmt.Sprintf("log :%d", i))
+	}
+	if err := closer.Close(); err != nil {
+		t.Fatalf("failed to close logger: %s", err)
+	}
+	if got := bytes.Count(fw.Bytes(), []byte("\n")); got != 100 {
+		t.Errorf("expected 100 flushed messages, got %d", got)
+	}
+	if !fw.closed {
+		t.Error("file writer is not closed")
+	}
+}
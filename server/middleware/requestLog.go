@@ -3,6 +3,7 @@ package middleware
 import (
 	"context"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/felixge/httpsnoop"
@@ -20,6 +21,8 @@ const requestIDKey key = 0
 // RequestLoggerMiddleware containing logger to log request
 type RequestLoggerMiddleware struct {
 	Logger *zerolog.Logger
+
+	disabled int32
 }
 
 // NewRequestLoggerMiddleware returns new request logger
@@ -30,6 +33,15 @@ func NewRequestLoggerMiddleware(logger *zerolog.Logger) *RequestLoggerMiddleware
 	return &loggerMiddleware
 }
 
+// SetEnabled turns request logging on or off while the server is running, requests are logged by default
+func (lm *RequestLoggerMiddleware) SetEnabled(enabled bool) {
+	var disabled int32
+	if !enabled {
+		disabled = 1
+	}
+	atomic.StoreInt32(&lm.disabled, disabled)
+}
+
 func newContextWithRequestID(ctx context.Context, req *http.Request) context.Context {
 	reqID := req.Header.Get(HeaderRequestID)
 	if reqID == "" {
@@ -50,6 +62,10 @@ func RequestIDFromContext(ctx context.Context) string {
 // GetMiddlewareHandler function returns middleware used to log requests
 func (lm *RequestLoggerMiddleware) GetMiddlewareHandler() func(http.ResponseWriter, *http.Request, http.HandlerFunc) {
 	return func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		if atomic.LoadInt32(&lm.disabled) == 1 {
+			next(rw, r)
+			return
+		}
 		ctx := newContextWithRequestID(r.Context(), r)
 		metrics := httpsnoop.CaptureMetrics(next, rw, r.WithContext(ctx))
 		requestID := rw.Header().Get(HeaderRequestID)
@@ -0,0 +1,248 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"go-app/server/auth"
+	"go-app/server/config"
+	"go-app/server/storage"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	errors "github.com/vasupal1996/goerror"
+)
+
+// headers carrying the signature of a signed request
+const (
+	HeaderSignatureKeyID     = "X-Signature-Key-Id"
+	HeaderSignatureTimestamp = "X-Signature-Timestamp"
+	HeaderSignatureNonce     = "X-Signature-Nonce"
+	HeaderSignature          = "X-Signature"
+)
+
+// DefaultMaxClockSkew is used when RequestSigningConfig.MaxClockSkew is not set
+const DefaultMaxClockSkew = 5 * time.Minute
+
+// maxSignedBodySize limits the body read into memory to compute its digest
+const maxSignedBodySize = 10 << 20
+
+const requestNonceKeyPrefix = "request_nonce:"
+
+var (
+	// ErrMissingSignature is returned when one of the signature headers is missing
+	ErrMissingSignature = errors.New("missing request signature", &errors.Unauthorized)
+	// ErrUnknownSigningKey is returned when the key id of the request is not configured
+	ErrUnknownSigningKey = errors.New("unknown signing key", &errors.Unauthorized)
+	// ErrInvalidSignature is returned when signature does not match the request
+	ErrInvalidSignature = errors.New("invalid request signature", &errors.Unauthorized)
+	// ErrSignatureExpired is returned when request timestamp is outside of the allowed clock skew
+	ErrSignatureExpired = errors.New("request signature expired", &errors.Unauthorized)
+	// ErrReplayedRequest is returned when nonce of the request was already used
+	ErrReplayedRequest = errors.New("request already processed", &errors.Unauthorized)
+)
+
+// RequestSigningClient is a service allowed to send signed requests
+type RequestSigningClient struct {
+	ID     string
+	Secret []byte
+	Scopes []string
+}
+
+// RequestSigningMiddleware verifies hmac signed requests.
+// Requests without signature pass through untouched so that they can be authenticated by other means,
+// requests with a valid signature carry an auth.ServiceClaim in their context.
+type RequestSigningMiddleware struct {
+	Clients      map[string]*RequestSigningClient
+	Nonces       storage.Redis
+	MaxClockSkew time.Duration
+	now          func() time.Time
+}
+
+// NewRequestSigningMiddleware returns new request signing middleware which stores nonces in redis or memory storage
+func NewRequestSigningMiddleware(s storage.Redis, c *config.RequestSigningConfig) *RequestSigningMiddleware {
+	sm := &RequestSigningMiddleware{
+		Clients:      make(map[string]*RequestSigningClient),
+		Nonces:       s,
+		MaxClockSkew: time.Duration(c.MaxClockSkew) * time.Second,
+		now:          time.Now,
+	}
+	if sm.MaxClockSkew == 0 {
+		sm.MaxClockSkew = DefaultMaxClockSkew
+	}
+	for _, cc := range c.Clients {
+		sm.Clients[cc.ID] = &RequestSigningClient{ID: cc.ID, Secret: []byte(cc.Secret), Scopes: cc.Scopes}
+	}
+	return sm
+}
+
+// Verify checks signature, timestamp and nonce of the request and returns the client which signed it.
+// The request body is restored so that it can be read again by the handler.
+func (sm *RequestSigningMiddleware) Verify(r *http.Request) (*RequestSigningClient, error) {
+	keyID := r.Header.Get(HeaderSignatureKeyID)
+	timestamp := r.Header.Get(HeaderSignatureTimestamp)
+	nonce := r.Header.Get(HeaderSignatureNonce)
+	signature := r.Header.Get(HeaderSignature)
+	if keyID == "" || timestamp == "" || nonce == "" || signature == "" {
+		return nil, ErrMissingSignature
+	}
+	client, ok := sm.Clients[keyID]
+	if !ok {
+		return nil, ErrUnknownSigningKey
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	signedAt := time.Unix(ts, 0)
+	if math.Abs(float64(sm.now().Sub(signedAt))) > float64(sm.MaxClockSkew) {
+		return nil, ErrSignatureExpired
+	}
+
+	digest, err := digestBody(r)
+	if err != nil {
+		return nil, err
+	}
+	expected := signRequest(client.Secret, r, timestamp, nonce, digest)
+	got, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, got) {
+		return nil, ErrInvalidSignature
+	}
+
+	// nonce is checked only after signature so that unauthenticated callers can not fill up the storage.
+	// It is stored only if absent in a single step so a request sent to many instances at once is accepted only once.
+	// Requests older than the clock skew are rejected by timestamp, the nonce is not needed after that.
+	nonceKey := requestNonceKeyPrefix + keyID + ":" + nonce
+	stored, err := sm.Nonces.CommitIfAbsent(nonceKey, []byte{1}, signedAt.Add(sm.MaxClockSkew))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to store request nonce", &errors.SomethingWentWrong)
+	}
+	if !stored {
+		return nil, ErrReplayedRequest
+	}
+	return client, nil
+}
+
+// GetMiddlewareHandler function returns middleware used to verify signed requests
+func (sm *RequestSigningMiddleware) GetMiddlewareHandler() func(http.ResponseWriter, *http.Request, http.HandlerFunc) {
+	return func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		if r.Header.Get(HeaderSignature) == "" {
+			next(rw, r)
+			return
+		}
+		client, err := sm.Verify(r)
+		if err != nil {
+			writeError(rw, r, err, http.StatusUnauthorized)
+			return
+		}
+		claim := &auth.ServiceClaim{ID: client.ID, Scopes: client.Scopes}
+		next(rw, r.WithContext(auth.NewContextWithClaim(r.Context(), claim)))
+	}
+}
+
+// RequestSigner signs requests sent to a server using RequestSigningMiddleware
+type RequestSigner struct {
+	KeyID  string
+	Secret []byte
+	now    func() time.Time
+}
+
+// NewRequestSigner returns a new RequestSigner for the key id and shared secret
+func NewRequestSigner(keyID, secret string) *RequestSigner {
+	return &RequestSigner{KeyID: keyID, Secret: []byte(secret), now: time.Now}
+}
+
+// Sign adds signature headers to the request. The request body is restored after computing its digest.
+func (s *RequestSigner) Sign(r *http.Request) error {
+	digest, err := digestBody(r)
+	if err != nil {
+		return err
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	nonce := hex.EncodeToString(b)
+	r.Header.Set(HeaderSignatureKeyID, s.KeyID)
+	r.Header.Set(HeaderSignatureTimestamp, timestamp)
+	r.Header.Set(HeaderSignatureNonce, nonce)
+	r.Header.Set(HeaderSignature, hex.EncodeToString(signRequest(s.Secret, r, timestamp, nonce, digest)))
+	return nil
+}
+
+// Transport returns http.RoundTripper signing every request before passing it to base (http.DefaultTransport if nil)
+func (s *RequestSigner) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &signingTransport{signer: s, base: base}
+}
+
+type signingTransport struct {
+	signer *RequestSigner
+	base   http.RoundTripper
+}
+
+// RoundTrip signs a copy of the request as RoundTripper must not modify the request it was given
+func (t *signingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	signed := r.Clone(r.Context())
+	if err := t.signer.Sign(signed); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(signed)
+}
+
+// signRequest returns hmac-sha256 of method, request uri, timestamp, nonce and body digest separated by new lines
+func signRequest(secret []byte, r *http.Request, timestamp, nonce, digest string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strings.Join([]string{r.Method, r.URL.RequestURI(), timestamp, nonce, digest}, "\n")))
+	return mac.Sum(nil)
+}
+
+// digestBody returns hex encoded sha256 of the request body and replaces the body with an unread copy
+func digestBody(r *http.Request) (string, error) {
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		var err error
+		body, err = ioutil.ReadAll(io.LimitReader(r.Body, maxSignedBodySize+1))
+		r.Body.Close()
+		if err != nil {
+			return "", errors.Wrap(err, "failed to read request body", &errors.BadRequest)
+		}
+		if len(body) > maxSignedBodySize {
+			return "", errors.New("request body too large to be signed", &errors.BadRequest)
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		r.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	h := sha256.Sum256(body)
+	return hex.EncodeToString(h[:]), nil
+}
+
+// writeError writes err in the same format as handler.Request error responses
+func writeError(rw http.ResponseWriter, r *http.Request, err error, statusCode int) {
+	requestID := RequestIDFromContext(r.Context())
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(statusCode)
+	json.NewEncoder(rw).Encode(&struct {
+		Error     []map[string]interface{} `json:"error"`
+		Success   bool                     `json:"success"`
+		RequestID *string                  `json:"request_id"`
+	}{
+		Error:     []map[string]interface{}{errors.Map(err)},
+		Success:   false,
+		RequestID: &requestID,
+	})
+}
@@ -0,0 +1,200 @@
+package middleware
+
+import (
+	"go-app/server/auth"
+	"go-app/server/config"
+	memorystorage "go-app/server/storage/memory"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRequestSigningMiddleware() *RequestSigningMiddleware {
+	return NewRequestSigningMiddleware(memorystorage.NewMemoryStorageWithCleanupInterval(0), &config.RequestSigningConfig{
+		EnableRequestSigning: true,
+		Clients: []config.RequestSigningClientConfig{
+			{ID: "billing", Secret: "billing-secret", Scopes: []string{"orders:read"}},
+		},
+	})
+}
+
+// echoHandler responds with id of the service claim and the request body
+func echoHandler(w http.ResponseWriter, r *http.Request) {
+	id := ""
+	if c, ok := auth.ClaimFromContext(r.Context()).(*auth.ServiceClaim); ok {
+		id = c.ID
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	w.Write([]byte(id + ":" + string(body)))
+}
+
+func TestRequestSigningMiddleware(t *testing.T) {
+	signed := func(secret string, now time.Time) func() *http.Request {
+		return func() *http.Request {
+			r := httptest.NewRequest(http.MethodPost, "/api/orders?page=1", strings.NewReader(`{"id":1}`))
+			s := NewRequestSigner("billing", secret)
+			s.now = func() time.Time { return now }
+			s.Sign(r)
+			return r
+		}
+	}
+	replayed := signed("billing-secret", time.Now())()
+
+	tests := []struct {
+		name     string
+		request  func() *http.Request
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Unsigned Request",
+			request:  func() *http.Request { return httptest.NewRequest(http.MethodGet, "/", nil) },
+			wantCode: http.StatusOK,
+			wantBody: ":",
+		},
+		{
+			name:     "Valid Signature",
+			request:  signed("billing-secret", time.Now()),
+			wantCode: http.StatusOK,
+			wantBody: `billing:{"id":1}`,
+		},
+		{
+			name:     "Wrong Secret",
+			request:  signed("other-secret", time.Now()),
+			wantCode: http.StatusUnauthorized,
+			wantBody: "invalid request signature",
+		},
+		{
+			name: "Tampered Body",
+			request: func() *http.Request {
+				r := signed("billing-secret", time.Now())()
+				r.Body = ioutil.NopCloser(strings.NewReader(`{"id":2}`))
+				return r
+			},
+			wantCode: http.StatusUnauthorized,
+			wantBody: "invalid request signature",
+		},
+		{
+			name: "Tampered Query",
+			request: func() *http.Request {
+				r := signed("billing-secret", time.Now())()
+				r.URL.RawQuery = "page=2"
+				return r
+			},
+			wantCode: http.StatusUnauthorized,
+			wantBody: "invalid request signature",
+		},
+		{
+			name: "Unknown Key",
+			request: func() *http.Request {
+				r := signed("billing-secret", time.Now())()
+				r.Header.Set(HeaderSignatureKeyID, "shipping")
+				return r
+			},
+			wantCode: http.StatusUnauthorized,
+			wantBody: "unknown signing key",
+		},
+		{
+			name: "Missing Nonce",
+			request: func() *http.Request {
+				r := signed("billing-secret", time.Now())()
+				r.Header.Del(HeaderSignatureNonce)
+				return r
+			},
+			wantCode: http.StatusUnauthorized,
+			wantBody: "missing request signature",
+		},
+		{
+			name:     "Old Timestamp",
+			request:  signed("billing-secret", time.Now().Add(-10*time.Minute)),
+			wantCode: http.StatusUnauthorized,
+			wantBody: "request signature expired",
+		},
+		{
+			name:     "Future Timestamp",
+			request:  signed("billing-secret", time.Now().Add(10*time.Minute)),
+			wantCode: http.StatusUnauthorized,
+			wantBody: "request signature expired",
+		},
+		{
+			name: "First Use Of Nonce",
+			request: func() *http.Request {
+				return replayed.Clone(replayed.Context())
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "Replayed Nonce",
+			request: func() *http.Request {
+				r := replayed.Clone(replayed.Context())
+				r.Body, _ = replayed.GetBody()
+				return r
+			},
+			wantCode: http.StatusUnauthorized,
+			wantBody: "request already processed",
+		},
+	}
+	sm := newTestRequestSigningMiddleware()
+	h := sm.GetMiddlewareHandler()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			h(recorder, tt.request(), echoHandler)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+			assert.Contains(t, recorder.Body.String(), tt.wantBody)
+		})
+	}
+}
+
+func TestRequestSigningMiddleware_ConcurrentReplay(t *testing.T) {
+	// middlewares sharing the storage stand for instances of the service
+	ms := memorystorage.NewMemoryStorageWithCleanupInterval(0)
+	c := &config.RequestSigningConfig{Clients: []config.RequestSigningClientConfig{{ID: "billing", Secret: "billing-secret"}}}
+	instances := []*RequestSigningMiddleware{NewRequestSigningMiddleware(ms, c), NewRequestSigningMiddleware(ms, c)}
+	signed := httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader(`{"id":1}`))
+	NewRequestSigner("billing", "billing-secret").Sign(signed)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	accepted := 0
+	for i := 0; i < 10; i++ {
+		r := signed.Clone(signed.Context())
+		r.Body, _ = signed.GetBody()
+		wg.Add(1)
+		go func(sm *RequestSigningMiddleware, r *http.Request) {
+			defer wg.Done()
+			if _, err := sm.Verify(r); err == nil {
+				mu.Lock()
+				accepted++
+				mu.Unlock()
+			}
+		}(instances[i%2], r)
+	}
+	wg.Wait()
+	assert.Equal(t, 1, accepted, "a signed request is accepted only once")
+}
+
+func TestRequestSigner_Transport(t *testing.T) {
+	sm := newTestRequestSigningMiddleware()
+	h := sm.GetMiddlewareHandler()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h(w, r, echoHandler)
+	}))
+	defer ts.Close()
+
+	client := &http.Client{Transport: NewRequestSigner("billing", "billing-secret").Transport(nil)}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Post(ts.URL+"/api/orders", "application/json", strings.NewReader(`{"id":1}`))
+		assert.Nil(t, err)
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `billing:{"id":1}`, string(body))
+	}
+}
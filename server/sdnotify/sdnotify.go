@@ -0,0 +1,132 @@
+/*
+	Package sdnotify implements the systemd notification protocol (sd_notify).
+
+	State changes are sent as datagrams to the unix socket systemd passes in NOTIFY_SOCKET. When the unit sets WatchdogSec
+	systemd passes WATCHDOG_USEC and restarts the service if it is not pinged within that interval, Watchdog pings at
+	half of the interval but only while the service reports itself healthy, so a process which is running but unable to
+	serve requests gets restarted as well.
+*/
+
+package sdnotify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Notification states understood by systemd
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// Notifier sends notifications to the systemd socket
+type Notifier struct {
+	Socket string
+}
+
+// NewNotifier returns Notifier for the socket in NOTIFY_SOCKET, nil is returned when the process is not started by systemd
+// with notify support
+func NewNotifier() *Notifier {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	return &Notifier{Socket: socket}
+}
+
+// Notify sends the newline separated states in a single datagram.
+// Sockets in the abstract namespace are prefixed with @ which is handled by net package.
+func (n *Notifier) Notify(states ...string) error {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: n.Socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("sdnotify: failed to connect to %s: %w", n.Socket, err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(strings.Join(states, "\n"))); err != nil {
+		return fmt.Errorf("sdnotify: failed to send notification: %w", err)
+	}
+	return nil
+}
+
+// Ready notifies that the service started, status is sent along if it is not empty
+func (n *Notifier) Ready(status string) error {
+	return n.Notify(withStatus(Ready, status)...)
+}
+
+// Stopping notifies that the service is shutting down, status is sent along if it is not empty
+func (n *Notifier) Stopping(status string) error {
+	return n.Notify(withStatus(Stopping, status)...)
+}
+
+// Status sends free form status of the service which is shown by systemctl status
+func (n *Notifier) Status(status string) error {
+	return n.Notify("STATUS=" + status)
+}
+
+func withStatus(state, status string) []string {
+	if status == "" {
+		return []string{state}
+	}
+	return []string{state, "STATUS=" + status}
+}
+
+// WatchdogInterval returns the watchdog timeout systemd expects pings within.
+// Zero is returned when the watchdog is not enabled or it is enabled for another process.
+func WatchdogInterval() (time.Duration, error) {
+	usec := os.Getenv("WATCHDOG_USEC")
+	if usec == "" {
+		return 0, nil
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("sdnotify: invalid WATCHDOG_USEC %q", usec)
+	}
+	return time.Duration(n) * time.Microsecond, nil
+}
+
+// Watchdog pings systemd at half of interval until ctx is cancelled. Pings are skipped while healthy returns an error,
+// the error is sent as status once so that the reason of a restart is visible in systemctl status.
+// Errors of sending the notifications are passed to onErr.
+func (n *Notifier) Watchdog(ctx context.Context, interval time.Duration, healthy func(context.Context) error, onErr func(error)) {
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	failing := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// a check must not take longer than the time left until the next ping
+		checkCtx, cancel := context.WithTimeout(ctx, interval/2)
+		err := healthy(checkCtx)
+		cancel()
+		var states []string
+		switch {
+		case err == nil && failing:
+			failing = false
+			states = []string{Watchdog, "STATUS=healthy"}
+		case err == nil:
+			states = []string{Watchdog}
+		case !failing:
+			failing = true
+			states = []string{"STATUS=unhealthy: " + err.Error()}
+		}
+		if len(states) == 0 {
+			continue
+		}
+		if err := n.Notify(states...); err != nil {
+			onErr(err)
+		}
+	}
+}
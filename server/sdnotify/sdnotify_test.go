@@ -0,0 +1,146 @@
+package sdnotify
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestSocket listens on a unixgram socket in a temporary directory and returns it along with the notifier sending to it
+func newTestSocket(t *testing.T) (*net.UnixConn, *Notifier, func()) {
+	dir, _ := ioutil.TempDir("", "sdnotify")
+	socket := filepath.Join(dir, "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	return conn, &Notifier{Socket: socket}, func() {
+		conn.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// receive returns the next datagram or an empty string if none is received within timeout
+func receive(conn *net.UnixConn, timeout time.Duration) string {
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	n, err := conn.Read(buf)
+	if err != nil {
+		return ""
+	}
+	return string(buf[:n])
+}
+
+func TestNotifier_Notify(t *testing.T) {
+	conn, n, cleanup := newTestSocket(t)
+	defer cleanup()
+
+	type TestCase struct {
+		Name   string
+		Notify func() error
+		Want   string
+	}
+	tests := []TestCase{
+		{Name: "ready", Notify: func() error { return n.Ready("") }, Want: "READY=1"},
+		{Name: "ready with status", Notify: func() error { return n.Ready("serving on :8000") }, Want: "READY=1\nSTATUS=serving on :8000"},
+		{Name: "status", Notify: func() error { return n.Status("draining requests") }, Want: "STATUS=draining requests"},
+		{Name: "stopping", Notify: func() error { return n.Stopping("shutting down") }, Want: "STOPPING=1\nSTATUS=shutting down"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Nil(t, tt.Notify())
+			assert.Equal(t, tt.Want, receive(conn, time.Second))
+		})
+	}
+
+	// nothing listens on the socket
+	n.Socket = n.Socket + ".missing"
+	assert.NotNil(t, n.Ready(""))
+}
+
+func TestNewNotifier(t *testing.T) {
+	defer os.Setenv("NOTIFY_SOCKET", os.Getenv("NOTIFY_SOCKET"))
+	os.Setenv("NOTIFY_SOCKET", "")
+	assert.Nil(t, NewNotifier())
+	os.Setenv("NOTIFY_SOCKET", "@go-app")
+	assert.Equal(t, &Notifier{Socket: "@go-app"}, NewNotifier())
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	defer os.Setenv("WATCHDOG_USEC", os.Getenv("WATCHDOG_USEC"))
+	defer os.Setenv("WATCHDOG_PID", os.Getenv("WATCHDOG_PID"))
+	pid := strconv.Itoa(os.Getpid())
+
+	type TestCase struct {
+		Name     string
+		USec     string
+		PID      string
+		Interval time.Duration
+		WantErr  bool
+	}
+	tests := []TestCase{
+		{Name: "disabled"},
+		{Name: "enabled", USec: "30000000", Interval: 30 * time.Second},
+		{Name: "enabled for this process", USec: "30000000", PID: pid, Interval: 30 * time.Second},
+		{Name: "enabled for another process", USec: "30000000", PID: "1"},
+		{Name: "invalid", USec: "30s", WantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			os.Setenv("WATCHDOG_USEC", tt.USec)
+			os.Setenv("WATCHDOG_PID", tt.PID)
+			interval, err := WatchdogInterval()
+			if tt.WantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.Interval, interval)
+		})
+	}
+}
+
+func TestNotifier_Watchdog(t *testing.T) {
+	conn, n, cleanup := newTestSocket(t)
+	defer cleanup()
+
+	var unhealthy int32
+	healthy := func(ctx context.Context) error {
+		if atomic.LoadInt32(&unhealthy) == 1 {
+			return errors.New("mongodb: connection refused")
+		}
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		n.Watchdog(ctx, 100*time.Millisecond, healthy, func(err error) { t.Error(err) })
+		close(done)
+	}()
+
+	// pinged at half of the interval
+	begin := time.Now()
+	assert.Equal(t, "WATCHDOG=1", receive(conn, time.Second))
+	assert.Equal(t, "WATCHDOG=1", receive(conn, time.Second))
+	assert.InDelta(t, 100*time.Millisecond, time.Since(begin), float64(40*time.Millisecond))
+
+	// pings stop while unhealthy and the reason is reported once
+	atomic.StoreInt32(&unhealthy, 1)
+	assert.Equal(t, "STATUS=unhealthy: mongodb: connection refused", receive(conn, time.Second))
+	assert.Equal(t, "", receive(conn, 200*time.Millisecond))
+
+	atomic.StoreInt32(&unhealthy, 0)
+	assert.Equal(t, "WATCHDOG=1\nSTATUS=healthy", receive(conn, time.Second))
+
+	cancel()
+	<-done
+}
@@ -4,7 +4,8 @@
 	the execution.
 
 	The server itself listing on some address and port (localhost:8000 (default)) via go routine and will be blocked until
-	StopServer function is called via some function or command line. Before stoping server all the resources and connections are closed.
+	StopServer function is called via some function or command line. Before stoping server in-flight requests are drained
+	and all the resources and connections are closed.
 */
 
 package server
@@ -16,17 +17,24 @@ import (
 	"go-app/app"
 	"go-app/server/auth"
 	"go-app/server/config"
+	"go-app/server/health"
 	goKafka "go-app/server/kafka"
+	"go-app/server/lifecycle"
 	"go-app/server/logger"
 	"go-app/server/middleware"
+	"go-app/server/sdnotify"
+	"go-app/server/socket"
 	"go-app/server/storage"
 	memorystorage "go-app/server/storage/memory"
 	mongostorage "go-app/server/storage/mongodb"
 	redisstorage "go-app/server/storage/redis"
+	"go-app/server/tlsconfig"
 	"go-app/server/validator"
 	"io"
+	"net"
 	"net/http"
 	"os"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -43,14 +51,44 @@ type Server struct {
 	Kafka      goKafka.Kafka
 	MongoDB    storage.DB
 	Redis      storage.Redis
+	Certs      *tlsconfig.CertReloader
+	Health     *health.Registry
+	// Reloader applies changes of reloadable config fields while the server is running, Config keeps the values
+	// the server was started with
+	Reloader *config.Reloader
+	// Notifier reports state to systemd, it is nil when the server is not run by systemd
+	Notifier *sdnotify.Notifier
 
 	API *api.API
+
+	// Lifecycle starts and stops the server components, more components such as kafka consumers or schedulers
+	// can be registered before StartServer is called
+	Lifecycle *lifecycle.Manager
+
+	// listener is set up by the http component unless it is already set
+	listener net.Listener
+	// stopWatchdog stops pinging systemd watchdog
+	stopWatchdog context.CancelFunc
+	// handingOver is set while a restarted child process takes over the listener
+	handingOver int32
+	// logCloser flushes buffered log writers on shutdown
+	logCloser io.Closer
+	// kafkaLog delivers logs to kafka when enabled
+	kafkaLog *logger.KafkaLogWriter
+	// requestLog is always installed so that request logging can be turned on by config reload
+	requestLog *middleware.RequestLoggerMiddleware
 }
 
-// NewServer returns a new Server object
-func NewServer() *Server {
-	c := config.GetConfig()
-	ms := mongostorage.NewMongoStorage(&c.DatabaseConfig)
+// defaultCloseTimeout is used to drain requests when ServerConfig.CloseTimeout is not set
+const defaultCloseTimeout = 5 * time.Second
+
+// NewServer returns a new Server object configured by c, see config.GetConfig.
+// Error is returned if any of the resources fails to initialize, the ones already opened are closed in that case.
+func NewServer(c *config.Config) (*Server, error) {
+	ms, err := mongostorage.NewMongoStorage(&c.DatabaseConfig)
+	if err != nil {
+		return nil, err
+	}
 	r := mux.NewRouter()
 
 	server := &Server{
@@ -58,39 +96,127 @@ func NewServer() *Server {
 		Config:     c,
 		MongoDB:    ms,
 		Router:     r,
+		Health:     health.NewRegistry(),
+		Notifier:   sdnotify.NewNotifier(),
 	}
 
 	server.InitLoggers()
 
+	server.Reloader = config.NewReloader(c, func() (*config.Config, error) { return config.GetConfig(os.Args[1:]) }, server.Log)
+	server.Reloader.Subscribe("logger.level", func(c *config.Config) {
+		if err := logger.SetLevel(c.LoggerConfig.Level); err != nil {
+			server.Log.Error().Err(err).Msg("failed to change log level")
+		}
+	})
+
 	if c.ServerConfig.UseMemoryStore {
 		server.Redis = memorystorage.NewMemoryStorage()
 	} else {
 		server.Redis = redisstorage.NewRedisStorage(&c.RedisConfig)
 	}
 
+	tokenAuth, err := auth.NewTokenAuthentication(&c.TokenAuthConfig)
+	if err != nil {
+		server.close()
+		return nil, fmt.Errorf("failed to initialize token authentication: %w", err)
+	}
+
+	var sessions auth.Session
+	if c.SessionConfig.EnableSession {
+		sessions = auth.NewCookieSession(server.Redis, &c.SessionConfig)
+	}
+
+	var oidc *auth.OIDCClient
+	if c.OIDCConfig.EnableOIDC {
+		oidc = auth.NewOIDCClient(server.Redis, &c.OIDCConfig)
+	}
+
+	var clientCerts *auth.ClientCertificateAuth
+	if c.ServerConfig.TLSConfig.EnableTLS {
+		server.Certs, err = tlsconfig.NewCertReloader(c.ServerConfig.TLSConfig.CertFile, c.ServerConfig.TLSConfig.KeyFile, server.Log)
+		if err != nil {
+			server.close()
+			return nil, fmt.Errorf("failed to load tls certificate: %w", err)
+		}
+		if c.ServerConfig.TLSConfig.ClientCAFile != "" {
+			clientCerts = auth.NewClientCertificateAuth(&c.ServerConfig.TLSConfig)
+		}
+	}
+
+	revocations := auth.NewRevocationStore(server.Redis)
+
 	// Initializing api endpoints and controller
 	server.API = api.NewAPI(&api.Options{
 		MainRouter: r,
 		Logger:     server.Log,
 		Config:     &c.APIConfig,
-		TokenAuth:  auth.NewTokenAuthentication(&c.TokenAuthConfig),
+		TokenAuth:  tokenAuth,
 		Validator:  validator.NewValidation(),
+
+		RefreshTokens: auth.NewRefreshTokenStore(server.Redis, &c.TokenAuthConfig),
+		Revocations:   revocations,
+		Sessions:      sessions,
+		Policy:        auth.NewPolicyFromConfig(&c.RBACConfig),
+		OIDC:          oidc,
+		ClientCerts:   clientCerts,
+		Certs:         server.Certs,
+		Health:        server.Health,
 	})
 
-	// Initializing app and services
-	server.API.App = app.NewApp(&app.Options{MongoDB: ms, Logger: server.Log, Config: &c.APPConfig})
-	// server.API.App.Example = app.InitExample(&app.ExampleOpts{DBName: "example", MongoStorage: ms, Logger: server.Log})
+	// Initializing app, services are initialized when the server is started
+	server.API.App = app.NewApp(&app.Options{MongoDB: ms, Logger: server.Log, Config: &c.APPConfig, Health: server.Health, Revoker: revocations})
 
-	return server
+	if err := server.registerChecks(); err != nil {
+		server.close()
+		return nil, fmt.Errorf("failed to register health checks: %w", err)
+	}
+
+	server.Lifecycle = lifecycle.NewManager(server.Log)
+	if err := server.registerComponents(); err != nil {
+		server.close()
+		return nil, fmt.Errorf("failed to register server components: %w", err)
+	}
+
+	return server, nil
 }
 
-// StartServer function initialize middlewares, router, loggers, and server config. It establishes connection with database & other services.
-// After setting up the server it runs the server on specified address and port.
-func (s *Server) StartServer() {
+// close releases the resources opened by NewServer when it fails, components are not started at that point
+// so they are closed directly instead of by Lifecycle
+func (s *Server) close() {
+	if s.Certs != nil {
+		s.Certs.Close()
+	}
+	if s.Reloader != nil {
+		s.Reloader.Close()
+	}
+	if s.Redis != nil {
+		s.Redis.Close()
+	}
+	if s.MongoDB != nil {
+		s.MongoDB.Close()
+	}
+	if s.logCloser != nil {
+		s.logCloser.Close()
+	}
+}
+
+// StartServer function initialize middlewares, router, loggers, and server config. It starts the registered components
+// in dependency order and runs the server on specified address and port after all of them are started.
+// Error is returned if any of the components fails to start, already started ones are stopped in that case.
+func (s *Server) StartServer() error {
 	n := negroni.New()
 
-	if s.Config.MiddlewareConfig.EnableRequestLog {
-		n.UseFunc(middleware.NewRequestLoggerMiddleware(s.Log).GetMiddlewareHandler())
+	s.requestLog = middleware.NewRequestLoggerMiddleware(s.Log)
+	s.requestLog.SetEnabled(s.Config.MiddlewareConfig.EnableRequestLog)
+	if s.Reloader != nil {
+		s.Reloader.Subscribe("middleware.enableRequestLog", func(c *config.Config) {
+			s.requestLog.SetEnabled(c.MiddlewareConfig.EnableRequestLog)
+		})
+	}
+	n.UseFunc(s.requestLog.GetMiddlewareHandler())
+
+	if s.Config.MiddlewareConfig.RequestSigningConfig.EnableRequestSigning {
+		n.UseFunc(middleware.NewRequestSigningMiddleware(s.Redis, &s.Config.MiddlewareConfig.RequestSigningConfig).GetMiddlewareHandler())
 	}
 
 	n.UseHandler(s.Router)
@@ -102,31 +228,289 @@ func (s *Server) StartServer() {
 		WriteTimeout: s.Config.ServerConfig.WriteTimeout * time.Second,
 	}
 
-	s.Log.Info().Msgf("Staring server at %s:%s", s.Config.ServerConfig.ListenAddr, s.Config.ServerConfig.Port)
+	if s.Config.ServerConfig.TLSConfig.EnableTLS {
+		tc, err := tlsconfig.NewTLSConfig(&s.Config.ServerConfig.TLSConfig, s.Certs)
+		if err != nil {
+			return fmt.Errorf("failed to initialize tls: %w", err)
+		}
+		s.httpServer.TLSConfig = tc
+	}
+
+	if err := s.Lifecycle.Start(context.Background()); err != nil {
+		return err
+	}
+	// a restarted server takes over from the parent once all the components are started
+	if err := socket.NotifyParent(); err != nil {
+		s.Log.Error().Err(err).Msg("failed to hand over from the parent process")
+	}
+	return nil
+}
+
+// Restart starts a new process of the server which inherits the listener. Once the new process is serving it sends
+// SIGTERM to this process to shut it down gracefully, so connections are not refused during the restart.
+// This process keeps serving if the new one fails to start.
+func (s *Server) Restart() error {
+	if s.listener == nil {
+		return fmt.Errorf("failed to restart: server is not listening")
+	}
+	path, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to restart: %w", err)
+	}
+	if !atomic.CompareAndSwapInt32(&s.handingOver, 0, 1) {
+		return fmt.Errorf("failed to restart: restart is already in progress")
+	}
+	p, err := socket.StartChild(s.listener, path, os.Args[1:], os.Environ())
+	if err != nil {
+		atomic.StoreInt32(&s.handingOver, 0)
+		return err
+	}
+	s.Log.Info().Int("pid", p.Pid).Msg("Restarting server")
 	go func() {
-		err := s.httpServer.ListenAndServe()
+		// child exits before the parent only if it failed to start
+		state, err := p.Wait()
+		atomic.StoreInt32(&s.handingOver, 0)
 		if err != nil {
-			s.Log.Error().Err(err).Msg("")
+			s.Log.Error().Err(err).Msg("failed to wait for restarted server")
 			return
 		}
+		s.Log.Error().Str("state", state.String()).Msg("restarted server exited, this process keeps serving")
 	}()
+	return nil
+}
+
+// StopServer gracefully shuts down the server by stopping the components in the reverse order of start:
+// new connections are refused and in-flight requests are given ServerConfig.CloseTimeout seconds to complete,
+// then kafka is closed, storages are disconnected and at last buffered log writers are flushed.
+// Every component is stopped even if a previous one failed and the errors are returned combined.
+func (s *Server) StopServer() error {
+	s.Log.Info().Msg("Stopping server")
+	// readiness fails from now on so that no new requests are routed to the server
+	if s.Health != nil {
+		s.Health.SetShuttingDown()
+	}
+	return s.Lifecycle.Stop(context.Background())
 }
 
-// StopServer closes all the connection and shutdown the server
-func (s *Server) StopServer() {
+// registerChecks registers readiness checks of the server dependencies
+func (s *Server) registerChecks() error {
+	checks := map[string]health.Checker{}
+	if s.MongoDB != nil {
+		checks["mongodb"] = health.CheckFunc(s.MongoDB.Ping)
+	}
+	if s.Redis != nil {
+		checks["redis"] = health.CheckFunc(s.Redis.Ping)
+	}
 	if s.Kafka != nil {
-		s.Kafka.Close()
+		checks["kafka"] = health.CheckFunc(s.Kafka.Ping)
+	}
+	if s.kafkaLog != nil {
+		checks["kafka_log"] = health.CheckFunc(s.kafkaLog.Ping)
 	}
+	// registered in fixed order so that checks are reported consistently
+	for _, name := range []string{"mongodb", "redis", "kafka", "kafka_log"} {
+		if c, ok := checks[name]; ok {
+			if err := s.Health.Register(name, c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// registerComponents registers lifecycle of the server resources.
+// The http listener depends on all the others so that it is started last and stopped first, and every component depends
+// on the logger so that buffered log writers are flushed after everything else is stopped.
+func (s *Server) registerComponents() error {
+	var all, storages []string
+	register := func(c *lifecycle.Component) error {
+		all = append(all, c.Name)
+		return s.Lifecycle.Register(c)
+	}
+
+	components := []*lifecycle.Component{{
+		Name: "logger",
+		Stop: func(ctx context.Context) error {
+			if s.logCloser == nil {
+				return nil
+			}
+			return s.logCloser.Close()
+		},
+	}}
 	if s.MongoDB != nil {
-		s.MongoDB.Close()
+		storages = append(storages, "mongodb")
+		components = append(components, &lifecycle.Component{Name: "mongodb", DependsOn: []string{"logger"}, Stop: closeHook(s.MongoDB.Close)})
 	}
 	if s.Redis != nil {
-		s.Redis.Close()
+		storages = append(storages, "redis")
+		components = append(components, &lifecycle.Component{Name: "redis", DependsOn: []string{"logger"}, Stop: closeHook(s.Redis.Close)})
+	}
+	if s.Kafka != nil {
+		storages = append(storages, "kafka")
+		components = append(components, &lifecycle.Component{Name: "kafka", DependsOn: []string{"logger"}, Stop: closeHook(s.Kafka.Close)})
+	}
+	if s.Certs != nil {
+		components = append(components, &lifecycle.Component{
+			Name:      "tls",
+			DependsOn: []string{"logger"},
+			Start: func(ctx context.Context) error {
+				// rotated certificates are picked up without restart, the loaded one keeps being served if watching fails
+				if err := s.Certs.Watch(); err != nil {
+					s.Log.Error().Err(err).Msg("failed to watch tls certificate")
+				}
+				return nil
+			},
+			Stop: func(ctx context.Context) error { return s.Certs.Close() },
+		})
+	}
+	if s.Reloader != nil {
+		components = append(components, &lifecycle.Component{
+			Name:      "config",
+			DependsOn: []string{"logger"},
+			Start: func(ctx context.Context) error {
+				// the server keeps running with the loaded configuration if watching fails
+				if err := s.Reloader.Watch(); err != nil {
+					s.Log.Error().Err(err).Msg("failed to watch config files")
+				}
+				return nil
+			},
+			Stop: func(ctx context.Context) error { return s.Reloader.Close() },
+		})
+	}
+	if s.API != nil && s.API.App != nil {
+		components = append(components, &lifecycle.Component{
+			Name:      "app",
+			DependsOn: append([]string{"logger"}, storages...),
+			Start:     func(ctx context.Context) error { return app.InitService(s.API.App) },
+		})
+	}
+	for _, c := range components {
+		if err := register(c); err != nil {
+			return err
+		}
+	}
+
+	closeTimeout := s.Config.ServerConfig.CloseTimeout * time.Second
+	if closeTimeout <= 0 {
+		closeTimeout = defaultCloseTimeout
+	}
+	err := register(&lifecycle.Component{
+		Name:      "http",
+		DependsOn: all,
+		// remaining connections are closed after requests are drained for closeTimeout
+		Timeout: closeTimeout + time.Second,
+		Start:   func(ctx context.Context) error { return s.listen() },
+		Stop: func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, closeTimeout)
+			defer cancel()
+			// listener is closed by shutdown
+			s.listener = nil
+			if err := s.httpServer.Shutdown(ctx); err != nil {
+				// requests still running after the timeout are aborted
+				s.httpServer.Close()
+				return fmt.Errorf("failed to drain requests: %w", err)
+			}
+			return nil
+		},
+	})
+	if err != nil || s.Notifier == nil {
+		return err
+	}
+	// notified once the server is listening and first to be stopped, so systemd knows the server is stopping
+	// before requests are drained
+	return s.Lifecycle.Register(&lifecycle.Component{
+		Name:      "systemd",
+		DependsOn: []string{"http"},
+		Start:     func(ctx context.Context) error { return s.notifyReady() },
+		Stop: func(ctx context.Context) error {
+			if s.stopWatchdog != nil {
+				s.stopWatchdog()
+			}
+			// the restarted process is the main process of the service now
+			if atomic.LoadInt32(&s.handingOver) == 1 {
+				return nil
+			}
+			return s.Notifier.Stopping("draining requests")
+		},
+	})
+}
+
+// notifyReady notifies systemd that the server is ready and starts pinging the watchdog if it is enabled.
+// Failing notifications are logged, they do not fail the start.
+func (s *Server) notifyReady() error {
+	states := []string{sdnotify.Ready, fmt.Sprintf("STATUS=serving on %s", s.listener.Addr())}
+	if socket.IsChild() {
+		// requires NotifyAccess=all in the unit so that systemd accepts notifications of the restarted process
+		states = append(states, fmt.Sprintf("MAINPID=%d", os.Getpid()))
+	}
+	if err := s.Notifier.Notify(states...); err != nil {
+		s.Log.Error().Err(err).Msg("Systemd notification error")
+	}
+	interval, err := sdnotify.WatchdogInterval()
+	if err != nil {
+		s.Log.Error().Err(err).Msg("Systemd watchdog is not started")
+	}
+	if interval == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stopWatchdog = cancel
+	healthy := func(ctx context.Context) error { return s.Health.Readiness(ctx).Err() }
+	go s.Notifier.Watchdog(ctx, interval, healthy, func(err error) {
+		s.Log.Error().Err(err).Msg("Systemd watchdog notification error")
+	})
+	return nil
+}
+
+// closeHook adapts Close method of a resource to a lifecycle hook
+func closeHook(close func()) lifecycle.Hook {
+	return func(ctx context.Context) error {
+		close()
+		return nil
+	}
+}
+
+// listen sets up the listener before returning so that errors such as address already in use are reported to the caller,
+// requests are served in a separate go routine.
+// Listener passed by systemd socket activation or by the parent process on restart is used instead of binding the address.
+func (s *Server) listen() error {
+	if s.listener == nil {
+		lns, err := socket.Inherited()
+		if err != nil {
+			return err
+		}
+		for i, ln := range lns {
+			if i == 0 {
+				s.listener = ln
+				continue
+			}
+			s.Log.Warn().Str("addr", ln.Addr().String()).Msg("only the first inherited listener is used")
+			ln.Close()
+		}
+	}
+	if s.listener == nil {
+		ln, err := net.Listen("tcp", s.httpServer.Addr)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", s.httpServer.Addr, err)
+		}
+		s.listener = ln
+	}
+	s.Log.Info().Bool("tls", s.httpServer.TLSConfig != nil).Msgf("Staring server at %s", s.listener.Addr())
+	go s.serve(s.listener)
+	return nil
+}
+
+func (s *Server) serve(ln net.Listener) {
+	var err error
+	if s.httpServer.TLSConfig != nil {
+		// certificate is served by GetCertificate of TLSConfig
+		err = s.httpServer.ServeTLS(ln, "", "")
+	} else {
+		err = s.httpServer.Serve(ln)
+	}
+	if err != nil && err != http.ErrServerClosed {
+		s.Log.Error().Err(err).Msg("server stopped unexpectedly")
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	s.httpServer.Shutdown(ctx)
-	os.Exit(0)
 }
 
 // InitLoggers initializes all the loggers
@@ -143,8 +527,13 @@ func (s *Server) InitLoggers() {
 	if s.Config.LoggerConfig.EnableConsoleLogger {
 		cw = logger.NewZeroLogConsoleWriter(logger.NewStandardConsoleWriter())
 	}
-	l := logger.NewLogger(kl, cw, fw)
+	l, closer := logger.NewBufferedLogger(kl, cw, fw)
 
 	// Setting logger
+	if err := logger.SetLevel(s.Config.LoggerConfig.Level); err != nil {
+		l.Error().Err(err).Msg("invalid log level")
+	}
 	s.Log = l
+	s.logCloser = closer
+	s.kafkaLog = kl
 }
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"context"
+	"go-app/server/config"
+	"go-app/server/health"
+	"go-app/server/lifecycle"
+	"go-app/server/sdnotify"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+// closeRecorder records the order in which requests complete and resources are closed
+type closeRecorder struct {
+	mu    sync.Mutex
+	steps []string
+}
+
+func (cr *closeRecorder) add(step string) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.steps = append(cr.steps, step)
+}
+
+type testCloser struct {
+	name string
+	rec  *closeRecorder
+}
+
+func (c *testCloser) Close()                     { c.rec.add(c.name) }
+func (c *testCloser) Ping(context.Context) error { return nil }
+
+type testRedis struct {
+	testCloser
+}
+
+func (r *testRedis) Find(string) ([]byte, bool, error)      { return nil, false, nil }
+func (r *testRedis) Commit(string, []byte, time.Time) error { return nil }
+func (r *testRedis) Delete(string) error                    { return nil }
+func (r *testRedis) FindAndDelete(string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+func (r *testRedis) CommitIfAbsent(string, []byte, time.Time) (bool, error) {
+	return true, nil
+}
+func (r *testRedis) CompareAndCommit(string, []byte, []byte, time.Time) (bool, error) {
+	return false, nil
+}
+
+// newTestServer returns a server serving handler on a random port along with its url, opts are applied before
+// the components are registered
+func newTestServer(t *testing.T, rec *closeRecorder, closeTimeout time.Duration, handler http.HandlerFunc, opts ...func(*Server)) (*Server, string) {
+	l := zerolog.Nop()
+	s := &Server{
+		httpServer: &http.Server{Handler: handler},
+		Log:        &l,
+		Config:     &config.Config{ServerConfig: config.ServerConfig{CloseTimeout: closeTimeout}},
+		Kafka:      &testCloser{name: "kafka", rec: rec},
+		MongoDB:    &testCloser{name: "mongodb", rec: rec},
+		Redis:      &testRedis{testCloser{name: "redis", rec: rec}},
+		Health:     health.NewRegistry(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.Lifecycle = lifecycle.NewManager(&l)
+	assert.Nil(t, s.registerComponents())
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	s.listener = ln
+	assert.Nil(t, s.Lifecycle.Start(context.Background()))
+	return s, "http://" + ln.Addr().String()
+}
+
+func TestServer_StopServer(t *testing.T) {
+	rec := &closeRecorder{}
+	started := make(chan struct{})
+	s, url := newTestServer(t, rec, 5, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		rec.add("request")
+	})
+
+	done := make(chan error)
+	go func() {
+		resp, err := http.Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+	<-started
+
+	assert.True(t, s.Health.Readiness(context.Background()).OK())
+	assert.Nil(t, s.StopServer())
+	assert.Nil(t, <-done)
+	assert.Equal(t, health.StatusShuttingDown, s.Health.Readiness(context.Background()).Status)
+	assert.Equal(t, []string{"request", "kafka", "redis", "mongodb"}, rec.steps)
+
+	// new connections are refused once stopped
+	_, err := http.Get(url)
+	assert.NotNil(t, err)
+}
+
+func TestServer_StopServerTimeout(t *testing.T) {
+	rec := &closeRecorder{}
+	started, release := make(chan struct{}), make(chan struct{})
+	s, url := newTestServer(t, rec, 1, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	defer close(release)
+
+	go func() {
+		resp, err := http.Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+
+	err := s.StopServer()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "failed to drain requests")
+	// resources are closed even though requests did not complete in time
+	assert.Equal(t, []string{"kafka", "redis", "mongodb"}, rec.steps)
+}
+
+func TestNewServer_Error(t *testing.T) {
+	// fails before any connection is made, the process keeps running
+	s, err := NewServer(&config.Config{DatabaseConfig: config.DatabaseConfig{Scheme: "invalid", Host: "localhost:27017"}})
+	assert.Nil(t, s)
+	assert.NotNil(t, err)
+}
+
+func TestServer_close(t *testing.T) {
+	rec := &closeRecorder{}
+	s := &Server{
+		MongoDB: &testCloser{name: "mongodb", rec: rec},
+		Redis:   &testRedis{testCloser{name: "redis", rec: rec}},
+	}
+	s.close()
+	assert.Equal(t, []string{"redis", "mongodb"}, rec.steps)
+}
+
+func TestServer_registerChecks(t *testing.T) {
+	rec := &closeRecorder{}
+	l := zerolog.Nop()
+	s := &Server{
+		Log:     &l,
+		Health:  health.NewRegistry(),
+		MongoDB: &testCloser{name: "mongodb", rec: rec},
+		Redis:   &testRedis{testCloser{name: "redis", rec: rec}},
+	}
+	assert.Nil(t, s.registerChecks())
+	report := s.Health.Readiness(context.Background())
+	assert.True(t, report.OK())
+	var names []string
+	for _, c := range report.Checks {
+		names = append(names, c.Name)
+	}
+	assert.Equal(t, []string{"mongodb", "redis"}, names)
+}
+
+func TestServer_systemdNotifications(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "server")
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	assert.Nil(t, err)
+	defer conn.Close()
+	receive := func() string {
+		buf := make([]byte, 1024)
+		conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+		n, _ := conn.Read(buf)
+		return string(buf[:n])
+	}
+
+	rec := &closeRecorder{}
+	s, url := newTestServer(t, rec, 5, func(w http.ResponseWriter, r *http.Request) {}, func(s *Server) {
+		s.Notifier = &sdnotify.Notifier{Socket: socket}
+	})
+	// notified once listening
+	assert.Equal(t, "READY=1\nSTATUS=serving on "+strings.TrimPrefix(url, "http://"), receive())
+
+	assert.Nil(t, s.StopServer())
+	assert.Equal(t, "STOPPING=1\nSTATUS=draining requests", receive())
+
+	// stopping is not notified when the restarted process took over
+	s, _ = newTestServer(t, rec, 5, func(w http.ResponseWriter, r *http.Request) {}, func(s *Server) {
+		s.Notifier = &sdnotify.Notifier{Socket: socket}
+	})
+	assert.Contains(t, receive(), "READY=1")
+	s.handingOver = 1
+	assert.Nil(t, s.StopServer())
+	assert.Equal(t, "", receive())
+}
@@ -0,0 +1,140 @@
+/*
+	Package socket lets the server inherit its listening socket instead of binding the address itself.
+
+	Two ways of inheriting are supported, both use the systemd socket activation convention of passing the sockets as
+	file descriptors starting at 3 with their count in LISTEN_FDS:
+
+	- socket activation, systemd binds the address and sets LISTEN_PID to the pid of the service
+	- zero downtime restart, a running server starts a new process of itself passing its listener (see StartChild).
+	  REEXEC_PARENT_PID is set instead of LISTEN_PID as the pid of the child is not known before it is started. Once the
+	  child is serving it asks the parent to shut down gracefully (see NotifyParent), so no connection is refused
+	  during the restart.
+*/
+
+package socket
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Environment variables used to pass listeners
+const (
+	EnvListenFDs       = "LISTEN_FDS"
+	EnvListenPID       = "LISTEN_PID"
+	EnvListenFDNames   = "LISTEN_FDNAMES"
+	EnvReexecParentPID = "REEXEC_PARENT_PID"
+)
+
+// listenFDsStart is the first inherited file descriptor, following stdin, stdout and stderr
+const listenFDsStart = 3
+
+// Inherited returns listeners passed to the process by systemd or by the parent process on restart.
+// No listener is returned when none was passed. The environment variables are unset so that they are not passed on to
+// processes started by the server.
+func Inherited() ([]net.Listener, error) {
+	return inherited(listenFDsStart)
+}
+
+func inherited(start int) ([]net.Listener, error) {
+	defer func() {
+		os.Unsetenv(EnvListenFDs)
+		os.Unsetenv(EnvListenPID)
+		os.Unsetenv(EnvListenFDNames)
+	}()
+	if !passedToThisProcess() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv(EnvListenFDs))
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("socket: invalid %s %q", EnvListenFDs, os.Getenv(EnvListenFDs))
+	}
+	listeners := make([]net.Listener, 0, n)
+	for fd := start; fd < start+n; fd++ {
+		syscall.CloseOnExec(fd)
+		f := os.NewFile(uintptr(fd), fmt.Sprintf("listen_fd_%d", fd))
+		ln, err := net.FileListener(f)
+		// listener holds a duplicate of the descriptor
+		f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("socket: file descriptor %d is not a listening socket: %w", fd, err)
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+// passedToThisProcess returns true if the sockets are meant for this process and not for a parent which left
+// the variables in the environment
+func passedToThisProcess() bool {
+	if os.Getenv(EnvListenFDs) == "" {
+		return false
+	}
+	if pid := os.Getenv(EnvListenPID); pid != "" {
+		return pid == strconv.Itoa(os.Getpid())
+	}
+	return IsChild()
+}
+
+// IsChild returns true if the process was started by StartChild of a server it replaces
+func IsChild() bool {
+	ppid := os.Getenv(EnvReexecParentPID)
+	return ppid != "" && ppid == strconv.Itoa(os.Getppid())
+}
+
+// fileListener is implemented by tcp and unix listeners
+type fileListener interface {
+	File() (*os.File, error)
+}
+
+// StartChild starts the executable at path with ln passed as the only inherited listener.
+// The child is expected to serve using the listener and call NotifyParent once it is ready.
+func StartChild(ln net.Listener, path string, args, env []string) (*os.Process, error) {
+	fl, ok := ln.(fileListener)
+	if !ok {
+		return nil, fmt.Errorf("socket: listener %T can not be passed to a child process", ln)
+	}
+	f, err := fl.File()
+	if err != nil {
+		return nil, fmt.Errorf("socket: failed to get listener file: %w", err)
+	}
+	defer f.Close()
+
+	childEnv := make([]string, 0, len(env)+2)
+	for _, kv := range env {
+		switch strings.SplitN(kv, "=", 2)[0] {
+		case EnvListenFDs, EnvListenPID, EnvListenFDNames, EnvReexecParentPID:
+			continue
+		}
+		childEnv = append(childEnv, kv)
+	}
+	childEnv = append(childEnv, EnvListenFDs+"=1", EnvReexecParentPID+"="+strconv.Itoa(os.Getpid()))
+
+	p, err := os.StartProcess(path, append([]string{path}, args...), &os.ProcAttr{
+		Env:   childEnv,
+		Files: []*os.File{os.Stdin, os.Stdout, os.Stderr, f},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("socket: failed to start child process: %w", err)
+	}
+	return p, nil
+}
+
+// NotifyParent asks the parent which started this process by StartChild to shut down gracefully.
+// It does nothing if the process was not started by StartChild.
+func NotifyParent() error {
+	if !IsChild() {
+		return nil
+	}
+	if err := syscall.Kill(os.Getppid(), syscall.SIGTERM); err != nil {
+		return fmt.Errorf("socket: failed to notify parent: %w", err)
+	}
+	return nil
+}
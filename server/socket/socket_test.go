@@ -0,0 +1,118 @@
+package socket
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHelperChild is run as the child process by TestStartChild, it serves its pid on the inherited listener
+func TestHelperChild(t *testing.T) {
+	if os.Getenv("GO_APP_HELPER_CHILD") != "1" {
+		return
+	}
+	lns, err := Inherited()
+	if err != nil || len(lns) != 1 {
+		fmt.Fprintf(os.Stderr, "unexpected listeners %v: %v", lns, err)
+		os.Exit(1)
+	}
+	if os.Getenv(EnvListenFDs) != "" {
+		fmt.Fprintf(os.Stderr, "%s is not unset", EnvListenFDs)
+		os.Exit(1)
+	}
+	go http.Serve(lns[0], http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, os.Getpid())
+	}))
+	if err := NotifyParent(); err != nil {
+		fmt.Fprint(os.Stderr, err)
+		os.Exit(1)
+	}
+	// stopped by the test
+	select {}
+}
+
+func TestStartChild(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+
+	terminated := make(chan os.Signal, 1)
+	signal.Notify(terminated, syscall.SIGTERM)
+	defer signal.Stop(terminated)
+
+	env := append(os.Environ(), "GO_APP_HELPER_CHILD=1", EnvListenPID+"=1")
+	p, err := StartChild(ln, os.Args[0], []string{"-test.run=TestHelperChild"}, env)
+	assert.Nil(t, err)
+	defer p.Kill()
+
+	// child asks the parent to shut down once it serves on the listener
+	select {
+	case <-terminated:
+	case <-time.After(10 * time.Second):
+		t.Fatal("child did not notify the parent")
+	}
+	resp, err := http.Get("http://" + ln.Addr().String())
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, strconv.Itoa(p.Pid), string(body))
+}
+
+func TestInherited(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+	f, err := ln.(*net.TCPListener).File()
+	assert.Nil(t, err)
+	fd := int(f.Fd())
+	defer os.Unsetenv(EnvReexecParentPID)
+
+	type TestCase struct {
+		Name    string
+		Env     map[string]string
+		Count   int
+		WantErr bool
+	}
+	tests := []TestCase{
+		{Name: "not passed"},
+		{Name: "socket activation", Env: map[string]string{EnvListenFDs: "1", EnvListenPID: strconv.Itoa(os.Getpid())}, Count: 1},
+		{Name: "passed to another process", Env: map[string]string{EnvListenFDs: "1", EnvListenPID: "1"}},
+		{Name: "restart", Env: map[string]string{EnvListenFDs: "1", EnvReexecParentPID: strconv.Itoa(os.Getppid())}, Count: 1},
+		{Name: "restart of another parent", Env: map[string]string{EnvListenFDs: "1", EnvReexecParentPID: "1"}},
+		{Name: "invalid count", Env: map[string]string{EnvListenFDs: "one", EnvListenPID: strconv.Itoa(os.Getpid())}, WantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			os.Unsetenv(EnvReexecParentPID)
+			for k, v := range tt.Env {
+				os.Setenv(k, v)
+			}
+			// dup so that the descriptor stays valid after the inherited listener is closed
+			dup, err := syscall.Dup(fd)
+			assert.Nil(t, err)
+			defer syscall.Close(dup)
+			lns, err := inherited(dup)
+			assert.Equal(t, "", os.Getenv(EnvListenFDs))
+			if tt.WantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Len(t, lns, tt.Count)
+			for _, l := range lns {
+				assert.Equal(t, ln.Addr().String(), l.Addr().String())
+				l.Close()
+			}
+		})
+	}
+	f.Close()
+}
@@ -1,6 +1,8 @@
 package memorystorage
 
 import (
+	"bytes"
+	"context"
 	"sync"
 	"time"
 )
@@ -20,8 +22,7 @@ type MemoryStore struct {
 // NewMemoryStorage returns a new MemoryStore instance, with a background cleanup goroutine that
 // runs every minute to remove expired session data.
 func NewMemoryStorage() *MemoryStore {
-	mms := MemoryStore{}
-	return &mms
+	return NewMemoryStorageWithCleanupInterval(time.Minute)
 }
 
 // NewMemoryStorageWithCleanupInterval returns a new MemoryStore instance. The cleanupInterval
@@ -58,6 +59,9 @@ func (m *MemoryStore) startCleanup(interval time.Duration) {
 // method does nothing.
 func (m *MemoryStore) Close() {}
 
+// Ping always succeeds as the data is stored in process memory
+func (m *MemoryStore) Ping(context.Context) error { return nil }
+
 // StopCleanup terminates the background cleanup goroutine for the MemoryStore
 // instance. It's rare to terminate this; generally MemoryStore instances and
 // their cleanup goroutines are intended to be long-lived and run for the lifetime
@@ -118,6 +122,55 @@ func (m *MemoryStore) Commit(token string, b []byte, expiry time.Time) error {
 	return nil
 }
 
+// FindAndDelete returns the data for a given session token and removes it from the MemoryStore instance in a single
+// step. If the session token is not found or is expired, the returned exists flag will be set to false.
+func (m *MemoryStore) FindAndDelete(token string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, found := m.items[token]
+	if !found {
+		return nil, false, nil
+	}
+	delete(m.items, token)
+	if time.Now().UnixNano() > item.expiration {
+		return nil, false, nil
+	}
+	return item.object, true, nil
+}
+
+// CommitIfAbsent adds a session token and data to the MemoryStore instance with the given expiry time only if the
+// session token does not exist or is expired. The returned flag is false if the session token already exists.
+func (m *MemoryStore) CommitIfAbsent(token string, b []byte, expiry time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if item, found := m.items[token]; found && time.Now().UnixNano() <= item.expiration {
+		return false, nil
+	}
+	m.items[token] = item{
+		object:     b,
+		expiration: expiry.UnixNano(),
+	}
+	return true, nil
+}
+
+// CompareAndCommit updates a session token with data and expiry time only if it currently holds old data.
+// The returned flag is false if the session token is missing, expired or holds other data.
+func (m *MemoryStore) CompareAndCommit(token string, old, b []byte, expiry time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, found := m.items[token]
+	if !found || time.Now().UnixNano() > item.expiration || !bytes.Equal(item.object, old) {
+		return false, nil
+	}
+	item.object = b
+	item.expiration = expiry.UnixNano()
+	m.items[token] = item
+	return true, nil
+}
+
 // Delete removes a session token and corresponding data from the MemoryStore
 // instance.
 func (m *MemoryStore) Delete(token string) error {
@@ -73,6 +73,78 @@ func TestCommitUpdated(t *testing.T) {
 	}
 }
 
+func TestFindAndDelete(t *testing.T) {
+	m := NewMemoryStorageWithCleanupInterval(0)
+	m.items["session_token"] = item{object: []byte("encoded_data"), expiration: time.Now().Add(time.Second).UnixNano()}
+
+	b, found, err := m.FindAndDelete("session_token")
+	if err != nil || found != true {
+		t.Fatalf("got %v, %v: expected %v, %v", found, err, true, nil)
+	}
+	if bytes.Equal(b, []byte("encoded_data")) == false {
+		t.Fatalf("got %v: expected %v", b, []byte("encoded_data"))
+	}
+
+	// data is returned only once
+	_, found, _ = m.FindAndDelete("session_token")
+	if found != false {
+		t.Fatalf("got %v: expected %v", found, false)
+	}
+}
+
+func TestCommitIfAbsent(t *testing.T) {
+	m := NewMemoryStorageWithCleanupInterval(0)
+
+	ok, err := m.CommitIfAbsent("session_token", []byte("encoded_data"), time.Now().Add(time.Minute))
+	if err != nil || ok != true {
+		t.Fatalf("got %v, %v: expected %v, %v", ok, err, true, nil)
+	}
+
+	ok, err = m.CommitIfAbsent("session_token", []byte("new_encoded_data"), time.Now().Add(time.Minute))
+	if err != nil || ok != false {
+		t.Fatalf("got %v, %v: expected %v, %v", ok, err, false, nil)
+	}
+	if v := m.items["session_token"].object; bytes.Equal(v, []byte("encoded_data")) == false {
+		t.Fatalf("got %v: expected %v", v, []byte("encoded_data"))
+	}
+
+	// expired data is replaced
+	m.items["session_token"] = item{object: []byte("encoded_data"), expiration: time.Now().Add(-time.Second).UnixNano()}
+	ok, _ = m.CommitIfAbsent("session_token", []byte("new_encoded_data"), time.Now().Add(time.Minute))
+	if ok != true {
+		t.Fatalf("got %v: expected %v", ok, true)
+	}
+}
+
+func TestCompareAndCommit(t *testing.T) {
+	m := NewMemoryStorageWithCleanupInterval(0)
+
+	ok, err := m.CompareAndCommit("session_token", []byte("encoded_data"), []byte("new_encoded_data"), time.Now().Add(time.Minute))
+	if err != nil || ok != false {
+		t.Fatalf("got %v, %v: expected %v, %v", ok, err, false, nil)
+	}
+
+	m.Commit("session_token", []byte("encoded_data"), time.Now().Add(time.Minute))
+	ok, err = m.CompareAndCommit("session_token", []byte("other_data"), []byte("new_encoded_data"), time.Now().Add(time.Minute))
+	if err != nil || ok != false {
+		t.Fatalf("got %v, %v: expected %v, %v", ok, err, false, nil)
+	}
+
+	ok, err = m.CompareAndCommit("session_token", []byte("encoded_data"), []byte("new_encoded_data"), time.Now().Add(time.Minute))
+	if err != nil || ok != true {
+		t.Fatalf("got %v, %v: expected %v, %v", ok, err, true, nil)
+	}
+	if v := m.items["session_token"].object; bytes.Equal(v, []byte("new_encoded_data")) == false {
+		t.Fatalf("got %v: expected %v", v, []byte("new_encoded_data"))
+	}
+
+	// data can be replaced only once
+	ok, _ = m.CompareAndCommit("session_token", []byte("encoded_data"), []byte("other_data"), time.Now().Add(time.Minute))
+	if ok != false {
+		t.Fatalf("got %v: expected %v", ok, false)
+	}
+}
+
 func TestExpiry(t *testing.T) {
 	m := NewMemoryStorageWithCleanupInterval(0)
 
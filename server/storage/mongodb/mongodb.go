@@ -4,11 +4,10 @@ import (
 	"context"
 	"fmt"
 	"go-app/server/config"
-	"log"
-	"os"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 // MongoStorage containing mongodb client connection and configuration
@@ -17,27 +16,30 @@ type MongoStorage struct {
 	Client *mongo.Client
 }
 
-// NewMongoStorage returns new mongodb storage instance
-func NewMongoStorage(c *config.DatabaseConfig) *MongoStorage {
+// NewMongoStorage returns new mongodb storage instance, error is returned if mongodb can not be reached
+func NewMongoStorage(c *config.DatabaseConfig) (*MongoStorage, error) {
+	// connection url carries the password, it must never be printed
 	clientOpts := options.Client().ApplyURI(c.ConnectionURL())
-	fmt.Println(c.ConnectionURL())
 	client, err := mongo.NewClient(clientOpts)
 	if err != nil {
-		log.Fatalf("failed to establish connection with mongodb: %s", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("failed to establish connection with mongodb: %w", err)
 	}
 
 	if err := client.Connect(context.TODO()); err != nil {
-		log.Fatalf("failed to connect with mongodb: %s", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("failed to connect with mongodb: %w", err)
 	}
 
-	// checking if client is pining or not otherwise exit
+	// checking if client is pining or not otherwise disconnect
 	if err := client.Ping(context.TODO(), nil); err != nil {
-		log.Fatalf("mongodb ping failed: %s", err)
-		os.Exit(1)
+		client.Disconnect(context.TODO())
+		return nil, fmt.Errorf("mongodb ping failed: %w", err)
 	}
-	return &MongoStorage{Config: c, Client: client}
+	return &MongoStorage{Config: c, Client: client}, nil
+}
+
+// Ping checks that the primary is reachable
+func (m *MongoStorage) Ping(ctx context.Context) error {
+	return m.Client.Ping(ctx, readpref.Primary())
 }
 
 // Close closes mongodb connection
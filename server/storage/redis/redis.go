@@ -1,7 +1,7 @@
 package redisstorage
 
 import (
-	"fmt"
+	"context"
 	"go-app/server/config"
 	"time"
 
@@ -11,12 +11,27 @@ import (
 // RedisStorage stores for redis client and redis config
 type RedisStorage struct {
 	Config *config.RedisConfig
-	Conn   redis.Conn
+	Pool   *redis.Pool
+}
+
+// Ping issues PING command, the context bounds time spent on getting a connection and waiting for the reply
+func (rs *RedisStorage) Ping(ctx context.Context) error {
+	conn, err := rs.Pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_, err = redis.DoWithTimeout(conn, time.Until(deadline), "PING")
+		return err
+	}
+	_, err = conn.Do("PING")
+	return err
 }
 
 // Close closes redis connection
 func (rs *RedisStorage) Close() {
-	rs.Conn.Close()
+	rs.Pool.Close()
 }
 
 // NewRedisStorage returns new redis instance
@@ -28,16 +43,14 @@ func NewRedisStorage(c *config.RedisConfig) *RedisStorage {
 		MaxIdle: 25,
 		// max number of connections
 		Dial: func() (redis.Conn, error) {
-			redisConnStart := time.Now()
-			fmt.Println(c.ConnectionURL())
-			c, err := redis.Dial("tcp", c.ConnectionURL())
-			redisConnDuration := time.Since(redisConnStart)
-			fmt.Println("redis connection latency = s%", redisConnDuration)
-			if err != nil {
-				return nil, err
+			opts := []redis.DialOption{}
+			if c.Username != "" {
+				opts = append(opts, redis.DialUsername(c.Username))
 			}
-			return c, err
-
+			if c.Password != "" {
+				opts = append(opts, redis.DialPassword(string(c.Password)))
+			}
+			return redis.Dial(c.DialNetwork(), c.Addr(), opts...)
 		},
 		TestOnBorrow: func(c redis.Conn, t time.Time) error {
 			if time.Since(t) < time.Minute {
@@ -47,11 +60,109 @@ func NewRedisStorage(c *config.RedisConfig) *RedisStorage {
 			return err
 		},
 	}
-	conn := client.Get()
-	return &RedisStorage{Conn: conn}
+	return &RedisStorage{Config: c, Pool: client}
 }
 
-// Close closes redis connection
+// Do executes redis command using a connection from the pool
 func (rs *RedisStorage) Do(commandName string, args ...interface{}) (reply interface{}, err error) {
-	return rs.Conn.Do(commandName, args...)
+	conn := rs.Pool.Get()
+	defer conn.Close()
+	return conn.Do(commandName, args...)
+}
+
+// Find returns the data for a given key. If the key is not found or is expired, the returned exists flag will be
+// set to false.
+func (rs *RedisStorage) Find(key string) ([]byte, bool, error) {
+	b, err := redis.Bytes(rs.Do("GET", key))
+	if err == redis.ErrNil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
+
+// Commit adds key and data with the given expiry time. If the key already exists, then the data and expiry time are
+// updated.
+func (rs *RedisStorage) Commit(key string, b []byte, expiry time.Time) error {
+	ttl := time.Until(expiry).Milliseconds()
+	if ttl <= 0 {
+		return rs.Delete(key)
+	}
+	_, err := rs.Do("SET", key, b, "PX", ttl)
+	return err
+}
+
+// CommitIfAbsent adds key and data with the given expiry time only if the key does not exist, using SET NX.
+// The returned flag is false if the key already exists. Nothing is stored if the expiry time has already passed.
+func (rs *RedisStorage) CommitIfAbsent(key string, b []byte, expiry time.Time) (bool, error) {
+	ttl := time.Until(expiry).Milliseconds()
+	if ttl <= 0 {
+		return true, nil
+	}
+	_, err := redis.String(rs.Do("SET", key, b, "NX", "PX", ttl))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// findAndDeleteScript returns data of the key and deletes it, GETDEL is not used as it requires redis 6.2
+var findAndDeleteScript = redis.NewScript(1, `
+local v = redis.call("GET", KEYS[1])
+if v then
+	redis.call("DEL", KEYS[1])
+end
+return v
+`)
+
+// FindAndDelete returns the data for a given key and deletes the key in a single step, so the data is returned to only
+// one of concurrent callers. If the key is not found or is expired, the returned exists flag will be set to false.
+func (rs *RedisStorage) FindAndDelete(key string) ([]byte, bool, error) {
+	conn := rs.Pool.Get()
+	defer conn.Close()
+	b, err := redis.Bytes(findAndDeleteScript.Do(conn, key))
+	if err == redis.ErrNil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
+
+// compareAndCommitScript sets the key to ARGV[2] with ttl ARGV[3] in milliseconds, or deletes it when ttl is not
+// positive, only if the key holds ARGV[1]
+var compareAndCommitScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+else
+	redis.call("DEL", KEYS[1])
+end
+return 1
+`)
+
+// CompareAndCommit updates key with data and expiry time only if it currently holds old. The returned flag is false
+// if the key is missing or holds other data. The check and update run in a single lua script so they are atomic.
+func (rs *RedisStorage) CompareAndCommit(key string, old, b []byte, expiry time.Time) (bool, error) {
+	conn := rs.Pool.Get()
+	defer conn.Close()
+	n, err := redis.Int(compareAndCommitScript.Do(conn, key, old, b, time.Until(expiry).Milliseconds()))
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// Delete removes key and corresponding data
+func (rs *RedisStorage) Delete(key string) error {
+	_, err := rs.Do("DEL", key)
+	return err
 }
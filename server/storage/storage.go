@@ -1,11 +1,29 @@
 package storage
 
+import (
+	"context"
+	"time"
+)
+
 // DB used by server to implement any storage interface by redis client.
+// Ping checks connectivity with the database and is used by readiness check.
 type DB interface {
+	Ping(context.Context) error
 	Close()
 }
 
 // Redis used by server to implement any storage interface by redis client.
+// Find, Commit and Delete provide a key value store with expiry which is implemented by both redis and memory storage.
+// CommitIfAbsent adds a key only if it does not exist, CompareAndCommit replaces the data of a key only if it still
+// holds the given old data and FindAndDelete removes a key returning its data, they are atomic across every instance
+// sharing the storage.
 type Redis interface {
+	Find(string) ([]byte, bool, error)
+	FindAndDelete(string) ([]byte, bool, error)
+	Commit(string, []byte, time.Time) error
+	CommitIfAbsent(string, []byte, time.Time) (bool, error)
+	CompareAndCommit(key string, old, new []byte, expiry time.Time) (bool, error)
+	Delete(string) error
+	Ping(context.Context) error
 	Close()
 }
@@ -0,0 +1,186 @@
+package tlsconfig
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
+)
+
+// CertExpiryWarning is how long before expiry a loaded certificate is logged as a warning
+const CertExpiryWarning = 30 * 24 * time.Hour
+
+// reloadDelay groups the file events of a single rotation, e.g. certificate and key written one after another
+const reloadDelay = 200 * time.Millisecond
+
+// CertStatus contains details of the certificate currently served
+type CertStatus struct {
+	Subject         string    `json:"subject"`
+	Issuer          string    `json:"issuer"`
+	SerialNumber    string    `json:"serial_number"`
+	NotBefore       time.Time `json:"not_before"`
+	NotAfter        time.Time `json:"not_after"`
+	DaysUntilExpiry int       `json:"days_until_expiry"`
+	ReloadedAt      time.Time `json:"reloaded_at"`
+}
+
+// CertReloader serves the certificate loaded from files through tls.Config.GetCertificate and loads it again when
+// the files change, so rotated certificates are used without restarting the server.
+// A certificate which fails to load is logged and the previous one keeps being served.
+type CertReloader struct {
+	CertFile string
+	KeyFile  string
+	Logger   *zerolog.Logger
+
+	mu         sync.RWMutex
+	cert       *tls.Certificate
+	reloadedAt time.Time
+
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+	now     func() time.Time
+}
+
+// NewCertReloader returns a new CertReloader instance with the certificate loaded from files
+func NewCertReloader(certFile, keyFile string, l *zerolog.Logger) (*CertReloader, error) {
+	cr := &CertReloader{CertFile: certFile, KeyFile: keyFile, Logger: l, now: time.Now}
+	if err := cr.Reload(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// Reload loads the certificate from files and serves it from now on if it differs from the current one
+func (cr *CertReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(cr.CertFile, cr.KeyFile)
+	if err != nil {
+		return fmt.Errorf("tls: failed to load certificate: %w", err)
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return fmt.Errorf("tls: failed to parse certificate: %w", err)
+	}
+
+	cr.mu.Lock()
+	if cr.cert != nil && bytes.Equal(cr.cert.Certificate[0], cert.Certificate[0]) {
+		cr.mu.Unlock()
+		return nil
+	}
+	cr.cert = &cert
+	cr.reloadedAt = cr.now()
+	cr.mu.Unlock()
+
+	cr.logExpiry(cert.Leaf)
+	return nil
+}
+
+// GetCertificate returns the current certificate, it is used as tls.Config.GetCertificate
+func (cr *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return cr.cert, nil
+}
+
+// Status returns details of the current certificate
+func (cr *CertReloader) Status() *CertStatus {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	leaf := cr.cert.Leaf
+	return &CertStatus{
+		Subject:         leaf.Subject.String(),
+		Issuer:          leaf.Issuer.String(),
+		SerialNumber:    leaf.SerialNumber.String(),
+		NotBefore:       leaf.NotBefore,
+		NotAfter:        leaf.NotAfter,
+		DaysUntilExpiry: daysUntil(leaf.NotAfter, cr.now()),
+		ReloadedAt:      cr.reloadedAt,
+	}
+}
+
+// Watch reloads the certificate whenever the files change until Close is called.
+// Directories of the files are watched so that files replaced by rename or symlink swap are detected as well.
+func (cr *CertReloader) Watch() error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("tls: failed to watch certificate: %w", err)
+	}
+	dirs := map[string]bool{filepath.Dir(cr.CertFile): true, filepath.Dir(cr.KeyFile): true}
+	for dir := range dirs {
+		if err := w.Add(dir); err != nil {
+			w.Close()
+			return fmt.Errorf("tls: failed to watch certificate: %w", err)
+		}
+	}
+	cr.watcher = w
+	cr.done = make(chan struct{})
+	go cr.watch()
+	return nil
+}
+
+// Close stops watching the files
+func (cr *CertReloader) Close() error {
+	if cr.watcher == nil {
+		return nil
+	}
+	err := cr.watcher.Close()
+	<-cr.done
+	cr.watcher = nil
+	return err
+}
+
+func (cr *CertReloader) watch() {
+	defer close(cr.done)
+	timer := time.NewTimer(reloadDelay)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case _, ok := <-cr.watcher.Events:
+			if !ok {
+				return
+			}
+			// any change in the directories may be a rotation, reload is skipped if the certificate did not change
+			timer.Reset(reloadDelay)
+		case err, ok := <-cr.watcher.Errors:
+			if !ok {
+				return
+			}
+			cr.Logger.Error().Err(err).Msg("tls certificate watcher failed")
+		case <-timer.C:
+			if err := cr.Reload(); err != nil {
+				cr.Logger.Error().Err(err).Str("cert_file", cr.CertFile).Msg("failed to reload tls certificate, keeping the current one")
+			}
+		}
+	}
+}
+
+func (cr *CertReloader) logExpiry(leaf *x509.Certificate) {
+	now := cr.now()
+	var e *zerolog.Event
+	switch {
+	case now.After(leaf.NotAfter):
+		e = cr.Logger.Error()
+	case leaf.NotAfter.Sub(now) < CertExpiryWarning:
+		e = cr.Logger.Warn()
+	default:
+		e = cr.Logger.Info()
+	}
+	e.Str("subject", leaf.Subject.String()).
+		Time("not_after", leaf.NotAfter).
+		Int("days_until_expiry", daysUntil(leaf.NotAfter, now)).
+		Msg("tls certificate loaded")
+}
+
+// daysUntil returns number of whole days until t, negative once t has passed
+func daysUntil(t, now time.Time) int {
+	d := t.Sub(now)
+	if d < 0 {
+		return -int((-d).Hours() / 24)
+	}
+	return int(d.Hours() / 24)
+}
@@ -0,0 +1,115 @@
+package tlsconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCertReloader(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tlsconfig")
+	defer os.RemoveAll(dir)
+	ca := newTestCert(t, "ca", nil, time.Now().Add(time.Hour))
+	caFile, _ := ca.write(t, dir, "ca")
+	certFile, keyFile := newTestCert(t, "localhost", ca, time.Now().Add(time.Hour)).write(t, dir, "server")
+
+	type TestCase struct {
+		Name     string
+		CertFile string
+		KeyFile  string
+		WantErr  bool
+	}
+	tests := []TestCase{
+		{Name: "valid", CertFile: certFile, KeyFile: keyFile},
+		{Name: "missing certificate", CertFile: filepath.Join(dir, "missing.crt"), KeyFile: keyFile, WantErr: true},
+		{Name: "key of another certificate", CertFile: certFile, KeyFile: filepath.Join(dir, "ca.key"), WantErr: true},
+		{Name: "missing key", CertFile: caFile, KeyFile: filepath.Join(dir, "missing.key"), WantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			cr, err := NewCertReloader(tt.CertFile, tt.KeyFile, testLogger())
+			if tt.WantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			cert, err := cr.GetCertificate(nil)
+			assert.Nil(t, err)
+			assert.Equal(t, "localhost", cert.Leaf.Subject.CommonName)
+		})
+	}
+}
+
+func TestCertReloader_Status(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tlsconfig")
+	defer os.RemoveAll(dir)
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	ca := newTestCert(t, "ca", nil, now.Add(365*24*time.Hour))
+	certFile, keyFile := newTestCert(t, "localhost", ca, now.Add(10*24*time.Hour+time.Hour)).write(t, dir, "server")
+	cr, err := NewCertReloader(certFile, keyFile, testLogger())
+	assert.Nil(t, err)
+
+	type TestCase struct {
+		Name string
+		Now  time.Time
+		Days int
+	}
+	tests := []TestCase{
+		{Name: "valid", Now: now, Days: 10},
+		{Name: "expires today", Now: now.Add(10 * 24 * time.Hour), Days: 0},
+		{Name: "expired", Now: now.Add(12*24*time.Hour + 2*time.Hour), Days: -2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			cr.now = func() time.Time { return tt.Now }
+			s := cr.Status()
+			assert.Equal(t, tt.Days, s.DaysUntilExpiry)
+			assert.Equal(t, "CN=localhost", s.Subject)
+			assert.Equal(t, "CN=ca", s.Issuer)
+		})
+	}
+}
+
+// TestCertReloader_Watch rotates the certificate files and checks the new certificate is served without restart
+func TestCertReloader_Watch(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tlsconfig")
+	defer os.RemoveAll(dir)
+	ca := newTestCert(t, "ca", nil, time.Now().Add(time.Hour))
+	old := newTestCert(t, "localhost", ca, time.Now().Add(time.Hour))
+	certFile, keyFile := old.write(t, dir, "server")
+	cr, err := NewCertReloader(certFile, keyFile, testLogger())
+	assert.Nil(t, err)
+	assert.Nil(t, cr.Watch())
+	defer cr.Close()
+
+	served := func() string {
+		cert, _ := cr.GetCertificate(nil)
+		return cert.Leaf.SerialNumber.String()
+	}
+	waitFor := func(serial string) bool {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if served() == serial {
+				return true
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		return false
+	}
+
+	rotated := newTestCert(t, "localhost", ca, time.Now().Add(48*time.Hour))
+	rotated.write(t, dir, "server")
+	assert.True(t, waitFor(rotated.cert.SerialNumber.String()))
+	assert.Equal(t, 1, cr.Status().DaysUntilExpiry)
+
+	// invalid files are ignored and the current certificate keeps being served
+	assert.Nil(t, ioutil.WriteFile(certFile, []byte("invalid"), 0600))
+	time.Sleep(3 * reloadDelay)
+	assert.Equal(t, rotated.cert.SerialNumber.String(), served())
+
+	assert.Nil(t, cr.Close())
+}
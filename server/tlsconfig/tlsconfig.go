@@ -0,0 +1,92 @@
+/*
+	Package tlsconfig builds tls configuration of the https listener from config.TLSConfig.
+	The server certificate is served by CertReloader which picks up rotated certificate files without restart.
+*/
+
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"go-app/server/config"
+	"io/ioutil"
+)
+
+// tls versions accepted as TLSConfig.MinVersion
+var versions = map[string]uint16{
+	"":    tls.VersionTLS12,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// client certificate verification modes accepted as TLSConfig.ClientAuth
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"":                tls.RequireAndVerifyClientCert,
+	"require":         tls.RequireAndVerifyClientCert,
+	"verify_if_given": tls.VerifyClientCertIfGiven,
+}
+
+// NewTLSConfig returns tls configuration serving the certificate of the reloader.
+// Client certificates are verified against ClientCAFile when it is set.
+func NewTLSConfig(c *config.TLSConfig, certs *CertReloader) (*tls.Config, error) {
+	minVersion, ok := versions[c.MinVersion]
+	if !ok {
+		return nil, fmt.Errorf("tls: unsupported min version %q", c.MinVersion)
+	}
+	suites, err := CipherSuites(c.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	tc := &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   suites,
+		GetCertificate: certs.GetCertificate,
+	}
+	if c.ClientCAFile != "" {
+		clientAuth, ok := clientAuthTypes[c.ClientAuth]
+		if !ok {
+			return nil, fmt.Errorf("tls: unsupported client auth %q", c.ClientAuth)
+		}
+		pool, err := LoadCertPool(c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tc.ClientCAs = pool
+		tc.ClientAuth = clientAuth
+	}
+	return tc, nil
+}
+
+// CipherSuites returns ids of the named cipher suites. Insecure cipher suites are rejected.
+func CipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	known := make(map[string]uint16)
+	for _, s := range tls.CipherSuites() {
+		known[s.Name] = s.ID
+	}
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("tls: unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// LoadCertPool returns pool of the certificates in the pem file
+func LoadCertPool(file string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("tls: failed to read CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("tls: no certificate found in %s", file)
+	}
+	return pool, nil
+}
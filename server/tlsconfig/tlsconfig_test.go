@@ -0,0 +1,210 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"go-app/server/auth"
+	"go-app/server/config"
+	"go-app/server/handler"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert returns a certificate signed by parent, or a self signed CA when parent is nil
+func newTestCert(t *testing.T, cn string, parent *testCert, notAfter time.Time) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %s", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCert{cert: cert, key: key}
+}
+
+// write stores certificate and key pem files in dir and returns their paths
+func (c *testCert) write(t *testing.T, dir, name string) (string, string) {
+	keyDER, _ := x509.MarshalECPrivateKey(c.key)
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	assert.Nil(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0600))
+	assert.Nil(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func testLogger() *zerolog.Logger {
+	l := zerolog.Nop()
+	return &l
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key, Leaf: c.cert}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tlsconfig")
+	defer os.RemoveAll(dir)
+	ca := newTestCert(t, "ca", nil, time.Now().Add(time.Hour))
+	caFile, _ := ca.write(t, dir, "ca")
+	certFile, keyFile := newTestCert(t, "localhost", ca, time.Now().Add(time.Hour)).write(t, dir, "server")
+	certs, err := NewCertReloader(certFile, keyFile, testLogger())
+	assert.Nil(t, err)
+
+	type TestCase struct {
+		Name    string
+		Config  config.TLSConfig
+		WantErr bool
+		Check   func(*tls.Config)
+	}
+	tests := []TestCase{
+		{
+			Name:   "defaults",
+			Config: config.TLSConfig{CertFile: certFile, KeyFile: keyFile},
+			Check: func(tc *tls.Config) {
+				assert.Equal(t, uint16(tls.VersionTLS12), tc.MinVersion)
+				assert.Nil(t, tc.CipherSuites)
+				assert.Nil(t, tc.ClientCAs)
+				assert.Equal(t, tls.NoClientCert, tc.ClientAuth)
+			},
+		},
+		{
+			Name: "mutual tls",
+			Config: config.TLSConfig{
+				CertFile:     certFile,
+				KeyFile:      keyFile,
+				MinVersion:   "1.3",
+				CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+				ClientCAFile: caFile,
+			},
+			Check: func(tc *tls.Config) {
+				assert.Equal(t, uint16(tls.VersionTLS13), tc.MinVersion)
+				assert.Equal(t, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}, tc.CipherSuites)
+				assert.NotNil(t, tc.ClientCAs)
+				assert.Equal(t, tls.RequireAndVerifyClientCert, tc.ClientAuth)
+			},
+		},
+		{
+			Name:   "optional client certificate",
+			Config: config.TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, ClientAuth: "verify_if_given"},
+			Check: func(tc *tls.Config) {
+				assert.Equal(t, tls.VerifyClientCertIfGiven, tc.ClientAuth)
+			},
+		},
+		{Name: "unsupported min version", Config: config.TLSConfig{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.0"}, WantErr: true},
+		{Name: "insecure cipher suite", Config: config.TLSConfig{CertFile: certFile, KeyFile: keyFile, CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}}, WantErr: true},
+		{Name: "invalid client auth", Config: config.TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, ClientAuth: "any"}, WantErr: true},
+		{Name: "invalid CA bundle", Config: config.TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile}, WantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			tc, err := NewTLSConfig(&tt.Config, certs)
+			if tt.WantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			tt.Check(tc)
+		})
+	}
+}
+
+// TestMutualTLS calls a handler over mutual tls and checks the claim derived from the client certificate
+func TestMutualTLS(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tlsconfig")
+	defer os.RemoveAll(dir)
+	ca := newTestCert(t, "ca", nil, time.Now().Add(time.Hour))
+	caFile, _ := ca.write(t, dir, "ca")
+	certFile, keyFile := newTestCert(t, "localhost", ca, time.Now().Add(time.Hour)).write(t, dir, "server")
+	c := &config.TLSConfig{
+		EnableTLS:    true,
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		ClientCAFile: caFile,
+		Clients:      []config.TLSClientConfig{{CommonName: "billing", Scopes: []string{"orders:read"}}},
+	}
+	certs, err := NewCertReloader(certFile, keyFile, testLogger())
+	assert.Nil(t, err)
+	tc, err := NewTLSConfig(c, certs)
+	assert.Nil(t, err)
+
+	policy := auth.NewPolicy()
+	srv := httptest.NewUnstartedServer(&handler.Request{
+		ClientCerts: auth.NewClientCertificateAuth(c),
+		Policy:      policy,
+		Permissions: []string{"orders:read"},
+		HandlerFunc: func(requestCTX *handler.RequestContext, w http.ResponseWriter, r *http.Request) {
+			requestCTX.SetAppResponse(requestCTX.UserClaim.(*auth.ServiceClaim).ID, http.StatusOK)
+		},
+	})
+	srv.TLS = tc
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	// server name is sent so that the reloader certificate is served instead of the httptest default
+	get := func(certs ...tls.Certificate) (*http.Response, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "localhost", Certificates: certs}}}
+		return client.Get(srv.URL)
+	}
+
+	resp, err := get(newTestCert(t, "billing", ca, time.Now().Add(time.Hour)).tlsCertificate())
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "{\"success\":true,\"payload\":\"billing\"}\n", string(body))
+
+	// verified service without the required scope
+	resp, err = get(newTestCert(t, "reports", ca, time.Now().Add(time.Hour)).tlsCertificate())
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// certificate of another CA and missing certificate fail the handshake
+	other := newTestCert(t, "other-ca", nil, time.Now().Add(time.Hour))
+	_, err = get(newTestCert(t, "billing", other, time.Now().Add(time.Hour)).tlsCertificate())
+	assert.NotNil(t, err)
+	_, err = get()
+	assert.NotNil(t, err)
+}